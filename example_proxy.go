@@ -49,6 +49,7 @@ func handleConn(conn *minecraft.Conn, listener *minecraft.Listener, config confi
 		KeepXBLIdentityData: true,
 		IdentityData:        conn.IdentityData(),
 		ClientData:          conn.ClientData(),
+		EnableClientCache:   conn.ClientCacheEnabled(),
 		TokenSource:         src,
 	}.Dial("raknet", config.Connection.RemoteAddress)
 	if err != nil {
@@ -70,7 +71,15 @@ func handleConn(conn *minecraft.Conn, listener *minecraft.Listener, config confi
 	}()
 	g.Wait()
 
+	// The translation state of the connection is released once both sides stopped forwarding packets.
+	var closed sync.WaitGroup
+	closed.Add(2)
 	go func() {
+		closed.Wait()
+		release(conn)
+	}()
+	go func() {
+		defer closed.Done()
 		defer listener.Disconnect(conn, "connection lost")
 		defer serverConn.Close()
 		for {
@@ -87,94 +96,12 @@ func handleConn(conn *minecraft.Conn, listener *minecraft.Listener, config confi
 		}
 	}()
 	go func() {
+		defer closed.Done()
 		defer serverConn.Close()
 		defer listener.Disconnect(conn, "connection lost")
 
-		// r := world.Overworld.Range()
-		// biomeBufferCache := make(map[protocol.ChunkPos][]byte)
-
 		for {
 			pk, err := serverConn.ReadPacket()
-
-			// switch pk := pk.(type) {
-			// case *packet.SubChunk:
-			// 	chunkBuf := bytes.NewBuffer(nil)
-			// 	blockEntities := make([]map[string]any, 0)
-			// 	for _, entry := range pk.SubChunkEntries {
-			// 		if entry.Result != protocol.SubChunkResultSuccess {
-			// 			chunkBuf.Write([]byte{
-			// 				chunk.SubChunkVersion,
-			// 				0, // The client will treat this as all air.
-			// 				uint8(entry.Offset[1]),
-			// 			})
-			// 			continue
-			// 		}
-
-			// 		var ind uint8
-			// 		readBuf := bytes.NewBuffer(entry.RawPayload)
-			// 		sub, err := chunk.DecodeSubChunk(latest.NewBlockMapping().Air(), r, readBuf, &ind, chunk.NetworkEncoding)
-			// 		if err != nil {
-			// 			fmt.Println(err)
-			// 			continue
-			// 		}
-
-			// 		var blockEntity map[string]any
-			// 		dec := nbt.NewDecoderWithEncoding(readBuf, nbt.NetworkLittleEndian)
-			// 		for {
-			// 			if err := dec.Decode(&blockEntity); err != nil {
-			// 				break
-			// 			}
-			// 			blockEntities = append(blockEntities, blockEntity)
-			// 		}
-
-			// 		chunkBuf.Write(chunk.EncodeSubChunk(sub, chunk.NetworkEncoding, chunk.SubChunkVersion9, r, int(ind)))
-			// 	}
-
-			// 	chunkPos := protocol.ChunkPos{pk.Position.X(), pk.Position.Z()}
-			// 	_, _ = chunkBuf.Write(append(biomeBufferCache[chunkPos], 0))
-			// 	delete(biomeBufferCache, chunkPos)
-
-			// 	enc := nbt.NewEncoderWithEncoding(chunkBuf, nbt.NetworkLittleEndian)
-			// 	for _, b := range blockEntities {
-			// 		_ = enc.Encode(b)
-			// 	}
-
-			// 	_ = conn.WritePacket(&packet.LevelChunk{
-			// 		Position:      chunkPos,
-			// 		SubChunkCount: uint32(len(pk.SubChunkEntries)),
-			// 		RawPayload:    append([]byte(nil), chunkBuf.Bytes()...),
-			// 	})
-			// 	_ = conn.Flush()
-			// 	continue
-			// case *packet.LevelChunk:
-			// 	if pk.SubChunkCount != protocol.SubChunkRequestModeLimitless && pk.SubChunkCount != protocol.SubChunkRequestModeLimited {
-			// 		// No changes to be made here.
-			// 		break
-			// 	}
-
-			// 	max := r.Height() >> 4
-			// 	if pk.SubChunkCount == protocol.SubChunkRequestModeLimited {
-			// 		max = int(pk.HighestSubChunk)
-			// 	}
-
-			// 	offsets := make([]protocol.SubChunkOffset, 0, max)
-			// 	for i := 0; i < max; i++ {
-			// 		offsets = append(offsets, protocol.SubChunkOffset{0, int8(i + (r[0] >> 4)), 0})
-			// 	}
-
-			// 	biomeBufferCache[pk.Position] = pk.RawPayload[:len(pk.RawPayload)-1]
-			// 	_ = serverConn.WritePacket(&packet.SubChunkRequest{
-			// 		Position: protocol.SubChunkPos{pk.Position.X(), 0, pk.Position.Z()},
-			// 		Offsets:  offsets,
-			// 	})
-			// 	_ = serverConn.Flush()
-			// 	continue
-			// 	// case *packet.Transfer:
-			// 	// 	a.remoteAddress = fmt.Sprintf("%s:%d", pk.Address, pk.Port)
-
-			// 	// 	pk.Address = "127.0.0.1"
-			// 	// 	pk.Port = a.localPort
-			//}
 			if err := conn.WritePacket(pk); err != nil {
 				return
 			}
@@ -193,6 +120,20 @@ func handleConn(conn *minecraft.Conn, listener *minecraft.Listener, config confi
 	}()
 }
 
+// releaser is implemented by protocols that hold translation state for every connection.
+type releaser interface {
+	// Release releases the translation state of the connection passed.
+	Release(conn *minecraft.Conn)
+}
+
+// release releases the translation state the protocol of the connection passed holds for it. It must be called for
+// every closed connection of a legacy version, as the state is otherwise never freed.
+func release(conn *minecraft.Conn) {
+	if r, ok := conn.Protocol().(releaser); ok {
+		r.Release(conn)
+	}
+}
+
 type config struct {
 	Connection struct {
 		LocalAddress  string
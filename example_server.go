@@ -90,16 +90,29 @@ type listener struct {
 // Accept blocks until the next connection is established and returns it. An error is returned if the Listener was
 // closed using Close.
 func (l listener) Accept() (session.Conn, error) {
-	conn, err := l.Listener.Accept()
+	c, err := l.Listener.Accept()
 	if err != nil {
 		return nil, err
 	}
-	return conn.(session.Conn), err
+	return conn{c.(*minecraft.Conn)}, err
 }
 
 // Disconnect disconnects a connection from the Listener with a reason.
-func (l listener) Disconnect(conn session.Conn, reason string) error {
-	return l.Listener.Disconnect(conn.(*minecraft.Conn), reason)
+func (l listener) Disconnect(c session.Conn, reason string) error {
+	return l.Listener.Disconnect(c.(conn).Conn, reason)
+}
+
+// conn is a session.Conn that wraps around a minecraft.Conn, releasing the translation state of its protocol once it
+// is closed.
+type conn struct {
+	*minecraft.Conn
+}
+
+// Close closes the connection and releases the translation state of its protocol.
+func (c conn) Close() error {
+	err := c.Conn.Close()
+	release(c.Conn)
+	return err
 }
 
 // statusProvider handles the way the server shows up in the server list. The
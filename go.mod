@@ -3,6 +3,7 @@ module github.com/flonja/multiversion
 go 1.20
 
 require (
+	github.com/cespare/xxhash v1.1.0
 	github.com/df-mc/dragonfly v0.9.9-0.20230714144543-281943e6efc4
 	github.com/df-mc/worldupgrader v1.0.8
 	github.com/go-gl/mathgl v1.0.0
@@ -20,7 +21,6 @@ require (
 
 require (
 	github.com/brentp/intintmap v0.0.0-20190211203843-30dc0ade9af9 // indirect
-	github.com/cespare/xxhash v1.1.0 // indirect
 	github.com/df-mc/atomic v1.10.0 // indirect
 	github.com/df-mc/goleveldb v1.1.9 // indirect
 	github.com/golang/protobuf v1.5.3 // indirect
@@ -8,7 +8,8 @@ import (
 
 // NetworkDecode decodes the network serialised data passed into a Chunk if successful. If not, the chunk
 // returned is nil and the error non-nil.
-// The sub chunk count passed must be that found in the LevelChunk packet.
+// The sub chunk count passed must be that found in the LevelChunk packet. If oldFormat is true, the biomes are
+// decoded from the two-dimensional format used before 1.18.
 // noinspection GoUnusedExportedFunction
 func NetworkDecode(air uint32, buf *bytes.Buffer, count int, oldFormat bool, r cube.Range) (*Chunk, error) {
 	var (
@@ -17,9 +18,6 @@ func NetworkDecode(air uint32, buf *bytes.Buffer, count int, oldFormat bool, r c
 	)
 	for i := 0; i < count; i++ {
 		index := uint8(i)
-		if oldFormat {
-			index += 4
-		}
 		c.sub[index], err = DecodeSubChunk(air, r, buf, &index, NetworkEncoding)
 		if err != nil {
 			return nil, err
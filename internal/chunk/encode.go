@@ -13,19 +13,12 @@ var pool = sync.Pool{
 	},
 }
 
-func NetworkEncode(air uint32, c *Chunk, oldFormat bool) ([]byte, error) {
+// NetworkEncode encodes the chunk passed into its network serialised form. If oldFormat is true, the biomes are
+// encoded in the two-dimensional format used before 1.18.
+func NetworkEncode(c *Chunk, oldFormat bool) ([]byte, error) {
 	buf := pool.Get().(*bytes.Buffer)
-	if oldFormat {
-		for y := 0; y < 4; y++ {
-			_, _ = buf.Write(EncodeSubChunk(NewSubChunk(air), NetworkEncoding, SubChunkVersion8, c.r, y))
-		}
-	}
 	for i := 0; i < len(c.sub); i++ {
-		index := i
-		if oldFormat {
-			index += 4
-		}
-		_, _ = buf.Write(EncodeSubChunk(c.sub[index], NetworkEncoding, SubChunkVersion8, c.r, index))
+		_, _ = buf.Write(EncodeSubChunk(c.sub[i], NetworkEncoding, SubChunkVersion8, c.r, i))
 	}
 	if oldFormat {
 		biomes := make([]byte, 256)
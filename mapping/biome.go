@@ -0,0 +1,89 @@
+package mapping
+
+import (
+	"encoding/json"
+)
+
+type Biome interface {
+	// BiomeIDToName converts a biome ID to its string identifier.
+	BiomeIDToName(uint32) (string, bool)
+	// BiomeNameToID converts a biome string identifier to its ID. If the biome does not exist in this version, the
+	// nearest matching biome that does exist is returned instead.
+	BiomeNameToID(string) (uint32, bool)
+	// Plains returns the ID of the plains biome, used when no biome can be matched at all.
+	Plains() uint32
+}
+
+// biomeFallbacks holds the closest matching biome for biomes that did not exist in older versions of the game. A
+// fallback may itself have a fallback, which is followed until a biome is found that exists in the target version.
+var biomeFallbacks = map[string]string{
+	"cherry_grove":    "meadow",
+	"mangrove_swamp":  "swampland",
+	"deep_dark":       "dripstone_caves",
+	"meadow":          "plains",
+	"grove":           "cold_taiga",
+	"snowy_slopes":    "ice_mountains",
+	"frozen_peaks":    "ice_mountains",
+	"jagged_peaks":    "ice_mountains",
+	"stony_peaks":     "extreme_hills",
+	"lush_caves":      "jungle",
+	"dripstone_caves": "extreme_hills",
+	"soulsand_valley": "hell",
+	"crimson_forest":  "hell",
+	"warped_forest":   "hell",
+	"basalt_deltas":   "hell",
+}
+
+type DefaultBiomeMapping struct {
+	// biomeIDsToNames holds a map to translate biome IDs to string identifiers.
+	biomeIDsToNames map[uint32]string
+	// biomeNamesToIDs holds a map to translate biome string identifiers to IDs.
+	biomeNamesToIDs map[string]uint32
+	plainsID        uint32
+}
+
+func NewBiomeMapping(raw []byte) *DefaultBiomeMapping {
+	biomeIDsToNames := make(map[uint32]string)
+	biomeNamesToIDs := make(map[string]uint32)
+	var plainsID *uint32
+
+	var biomes map[string]uint32
+	if err := json.Unmarshal(raw, &biomes); err != nil {
+		panic(err)
+	}
+	for name, id := range biomes {
+		id := id
+		if name == "plains" {
+			plainsID = &id
+		}
+
+		biomeNamesToIDs[name] = id
+		biomeIDsToNames[id] = name
+	}
+	if plainsID == nil {
+		panic("couldn't find plains")
+	}
+
+	return &DefaultBiomeMapping{biomeIDsToNames: biomeIDsToNames, biomeNamesToIDs: biomeNamesToIDs, plainsID: *plainsID}
+}
+
+func (m *DefaultBiomeMapping) BiomeIDToName(id uint32) (string, bool) {
+	name, ok := m.biomeIDsToNames[id]
+	return name, ok
+}
+
+func (m *DefaultBiomeMapping) BiomeNameToID(name string) (uint32, bool) {
+	for {
+		if id, ok := m.biomeNamesToIDs[name]; ok {
+			return id, true
+		}
+		var ok bool
+		if name, ok = biomeFallbacks[name]; !ok {
+			return 0, false
+		}
+	}
+}
+
+func (m *DefaultBiomeMapping) Plains() uint32 {
+	return m.plainsID
+}
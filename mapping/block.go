@@ -7,7 +7,11 @@ import (
 	"github.com/sandertv/gophertunnel/minecraft/nbt"
 	"github.com/sandertv/gophertunnel/minecraft/protocol"
 	"github.com/segmentio/fasthash/fnv1"
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
 	"sort"
+	"sync"
+	"sync/atomic"
 )
 
 type Block interface {
@@ -15,25 +19,47 @@ type Block interface {
 	StateToRuntimeID(blockupgrader.BlockState) (uint32, bool)
 	// RuntimeIDToState converts a runtime ID to a name and its state properties.
 	RuntimeIDToState(uint32) (blockupgrader.BlockState, bool)
-	// DowngradeBlockActorData downgrades the input sub chunk to a legacy block actor.
-	DowngradeBlockActorData(map[string]any)
-	// UpgradeBlockActorData upgrades the input sub chunk to the latest block actor.
-	UpgradeBlockActorData(map[string]any)
+	// RuntimeIDToHash converts a runtime ID to the hash of its upgraded block state. Hashes are computed once for every
+	// state, so looking a runtime ID up in another mapping through its hash is cheaper than through its state.
+	RuntimeIDToHash(uint32) (internal.StateHash, bool)
+	// HashToRuntimeID converts the hash of an upgraded block state to a runtime ID.
+	HashToRuntimeID(internal.StateHash) (uint32, bool)
+	// MatchState finds the runtime ID of the block state with the name passed that has all required properties and
+	// shares the most properties with the preferred properties.
+	MatchState(name string, required, preferred map[string]any) (uint32, bool)
 	// Adjust adjusts the latest mappings to account for custom states.
 	Adjust([]protocol.BlockEntry)
+	// Clone returns a copy of the mapping that can be adjusted without affecting the original mapping.
+	Clone() Block
 	Air() uint32
 }
 
+// namedState is a block state indexed by its name, holding its runtime ID and upgraded properties.
+type namedState struct {
+	runtimeID  uint32
+	properties map[string]any
+}
+
+// DefaultBlockMapping is a block mapping that is safe for concurrent use. Its lookup tables are never modified once
+// stored: adjusting the mapping stores new tables instead, so lookups never need to lock.
 type DefaultBlockMapping struct {
-	// states holds a list of all possible vanilla block states.
+	// palette holds the current lookup tables of the mapping.
+	palette atomic.Pointer[blockPalette]
+	// mu is held while adjusting the mapping, so that concurrent adjustments are not lost.
+	mu sync.Mutex
+}
+
+// blockPalette holds the lookup tables of a block mapping at one point in time.
+type blockPalette struct {
+	// states holds a list of all possible block states, indexed by runtime ID.
 	states []blockupgrader.BlockState
+	// hashes holds the hash of every upgraded block state, indexed by runtime ID.
+	hashes []internal.StateHash
 	// stateRuntimeIDs holds a map for looking up the runtime ID of a block by the stateHash it produces.
 	stateRuntimeIDs map[internal.StateHash]uint32
-	// runtimeIDToState holds a map for looking up the blockState of a block by its runtime ID.
-	runtimeIDToState     map[uint32]blockupgrader.BlockState
-	upgrader, downgrader func(map[string]any) map[string]any
-
-	// airRID is the runtime ID of the air block in the latest version of the game.
+	// nameToStates holds a map for looking up all block states with the same upgraded name.
+	nameToStates map[string][]namedState
+	// airRID is the runtime ID of the air block.
 	airRID uint32
 }
 
@@ -41,69 +67,100 @@ func NewBlockMapping(raw []byte) *DefaultBlockMapping {
 	dec := nbt.NewDecoder(bytes.NewBuffer(raw))
 
 	var states []blockupgrader.BlockState
-	stateRuntimeIDs := make(map[internal.StateHash]uint32)
-	runtimeIDToState := make(map[uint32]blockupgrader.BlockState)
-	var airRID *uint32
-
 	var s blockupgrader.BlockState
 	for {
 		if err := dec.Decode(&s); err != nil {
 			break
 		}
-
-		rid := uint32(len(states))
 		states = append(states, s)
-		if s.Name == "minecraft:air" {
+	}
+
+	m := &DefaultBlockMapping{}
+	m.palette.Store(newBlockPalette(states))
+	return m
+}
+
+// newBlockPalette creates the lookup tables for the block states passed, indexed by runtime ID.
+func newBlockPalette(states []blockupgrader.BlockState) *blockPalette {
+	p := &blockPalette{
+		states:          states,
+		hashes:          make([]internal.StateHash, len(states)),
+		stateRuntimeIDs: make(map[internal.StateHash]uint32, len(states)),
+		nameToStates:    make(map[string][]namedState),
+	}
+	var airRID *uint32
+	for i, state := range states {
+		rid := uint32(i)
+		if state.Name == "minecraft:air" {
 			airRID = &rid
 		}
 
-		stateRuntimeIDs[internal.HashState(blockupgrader.Upgrade(s))] = rid
-		runtimeIDToState[rid] = s
+		upgraded := upgradeState(state)
+		p.hashes[rid] = internal.HashState(upgraded)
+		p.stateRuntimeIDs[p.hashes[rid]] = rid
+		p.nameToStates[upgraded.Name] = append(p.nameToStates[upgraded.Name], namedState{runtimeID: rid, properties: upgraded.Properties})
 	}
 	if airRID == nil {
 		panic("couldn't find air")
 	}
-
-	return &DefaultBlockMapping{
-		states:           states,
-		stateRuntimeIDs:  stateRuntimeIDs,
-		runtimeIDToState: runtimeIDToState,
-		airRID:           *airRID,
-	}
-}
-
-func (m *DefaultBlockMapping) WithBlockActorRemapper(downgrader, upgrader func(map[string]any) map[string]any) *DefaultBlockMapping {
-	m.downgrader = downgrader
-	m.upgrader = upgrader
-	return m
+	p.airRID = *airRID
+	return p
 }
 
 func (m *DefaultBlockMapping) StateToRuntimeID(state blockupgrader.BlockState) (uint32, bool) {
-	rid, ok := m.stateRuntimeIDs[internal.HashState(blockupgrader.Upgrade(state))]
-	return rid, ok
+	return m.HashToRuntimeID(internal.HashState(upgradeState(state)))
 }
 
 func (m *DefaultBlockMapping) RuntimeIDToState(runtimeId uint32) (blockupgrader.BlockState, bool) {
-	state, found := m.runtimeIDToState[runtimeId]
-	return state, found
+	p := m.palette.Load()
+	if runtimeId >= uint32(len(p.states)) {
+		return blockupgrader.BlockState{}, false
+	}
+	return p.states[runtimeId], true
 }
 
-func (m *DefaultBlockMapping) DowngradeBlockActorData(actorData map[string]any) {
-	if m.downgrader != nil {
-		m.downgrader(actorData)
+func (m *DefaultBlockMapping) RuntimeIDToHash(runtimeId uint32) (internal.StateHash, bool) {
+	p := m.palette.Load()
+	if runtimeId >= uint32(len(p.hashes)) {
+		return internal.StateHash{}, false
 	}
+	return p.hashes[runtimeId], true
+}
+
+func (m *DefaultBlockMapping) HashToRuntimeID(hash internal.StateHash) (uint32, bool) {
+	rid, ok := m.palette.Load().stateRuntimeIDs[hash]
+	return rid, ok
 }
 
-func (m *DefaultBlockMapping) UpgradeBlockActorData(actorData map[string]any) {
-	if m.upgrader != nil {
-		m.upgrader(actorData)
+func (m *DefaultBlockMapping) MatchState(name string, required, preferred map[string]any) (uint32, bool) {
+	var (
+		runtimeID uint32
+		found     bool
+		bestScore = -1
+	)
+	for _, state := range m.palette.Load().nameToStates[name] {
+		if !matchesProperties(state.properties, required) {
+			continue
+		}
+		score := 0
+		for k, v := range preferred {
+			if state.properties[k] == v {
+				score++
+			}
+		}
+		if score > bestScore {
+			runtimeID, found, bestScore = state.runtimeID, true, score
+		}
 	}
+	return runtimeID, found
 }
 
 func (m *DefaultBlockMapping) Adjust(entries []protocol.BlockEntry) {
-	customStates := convert(entries)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	var newStates []blockupgrader.BlockState
-	for _, state := range customStates {
+	for _, state := range convert(entries) {
 		if _, ok := m.StateToRuntimeID(state); !ok {
 			newStates = append(newStates, state)
 		}
@@ -112,7 +169,8 @@ func (m *DefaultBlockMapping) Adjust(entries []protocol.BlockEntry) {
 		return
 	}
 
-	adjustedStates := append(m.states, customStates...)
+	// The states may be shared with clones of the mapping, so they are copied before sorting.
+	adjustedStates := append(slices.Clone(m.palette.Load().states), newStates...)
 	sort.SliceStable(adjustedStates, func(i, j int) bool {
 		stateOne, stateTwo := adjustedStates[i], adjustedStates[j]
 		if stateOne.Name == stateTwo.Name {
@@ -120,15 +178,36 @@ func (m *DefaultBlockMapping) Adjust(entries []protocol.BlockEntry) {
 		}
 		return fnv1.HashString64(stateOne.Name) < fnv1.HashString64(stateTwo.Name)
 	})
+	m.palette.Store(newBlockPalette(adjustedStates))
+}
 
-	m.stateRuntimeIDs = make(map[internal.StateHash]uint32, len(adjustedStates))
-	m.runtimeIDToState = make(map[uint32]blockupgrader.BlockState, len(adjustedStates))
-	for rid, state := range adjustedStates {
-		m.stateRuntimeIDs[internal.HashState(blockupgrader.Upgrade(state))] = uint32(rid)
-		m.runtimeIDToState[uint32(rid)] = state
-	}
+// Clone returns a copy of the mapping sharing its lookup tables, which are replaced rather than modified when either
+// mapping is adjusted.
+func (m *DefaultBlockMapping) Clone() Block {
+	c := &DefaultBlockMapping{}
+	c.palette.Store(m.palette.Load())
+	return c
 }
 
 func (m *DefaultBlockMapping) Air() uint32 {
-	return m.airRID
+	return m.palette.Load().airRID
+}
+
+// matchesProperties checks if all properties passed are present in the state properties with the same value.
+func matchesProperties(stateProperties, properties map[string]any) bool {
+	for k, v := range properties {
+		if stateProperties[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// upgradeState upgrades the block state passed to the latest version. The properties are copied first, as the block
+// upgrader modifies the properties of the state passed.
+func upgradeState(state blockupgrader.BlockState) blockupgrader.BlockState {
+	state.Properties = maps.Clone(state.Properties)
+	state = blockupgrader.Upgrade(state)
+	state.Properties = maps.Clone(state.Properties)
+	return state
 }
@@ -0,0 +1,89 @@
+package mapping
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/df-mc/worldupgrader/blockupgrader"
+)
+
+// BlockFallback holds the rules used to find the closest matching block state for block states that do not exist in
+// a block mapping. Rules are keyed by the name of the missing block and hold the block shown instead, with any
+// properties it requires, both using the names of the latest version. The remaining properties are carried over from the missing block where the names match.
+type BlockFallback struct {
+	// rules holds the replacement of every missing block, indexed by its name.
+	rules map[string]blockupgrader.BlockState
+	// def is the replacement used for missing blocks that have no rule and no block with the same name.
+	def *blockupgrader.BlockState
+}
+
+// blockFallbackData is the layout of an encoded block fallback file.
+type blockFallbackData struct {
+	Default *blockFallbackRule           `json:"default"`
+	Rules   map[string]blockFallbackRule `json:"rules"`
+}
+
+// blockFallbackRule is a single replacement in an encoded block fallback file.
+type blockFallbackRule struct {
+	Name       string         `json:"name"`
+	Properties map[string]any `json:"properties"`
+}
+
+// NewBlockFallback decodes the JSON encoded block fallback rules passed.
+func NewBlockFallback(raw []byte) *BlockFallback {
+	var data blockFallbackData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		panic(err)
+	}
+	f := &BlockFallback{rules: make(map[string]blockupgrader.BlockState, len(data.Rules))}
+	for name, rule := range data.Rules {
+		f.rules[name] = rule.state()
+	}
+	if data.Default != nil {
+		def := data.Default.state()
+		f.def = &def
+	}
+	return f
+}
+
+// Resolve finds the runtime ID of the block state in the block mapping passed that most closely matches the state
+// passed. The rule of the state is tried first, then the state with the same name sharing the most properties, and
+// lastly the default rule.
+func (f *BlockFallback) Resolve(state blockupgrader.BlockState, m Block) (uint32, bool) {
+	state = upgradeState(state)
+	if rule, ok := f.rules[state.Name]; ok {
+		if rid, ok := m.MatchState(rule.Name, rule.Properties, state.Properties); ok {
+			return rid, true
+		}
+	}
+	if rid, ok := m.MatchState(state.Name, nil, state.Properties); ok {
+		return rid, true
+	}
+	if f.def != nil {
+		return m.MatchState(f.def.Name, f.def.Properties, state.Properties)
+	}
+	return 0, false
+}
+
+// state converts the rule to a block state, converting the JSON property values to the types used by
+// block properties.
+func (r blockFallbackRule) state() blockupgrader.BlockState {
+	properties := make(map[string]any, len(r.Properties))
+	for k, v := range r.Properties {
+		switch v := v.(type) {
+		case bool:
+			if v {
+				properties[k] = uint8(1)
+			} else {
+				properties[k] = uint8(0)
+			}
+		case float64:
+			properties[k] = int32(v)
+		case string:
+			properties[k] = v
+		default:
+			panic(fmt.Sprintf("invalid block property type %T for property %v", v, k))
+		}
+	}
+	return blockupgrader.BlockState{Name: r.Name, Properties: properties}
+}
@@ -0,0 +1,70 @@
+package mapping_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/df-mc/worldupgrader/blockupgrader"
+	"github.com/flonja/multiversion/mapping"
+	"github.com/flonja/multiversion/protocols/latest"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+)
+
+// TestBlockMappingConcurrentAdjust adjusts a block mapping and its clones while they are being looked up from other
+// goroutines. It is meant to be run with the race detector enabled.
+func TestBlockMappingConcurrentAdjust(t *testing.T) {
+	const adjustments = 4
+
+	m := latest.NewBlockMapping()
+	stone := blockupgrader.BlockState{Name: "minecraft:stone", Properties: map[string]any{"stone_type": "stone"}}
+	stoneRID, ok := m.StateToRuntimeID(stone)
+	if !ok {
+		t.Fatalf("stone not found in block mapping")
+	}
+
+	clone := m.Clone()
+	var wg sync.WaitGroup
+	for i := 0; i < adjustments; i++ {
+		wg.Add(3)
+		go func(i int) {
+			defer wg.Done()
+			m.Adjust([]protocol.BlockEntry{{Name: fmt.Sprintf("custom:block_%v", i), Properties: map[string]any{}}})
+		}(i)
+		go func() {
+			defer wg.Done()
+			c := m.Clone()
+			for _, b := range []mapping.Block{m, c} {
+				rid, ok := b.StateToRuntimeID(stone)
+				if !ok {
+					t.Errorf("stone not found while adjusting")
+					return
+				}
+				if state, ok := b.RuntimeIDToState(rid); !ok || state.Name != stone.Name {
+					t.Errorf("runtime ID %v of stone resolved to %v", rid, state.Name)
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if _, ok := m.MatchState("minecraft:stone", nil, nil); !ok {
+				t.Errorf("no state matched for stone while adjusting")
+			}
+			_ = m.Air()
+		}()
+	}
+	wg.Wait()
+
+	for i := 0; i < adjustments; i++ {
+		state := blockupgrader.BlockState{Name: fmt.Sprintf("custom:block_%v", i), Properties: map[string]any{}}
+		if _, ok := m.StateToRuntimeID(state); !ok {
+			t.Errorf("adjustment %v was lost", i)
+		}
+		if _, ok := clone.StateToRuntimeID(state); ok {
+			t.Errorf("adjustment %v affected a clone made before it", i)
+		}
+	}
+	if rid, _ := clone.StateToRuntimeID(stone); rid != stoneRID {
+		t.Errorf("runtime ID of stone in clone changed from %v to %v", stoneRID, rid)
+	}
+}
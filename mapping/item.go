@@ -2,8 +2,12 @@ package mapping
 
 import (
 	"encoding/json"
+	"math"
+	"sync"
+	"sync/atomic"
 
 	"github.com/sandertv/gophertunnel/minecraft/nbt"
+	"golang.org/x/exp/maps"
 )
 
 type Item interface {
@@ -11,23 +15,20 @@ type Item interface {
 	ItemRuntimeIDToName(int32) (string, bool)
 	// ItemNameToRuntimeID converts a string ID to an item runtime ID.
 	ItemNameToRuntimeID(string) (int32, bool)
+	// ItemNames returns the string IDs of all items in the mapping.
+	ItemNames() []string
 	RegisterEntry(name string) int32
 	Air() int32
 	ItemVersion() uint16
 }
 
 type DefaultItemMapping struct {
-	// itemRuntimeIDsToNames holds a map to translate item runtime IDs to string IDs.
-	itemRuntimeIDsToNames map[int32]string
-	// itemNamesToRuntimeIDs holds a map to translate item string IDs to runtime IDs.
-	itemNamesToRuntimeIDs map[string]int32
-	airRID                int32
-	itemVersion           uint16
+	itemTables
+	airRID      int32
+	itemVersion uint16
 }
 
 func NewItemMapping(raw []byte, itemVersion uint16) *DefaultItemMapping {
-	itemRuntimeIDsToNames := make(map[int32]string)
-	itemNamesToRuntimeIDs := make(map[string]int32)
 	var airRID *int32
 
 	var items map[string]int32
@@ -38,33 +39,14 @@ func NewItemMapping(raw []byte, itemVersion uint16) *DefaultItemMapping {
 		if name == "minecraft:air" {
 			airRID = &rid
 		}
-
-		itemNamesToRuntimeIDs[name] = rid
-		itemRuntimeIDsToNames[rid] = name
 	}
 	if airRID == nil {
 		panic("couldn't find air")
 	}
 
-	return &DefaultItemMapping{itemRuntimeIDsToNames: itemRuntimeIDsToNames, itemNamesToRuntimeIDs: itemNamesToRuntimeIDs,
-		itemVersion: itemVersion}
-}
-
-func (m *DefaultItemMapping) ItemRuntimeIDToName(runtimeID int32) (name string, found bool) {
-	name, ok := m.itemRuntimeIDsToNames[runtimeID]
-	return name, ok
-}
-
-func (m *DefaultItemMapping) ItemNameToRuntimeID(name string) (runtimeID int32, found bool) {
-	rid, ok := m.itemNamesToRuntimeIDs[name]
-	return rid, ok
-}
-
-func (m *DefaultItemMapping) RegisterEntry(name string) int32 {
-	nextRID := int32(len(m.itemRuntimeIDsToNames))
-	m.itemNamesToRuntimeIDs[name] = nextRID
-	m.itemRuntimeIDsToNames[nextRID] = name
-	return nextRID
+	m := &DefaultItemMapping{itemVersion: itemVersion}
+	m.table.Store(newItemTable(items))
+	return m
 }
 
 func (m *DefaultItemMapping) Air() int32 {
@@ -76,63 +58,111 @@ func (m *DefaultItemMapping) ItemVersion() uint16 {
 }
 
 type LegacyItemMapping struct {
-	// itemRuntimeIDsToNames holds a map to translate item runtime IDs to string IDs.
-	itemRuntimeIDsToNames map[int32]string
-	// itemNamesToRuntimeIDs holds a map to translate item string IDs to runtime IDs.
-	itemNamesToRuntimeIDs map[string]int32
-	airRID                int32
-	itemVersion           uint16
+	itemTables
+	airRID      int32
+	itemVersion uint16
 }
 
 func NewLegacyItemMapping(raw []byte, itemVersion uint16) *LegacyItemMapping {
-	itemRuntimeIDsToNames := make(map[int32]string)
-	itemNamesToRuntimeIDs := make(map[string]int32)
 	var airRID *int32
 
 	var items map[string]struct {
-		runtime_id      int32
-		component_based bool
+		RuntimeID      int32 `json:"runtime_id"`
+		ComponentBased bool  `json:"component_based"`
 	}
 	if err := json.Unmarshal(raw, &items); err != nil {
 		panic(err)
 	}
+	runtimeIDs := make(map[string]int32, len(items))
 	for name, it := range items {
 		if name == "minecraft:air" {
-			airRID = &it.runtime_id
+			airRID = &it.RuntimeID
 		}
-
-		itemNamesToRuntimeIDs[name] = it.runtime_id
-		itemRuntimeIDsToNames[it.runtime_id] = name
+		runtimeIDs[name] = it.RuntimeID
 	}
 	if airRID == nil {
 		panic("couldn't find air")
 	}
 
-	return &LegacyItemMapping{itemRuntimeIDsToNames: itemRuntimeIDsToNames, itemNamesToRuntimeIDs: itemNamesToRuntimeIDs,
-		itemVersion: itemVersion}
+	m := &LegacyItemMapping{itemVersion: itemVersion}
+	m.table.Store(newItemTable(runtimeIDs))
+	return m
 }
 
-func (m *LegacyItemMapping) ItemRuntimeIDToName(runtimeID int32) (name string, found bool) {
-	name, ok := m.itemRuntimeIDsToNames[runtimeID]
-	return name, ok
+func (m *LegacyItemMapping) Air() int32 {
+	return m.airRID
 }
 
-func (m *LegacyItemMapping) ItemNameToRuntimeID(name string) (runtimeID int32, found bool) {
-	rid, ok := m.itemNamesToRuntimeIDs[name]
-	return rid, ok
+func (m *LegacyItemMapping) ItemVersion() uint16 {
+	return m.itemVersion
 }
 
-func (m *LegacyItemMapping) RegisterEntry(name string) int32 {
-	nextRID := int32(len(m.itemRuntimeIDsToNames))
-	m.itemNamesToRuntimeIDs[name] = nextRID
-	m.itemRuntimeIDsToNames[nextRID] = name
-	return nextRID
+// itemTables holds the lookup tables of an item mapping. Tables are never modified once stored: registering an entry
+// stores a modified copy instead, so the mapping can be read from multiple goroutines without locking.
+type itemTables struct {
+	// table holds the current lookup tables of the mapping.
+	table atomic.Pointer[itemTable]
+	// mu is held while registering entries, so that concurrently registered entries are not lost.
+	mu sync.Mutex
 }
 
-func (m *LegacyItemMapping) Air() int32 {
-	return m.airRID
+// itemTable holds the lookup tables of an item mapping at one point in time.
+type itemTable struct {
+	// names holds the string ID of every item runtime ID, indexed by the runtime ID minus offset. Runtime IDs without
+	// an item hold an empty string.
+	names []string
+	// offset is the lowest runtime ID of the table. Runtime IDs of legacy block items are negative.
+	offset int32
+	// runtimeIDs holds a map to translate item string IDs to runtime IDs.
+	runtimeIDs map[string]int32
 }
 
-func (m *LegacyItemMapping) ItemVersion() uint16 {
-	return m.itemVersion
+// newItemTable creates the lookup tables for the item runtime IDs passed, indexed by their string ID.
+func newItemTable(runtimeIDs map[string]int32) *itemTable {
+	t := &itemTable{runtimeIDs: runtimeIDs}
+	if len(runtimeIDs) == 0 {
+		return t
+	}
+	minRID, maxRID := int32(math.MaxInt32), int32(math.MinInt32)
+	for _, rid := range runtimeIDs {
+		if rid < minRID {
+			minRID = rid
+		}
+		if rid > maxRID {
+			maxRID = rid
+		}
+	}
+	t.offset, t.names = minRID, make([]string, maxRID-minRID+1)
+	for name, rid := range runtimeIDs {
+		t.names[rid-minRID] = name
+	}
+	return t
+}
+
+func (m *itemTables) ItemRuntimeIDToName(runtimeID int32) (name string, found bool) {
+	t := m.table.Load()
+	if i := int64(runtimeID) - int64(t.offset); i >= 0 && i < int64(len(t.names)) && t.names[i] != "" {
+		return t.names[i], true
+	}
+	return "", false
+}
+
+func (m *itemTables) ItemNameToRuntimeID(name string) (runtimeID int32, found bool) {
+	rid, ok := m.table.Load().runtimeIDs[name]
+	return rid, ok
+}
+
+func (m *itemTables) ItemNames() []string {
+	return maps.Keys(m.table.Load().runtimeIDs)
+}
+
+func (m *itemTables) RegisterEntry(name string) int32 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	runtimeIDs := maps.Clone(m.table.Load().runtimeIDs)
+	nextRID := int32(len(runtimeIDs))
+	runtimeIDs[name] = nextRID
+	m.table.Store(newItemTable(runtimeIDs))
+	return nextRID
 }
@@ -0,0 +1,82 @@
+package mapping
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ItemFallback holds the substitutes used for items of the latest version that do not exist in a legacy version. A
+// substitute without a display name is an exact equivalent of the latest item, such as 'minecraft:wool' with the
+// right metadata for 'minecraft:white_wool', and can be reversed by looking it up. A substitute with a display name is
+// merely a stand-in, so the original item has to be remembered elsewhere to be reversed.
+type ItemFallback struct {
+	// substitutes holds the substitute of every missing item, indexed by the name of the latest item.
+	substitutes map[string]ItemSubstitute
+	// equivalents holds the latest item of every exact equivalent, indexed by the legacy name and metadata.
+	equivalents map[itemKey]ItemSubstitute
+}
+
+// ItemSubstitute is a legacy item shown in place of an item that does not exist in the legacy version, or the other
+// way around.
+type ItemSubstitute struct {
+	// Name is the name of the substitute item.
+	Name string `json:"name"`
+	// Metadata is the metadata of the substitute item. If nil, the metadata of the original item is kept.
+	Metadata *uint32 `json:"metadata"`
+	// DisplayName is the name shown to the player for stand-ins, for example 'Brush'.
+	DisplayName string `json:"display_name"`
+}
+
+// itemKey identifies an item by its name and metadata. If any is true, the key matches any metadata.
+type itemKey struct {
+	name     string
+	metadata uint32
+	any      bool
+}
+
+// NewItemFallback decodes the JSON encoded item substitutes passed, indexed by the name of the latest item.
+func NewItemFallback(raw []byte) *ItemFallback {
+	var substitutes map[string]ItemSubstitute
+	if err := json.Unmarshal(raw, &substitutes); err != nil {
+		panic(err)
+	}
+	f := &ItemFallback{substitutes: substitutes, equivalents: make(map[itemKey]ItemSubstitute)}
+	for name, substitute := range substitutes {
+		if substitute.DisplayName != "" {
+			continue
+		}
+		key := itemKey{name: substitute.Name, any: true}
+		if substitute.Metadata != nil {
+			key = itemKey{name: substitute.Name, metadata: *substitute.Metadata}
+		}
+		if _, ok := f.equivalents[key]; ok {
+			panic(fmt.Errorf("%v is used as equivalent of multiple items", substitute.Name))
+		}
+		f.equivalents[key] = ItemSubstitute{Name: name}
+	}
+	return f
+}
+
+// Downgrade returns the substitute of the latest item passed, with the metadata to use.
+func (f *ItemFallback) Downgrade(name string, metadata uint32) (ItemSubstitute, uint32, bool) {
+	substitute, ok := f.substitutes[name]
+	if !ok {
+		return ItemSubstitute{}, 0, false
+	}
+	if substitute.Metadata != nil {
+		metadata = *substitute.Metadata
+	}
+	return substitute, metadata, true
+}
+
+// Upgrade returns the latest item of the legacy item passed if it is an exact equivalent, with the metadata to use.
+// Stand-ins are never returned, as it is impossible to tell which item they were shown for.
+func (f *ItemFallback) Upgrade(name string, metadata uint32) (string, uint32, bool) {
+	if substitute, ok := f.equivalents[itemKey{name: name, metadata: metadata}]; ok {
+		return substitute.Name, 0, true
+	}
+	if substitute, ok := f.equivalents[itemKey{name: name, any: true}]; ok {
+		return substitute.Name, metadata, true
+	}
+	return "", 0, false
+}
@@ -0,0 +1,25 @@
+package mapping
+
+import "encoding/json"
+
+// ItemTags holds the items of the item tags that recipes of the latest version may use instead of a single item, such
+// as 'minecraft:planks'. Versions that do not support item tags need every tag to be resolved to its items.
+type ItemTags struct {
+	// tags holds the names of the items of every tag, indexed by the name of the tag.
+	tags map[string][]string
+}
+
+// NewItemTags decodes the JSON encoded item tags passed, holding the names of the items of every tag.
+func NewItemTags(raw []byte) *ItemTags {
+	var tags map[string][]string
+	if err := json.Unmarshal(raw, &tags); err != nil {
+		panic(err)
+	}
+	return &ItemTags{tags: tags}
+}
+
+// Items returns the names of the items of the tag passed. If the tag is unknown, false is returned.
+func (t *ItemTags) Items(tag string) ([]string, bool) {
+	items, ok := t.tags[tag]
+	return items, ok
+}
@@ -0,0 +1,54 @@
+package mapping_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/flonja/multiversion/protocols/latest"
+)
+
+// TestItemMappingConcurrentRegister registers entries on an item mapping while it is being looked up from other
+// goroutines. It is meant to be run with the race detector enabled.
+func TestItemMappingConcurrentRegister(t *testing.T) {
+	const entries = 32
+
+	m := latest.NewItemMapping()
+	appleRID, ok := m.ItemNameToRuntimeID("minecraft:apple")
+	if !ok {
+		t.Fatalf("apple not found in item mapping")
+	}
+
+	var wg sync.WaitGroup
+	runtimeIDs := make([]int32, entries)
+	for i := 0; i < entries; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			runtimeIDs[i] = m.RegisterEntry(fmt.Sprintf("custom:item_%v", i))
+		}(i)
+		go func() {
+			defer wg.Done()
+			if name, ok := m.ItemRuntimeIDToName(appleRID); !ok || name != "minecraft:apple" {
+				t.Errorf("runtime ID %v of apple resolved to %v", appleRID, name)
+			}
+			if rid, ok := m.ItemNameToRuntimeID("minecraft:apple"); !ok || rid != appleRID {
+				t.Errorf("apple resolved to runtime ID %v instead of %v", rid, appleRID)
+			}
+			_ = m.ItemNames()
+		}()
+	}
+	wg.Wait()
+
+	seen := make(map[int32]struct{}, entries)
+	for i, rid := range runtimeIDs {
+		name := fmt.Sprintf("custom:item_%v", i)
+		if got, ok := m.ItemRuntimeIDToName(rid); !ok || got != name {
+			t.Errorf("runtime ID %v of %v resolved to %v", rid, name, got)
+		}
+		if _, ok := seen[rid]; ok {
+			t.Errorf("runtime ID %v registered twice", rid)
+		}
+		seen[rid] = struct{}{}
+	}
+}
@@ -0,0 +1,80 @@
+package mapping
+
+import (
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+)
+
+// ItemOverlay is an item mapping holding the entries registered for a single connection, such as the custom items sent
+// in its StartGame packet, on top of an item mapping shared by all connections. The shared mapping is never modified.
+type ItemOverlay struct {
+	Item
+	// itemRuntimeIDsToNames holds the runtime IDs registered on the overlay, translated to their string IDs.
+	itemRuntimeIDsToNames map[int32]string
+	// itemNamesToRuntimeIDs holds the string IDs registered on the overlay, translated to their runtime IDs.
+	itemNamesToRuntimeIDs map[string]int32
+	// nextRID is the runtime ID given to the next entry registered.
+	nextRID int32
+}
+
+// NewItemOverlay creates an overlay of the item mapping passed.
+func NewItemOverlay(base Item) *ItemOverlay {
+	return &ItemOverlay{Item: base, itemRuntimeIDsToNames: make(map[int32]string), itemNamesToRuntimeIDs: make(map[string]int32),
+		nextRID: int32(len(base.ItemNames()))}
+}
+
+func (o *ItemOverlay) ItemRuntimeIDToName(runtimeID int32) (string, bool) {
+	if name, ok := o.itemRuntimeIDsToNames[runtimeID]; ok {
+		return name, true
+	}
+	return o.Item.ItemRuntimeIDToName(runtimeID)
+}
+
+func (o *ItemOverlay) ItemNameToRuntimeID(name string) (int32, bool) {
+	if rid, ok := o.itemNamesToRuntimeIDs[name]; ok {
+		return rid, true
+	}
+	return o.Item.ItemNameToRuntimeID(name)
+}
+
+func (o *ItemOverlay) ItemNames() []string {
+	names := o.Item.ItemNames()
+	for name := range o.itemNamesToRuntimeIDs {
+		if _, ok := o.Item.ItemNameToRuntimeID(name); !ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func (o *ItemOverlay) RegisterEntry(name string) int32 {
+	rid := o.nextRID
+	o.nextRID++
+	o.itemNamesToRuntimeIDs[name] = rid
+	o.itemRuntimeIDsToNames[rid] = name
+	return rid
+}
+
+// BlockOverlay is a copy-on-write overlay of a block mapping shared by all connections. It reads from the shared
+// mapping until it is adjusted for the custom states of a single connection, after which it reads from an adjusted
+// copy. The shared mapping is never modified.
+type BlockOverlay struct {
+	Block
+	// copied is true once the shared mapping has been replaced with a copy.
+	copied bool
+}
+
+// NewBlockOverlay creates an overlay of the block mapping passed.
+func NewBlockOverlay(base Block) *BlockOverlay {
+	return &BlockOverlay{Block: base}
+}
+
+func (o *BlockOverlay) Adjust(entries []protocol.BlockEntry) {
+	if !o.copied {
+		o.Block, o.copied = o.Block.Clone(), true
+	}
+	o.Block.Adjust(entries)
+}
+
+func (o *BlockOverlay) Clone() Block {
+	return &BlockOverlay{Block: o.Block, copied: false}
+}
@@ -0,0 +1,136 @@
+package chain
+
+import (
+	"github.com/flonja/multiversion/translator"
+	"github.com/sandertv/gophertunnel/minecraft"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// Step converts packets between a protocol and the next newer protocol. A step only handles the packets that changed
+// between the two protocols, and passes all other packets on untouched.
+type Step interface {
+	// Upgrade converts a packet of the older protocol to packets of the newer protocol.
+	Upgrade(pk packet.Packet, conn *minecraft.Conn) []packet.Packet
+	// Downgrade converts a packet of the newer protocol to packets of the older protocol.
+	Downgrade(pk packet.Packet, conn *minecraft.Conn) []packet.Packet
+}
+
+// ChainedProtocol is a minecraft.Protocol that converts packets to and from the latest version by passing them through
+// a chain of steps, each converting between two adjacent protocols. All other methods are those of the protocol it
+// was created with.
+type ChainedProtocol struct {
+	minecraft.Protocol
+	// steps holds the steps of the chain, from the oldest protocol to the newest.
+	steps []Step
+	// filter drops the packets that do not exist in the oldest protocol, if set.
+	filter *translator.PacketFilter
+}
+
+// NewChainedProtocol creates a chained protocol for the protocol passed. The steps are ordered from the oldest
+// protocol to the newest, with the last step producing packets of the latest version.
+func NewChainedProtocol(proto minecraft.Protocol, steps ...Step) *ChainedProtocol {
+	return &ChainedProtocol{Protocol: proto, steps: steps}
+}
+
+// WithPacketFilter sets the packet filter used to drop the packets that do not exist in the oldest protocol of the
+// chain. Packets are filtered before being upgraded and after being downgraded, and are emulated before being
+// downgraded.
+func (p *ChainedProtocol) WithPacketFilter(filter *translator.PacketFilter) *ChainedProtocol {
+	p.filter = filter
+	return p
+}
+
+func (p *ChainedProtocol) ConvertToLatest(pk packet.Packet, conn *minecraft.Conn) []packet.Packet {
+	pks := []packet.Packet{pk}
+	if p.filter != nil {
+		pks = p.filter.UpgradePackets(pks, conn)
+	}
+	for _, step := range p.steps {
+		pks = convert(pks, conn, step.Upgrade)
+	}
+	if p.filter != nil {
+		pks = p.filter.ConsumePackets(pks, conn)
+	}
+	return pks
+}
+
+func (p *ChainedProtocol) ConvertFromLatest(pk packet.Packet, conn *minecraft.Conn) []packet.Packet {
+	pks := []packet.Packet{pk}
+	if p.filter != nil {
+		pks = p.filter.EmulatePackets(pks, conn)
+	}
+	for i := len(p.steps) - 1; i >= 0; i-- {
+		pks = convert(pks, conn, p.steps[i].Downgrade)
+	}
+	if p.filter != nil {
+		pks = p.filter.DowngradePackets(pks, conn)
+	}
+	return pks
+}
+
+// convert passes every packet through the conversion function passed and collects the results.
+func convert(pks []packet.Packet, conn *minecraft.Conn, f func(pk packet.Packet, conn *minecraft.Conn) []packet.Packet) []packet.Packet {
+	result := make([]packet.Packet, 0, len(pks))
+	for _, pk := range pks {
+		result = append(result, f(pk, conn)...)
+	}
+	return result
+}
+
+// TranslatorStep is the last step of a chain, translating the items, blocks, entities, sounds and particles held by
+// packets of the latest version between the oldest protocol of the chain and the latest version. Items and blocks are
+// translated using the context of the connection, and blobs of the client cache are translated by the blob cache.
+// Protocols that do not support the client cache leave the blob cache nil, and protocols without sub chunk requesting
+// or server authoritative inventories set the sub chunk and inventory bridges.
+type TranslatorStep struct {
+	Contexts    *translator.Contexts
+	Blobs       *translator.BlobCache
+	Entities    translator.EntityTranslator
+	Sounds      translator.SoundTranslator
+	Particles   translator.ParticleTranslator
+	SubChunks   *translator.SubChunkBridge
+	Inventories *translator.InventoryBridge
+}
+
+func (s TranslatorStep) Upgrade(pk packet.Packet, conn *minecraft.Conn) []packet.Packet {
+	ctx := s.Contexts.Context(pk, conn)
+	pks := ctx.Blocks.UpgradeBlockPackets(ctx.Items.UpgradeItemPackets([]packet.Packet{pk}, conn), conn)
+	if s.Blobs != nil {
+		pks = s.Blobs.UpgradeBlobPackets(pks, conn)
+	}
+	if s.Inventories != nil {
+		// Inventory transactions are bridged to item stack requests after their items were upgraded.
+		pks = s.Inventories.UpgradeInventoryPackets(pks, conn)
+	}
+	if s.SubChunks != nil {
+		pks = s.SubChunks.UpgradeChunkPackets(pks, conn)
+	}
+	return pks
+}
+
+func (s TranslatorStep) Downgrade(pk packet.Packet, conn *minecraft.Conn) []packet.Packet {
+	ctx := s.Contexts.Context(pk, conn)
+	pks := []packet.Packet{pk}
+	if s.Blobs != nil {
+		pks = s.Blobs.DowngradeBlobPackets(pks, ctx, conn)
+	}
+	if s.SubChunks != nil {
+		// Sub chunks are merged into full chunks before their blocks are downgraded.
+		pks = s.SubChunks.DowngradeChunkPackets(pks, ctx, conn)
+	}
+	if s.Entities != nil {
+		pks = s.Entities.DowngradeEntityPackets(pks, conn)
+	}
+	if s.Sounds != nil {
+		pks = s.Sounds.DowngradeSoundPackets(pks, conn)
+	}
+	if s.Inventories != nil {
+		pks = s.Inventories.DowngradeInventoryPackets(pks, conn)
+	}
+	pks = ctx.Blocks.DowngradeBlockPackets(ctx.Items.DowngradeItemPackets(pks, conn), conn)
+	if s.Particles != nil {
+		// Particles are translated last, as the item and block translators recognise particles by their latest types.
+		pks = s.Particles.DowngradeParticlePackets(pks, conn)
+	}
+	return pks
+}
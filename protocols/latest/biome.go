@@ -0,0 +1,15 @@
+package latest
+
+import (
+	_ "embed"
+	"github.com/flonja/multiversion/mapping"
+)
+
+var (
+	//go:embed biome_id_map.json
+	biomeIDData []byte
+)
+
+func NewBiomeMapping() *mapping.DefaultBiomeMapping {
+	return mapping.NewBiomeMapping(biomeIDData)
+}
@@ -0,0 +1,15 @@
+package latest
+
+import (
+	_ "embed"
+	"github.com/flonja/multiversion/mapping"
+)
+
+var (
+	//go:embed item_tags.json
+	itemTagData []byte
+)
+
+func NewItemTags() *mapping.ItemTags {
+	return mapping.NewItemTags(itemTagData)
+}
@@ -0,0 +1,31 @@
+package latest
+
+// Particle types of the latest version, as sent with the legacy particle level event. Only the particle types that
+// are used to translate particles for legacy versions are listed.
+const (
+	ParticleCritical            = 3
+	ParticleSmoke               = 5
+	ParticleFlame               = 8
+	ParticleCandleFlame         = 9
+	ParticleRedstone            = 12
+	ParticleSnowballPoof        = 15
+	ParticleHugeExplode         = 16
+	ParticleDripWater           = 27
+	ParticleDripLava            = 28
+	ParticleStalactiteDripWater = 30
+	ParticleStalactiteDripLava  = 31
+	ParticleVillagerHappy       = 39
+	ParticleSoul                = 71
+	ParticlePortalReverse       = 73
+	ParticleSnowflake           = 74
+	ParticleVibrationSignal     = 75
+	ParticleSculkSensorRedstone = 76
+	ParticleSporeBlossomShower  = 77
+	ParticleSporeBlossomAmbient = 78
+	ParticleWax                 = 79
+	ParticleElectricSpark       = 80
+	ParticleShriek              = 81
+	ParticleSculkSoul           = 82
+	ParticleSonicExplosion      = 83
+	ParticleDustPlume           = 84
+)
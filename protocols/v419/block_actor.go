@@ -0,0 +1,16 @@
+package v419
+
+import (
+	"github.com/flonja/multiversion/translator"
+)
+
+// blockActorRules holds the differences between the block actors of 1.16.100 and those of other legacy versions.
+var blockActorRules = translator.LegacyBlockActorRules{
+	IDs: map[string]string{
+		"GlowItemFrame":         "ItemFrame",
+		"SculkSensor":           "DaylightDetector",
+		"CalibratedSculkSensor": "DaylightDetector",
+		"SculkShrieker":         "DaylightDetector",
+	},
+	SignFields: []string{"TextOwner"},
+}
@@ -1,39 +1,9 @@
 package v419
 
 import (
-	"math"
-
-	"github.com/flonja/multiversion/mapping"
-	"github.com/flonja/multiversion/protocols/v486/types"
 	"github.com/sandertv/gophertunnel/minecraft/protocol"
 )
 
-// / downgradeBlockActorData downgrades a block actor from latest version to legacy version.
-func downgradeBlockActorData(data map[string]any) map[string]any {
-	switch data["id"] {
-	case "Sign":
-		delete(data, "BackText")
-		frontRaw, ok := data["FrontText"]
-		if !ok {
-			frontRaw = map[string]any{"Text": ""}
-		}
-		front, ok := frontRaw.(map[string]any)
-		if !ok {
-			front = map[string]any{"Text": ""}
-		}
-		textRaw, ok := front["Text"]
-		if !ok {
-			textRaw = ""
-		}
-		text, ok := textRaw.(string)
-		if !ok {
-			text = ""
-		}
-		data["Text"] = text
-	}
-	return data
-}
-
 // downgradeEntityMetadata downgrades entity metadata from latest version to legacy version.
 func downgradeEntityMetadata(data map[uint32]any) map[uint32]any {
 	newData := make(map[uint32]any)
@@ -86,7 +56,7 @@ func downgradeEntityMetadata(data map[uint32]any) map[uint32]any {
 		case protocol.EntityDataKeyEatingCounter:
 			key = 90
 		case protocol.EntityDataKeyFlagsTwo:
-			key = 91
+			key = entityDataKeyFlagsTwo
 		case protocol.EntityDataKeyDataDuration:
 			key = 94
 		case protocol.EntityDataKeyDataSpawnTime:
@@ -121,57 +91,23 @@ func downgradeEntityMetadata(data map[uint32]any) map[uint32]any {
 		newData[key] = value
 	}
 
-	var flag1, flag2 int64
-	if v, ok := newData[protocol.EntityDataKeyFlags]; ok {
-		flag1 = v.(int64)
-	}
-	if v, ok := newData[protocol.EntityDataKeyFlagsTwo]; ok {
-		flag2 = v.(int64)
-	}
-	if flag1 == 0 && flag2 == 0 {
-		return newData
-	}
-
-	newFlag1 := flag1 & ^(^0 << (protocol.EntityDataFlagDash - 1))
-	lastHalf := flag1 & (^0 << protocol.EntityDataFlagDash)
-	lastHalf >>= 1
-	lastHalf &= math.MaxInt64
-
-	newFlag1 |= lastHalf
-
-	if flag2 != 0 {
-		newFlag1 ^= (flag2 & 1) << 63
-		flag2 >>= 1
-		flag2 &= math.MaxInt64
-
-		newData[protocol.EntityDataKeyFlagsTwo] = flag2
-	}
-
-	newData[protocol.EntityDataKeyFlags] = newFlag1
-	return newData
+	return downgradeEntityFlags(newData)
 }
 
-func downgradeCraftingDescription(descriptor protocol.ItemDescriptor, m mapping.Item) protocol.ItemDescriptor {
-	var networkId int32
-	var metadata int32
-	switch descriptor := descriptor.(type) {
-	case *protocol.DefaultItemDescriptor:
-		networkId = int32(descriptor.NetworkID)
-		metadata = int32(descriptor.MetadataValue)
-	case *protocol.DeferredItemDescriptor:
-		if rid, ok := m.ItemNameToRuntimeID(descriptor.Name); ok {
-			networkId = rid
-			metadata = int32(descriptor.MetadataValue)
-		}
-	case *protocol.ItemTagItemDescriptor:
-		/// ?????
-	case *protocol.ComplexAliasItemDescriptor:
-		/// ?????
+// downgradeEntityFlags downgrades the entity flags held in legacy entity metadata from latest version to legacy
+// version.
+func downgradeEntityFlags(data map[uint32]any) map[uint32]any {
+	flags, hasFlags := data[protocol.EntityDataKeyFlags].(int64)
+	flagsTwo, hasFlagsTwo := data[entityDataKeyFlagsTwo].(int64)
+	if !hasFlags && !hasFlagsTwo {
+		return data
+	}
+	flags, flagsTwo = entityFlags.DowngradeEntityFlags(flags, flagsTwo)
+	if hasFlags || flags != 0 {
+		data[protocol.EntityDataKeyFlags] = flags
 	}
-	return &types.DefaultItemDescriptor{
-		NetworkID:     networkId,
-		MetadataValue: metadata,
+	if hasFlagsTwo || flagsTwo != 0 {
+		data[entityDataKeyFlagsTwo] = flagsTwo
 	}
+	return data
 }
-
-// TODO: add downgrade entity flags
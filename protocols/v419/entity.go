@@ -0,0 +1,45 @@
+package v419
+
+import (
+	"github.com/flonja/multiversion/translator"
+	"github.com/samber/lo"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+)
+
+// entityDataKeyFlagsTwo is the legacy key of the second entity flag bitfield.
+const entityDataKeyFlagsTwo = 91
+
+// entityFlags translates entity flags between the latest version and 1.16.100. The dash flag was added after
+// 1.16.100, as were all flags following the special celebration flag.
+var entityFlags = translator.NewEntityFlagTranslator(lo.Without(
+	lo.RangeFrom[uint32](0, protocol.EntityDataFlagCelebratingSpecial+1),
+	protocol.EntityDataFlagDash,
+))
+
+// unknownEntityTypes holds the entity types of the latest version that were added after 1.16.100.
+var unknownEntityTypes = []string{
+	"minecraft:allay",
+	"minecraft:axolotl",
+	"minecraft:camel",
+	"minecraft:chest_boat",
+	"minecraft:frog",
+	"minecraft:glow_squid",
+	"minecraft:goat",
+	"minecraft:sniffer",
+	"minecraft:tadpole",
+	"minecraft:warden",
+}
+
+// entitySubstitutes holds the entities shown to 1.16.100 clients in place of entity types that were added later on.
+var entitySubstitutes = map[string]translator.EntitySubstitute{
+	"minecraft:allay":      {EntityType: "minecraft:vex"},
+	"minecraft:axolotl":    {EntityType: "minecraft:salmon"},
+	"minecraft:camel":      {EntityType: "minecraft:horse", Scale: 1.4},
+	"minecraft:chest_boat": {EntityType: "minecraft:boat"},
+	"minecraft:frog":       {EntityType: "minecraft:rabbit"},
+	"minecraft:glow_squid": {EntityType: "minecraft:squid"},
+	"minecraft:goat":       {EntityType: "minecraft:sheep"},
+	"minecraft:sniffer":    {EntityType: "minecraft:ravager", Scale: 0.8},
+	"minecraft:tadpole":    {EntityType: "minecraft:tropicalfish", Scale: 0.5},
+	"minecraft:warden":     {EntityType: "minecraft:iron_golem", Scale: 1.05},
+}
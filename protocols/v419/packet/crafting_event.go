@@ -1,7 +1,6 @@
 package packet
 
 import (
-	"github.com/flonja/multiversion/protocols/v419/types"
 	"github.com/google/uuid"
 	"github.com/sandertv/gophertunnel/minecraft/protocol"
 	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
@@ -21,9 +20,9 @@ type CraftingEvent struct {
 	RecipeUUID uuid.UUID
 	// Input is a list of items that the player put into the recipe so that it could create the Output items.
 	// These items are consumed in the process.
-	Input []types.ItemStack
+	Input []protocol.ItemStack
 	// Output is a list of items that were obtained as a result of crafting the recipe.
-	Output []types.ItemStack
+	Output []protocol.ItemStack
 }
 
 // ID ...
@@ -33,16 +32,9 @@ func (*CraftingEvent) ID() uint32 {
 
 // Marshal ...
 func (pk *CraftingEvent) Marshal(w protocol.IO) {
-	inputLen, outputLen := uint32(len(pk.Input)), uint32(len(pk.Output))
 	w.Uint8(&pk.WindowID)
 	w.Varint32(&pk.CraftingType)
 	w.UUID(&pk.RecipeUUID)
-	w.Varuint32(&inputLen)
-	for _, input := range pk.Input {
-		types.WriteItem(w, &input)
-	}
-	w.Varuint32(&outputLen)
-	for _, output := range pk.Output {
-		types.WriteItem(w, &output)
-	}
+	protocol.FuncSlice(w, &pk.Input, w.Item)
+	protocol.FuncSlice(w, &pk.Output, w.Item)
 }
@@ -1,6 +1,7 @@
 package packet
 
 import (
+	"github.com/flonja/multiversion/protocols/v419/types"
 	"github.com/sandertv/gophertunnel/minecraft/protocol"
 	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
 )
@@ -36,12 +37,12 @@ type InventoryTransaction struct {
 	// these actions hold one slot in which one item was changed to another. In general, the combination of
 	// all of these actions results in a balanced inventory transaction. This should be checked to ensure that
 	// no items are cheated into the inventory.
-	// Actions []types.InventoryAction
-	// // TransactionData is a data object that holds data specific to the type of transaction that the
-	// // TransactionPacket held. Its concrete type must be one of NormalTransactionData, MismatchTransactionData
-	// // UseItemTransactionData, UseItemOnEntityTransactionData or ReleaseItemTransactionData. If nil is set,
-	// // the transaction will be assumed to of type InventoryTransactionTypeNormal.
-	// TransactionData types.InventoryTransactionData
+	Actions []types.InventoryAction
+	// TransactionData is a data object that holds data specific to the type of transaction that the
+	// TransactionPacket held. Its concrete type must be one of NormalTransactionData, MismatchTransactionData
+	// UseItemTransactionData, UseItemOnEntityTransactionData or ReleaseItemTransactionData. If nil is set,
+	// the transaction will be assumed to of type InventoryTransactionTypeNormal.
+	TransactionData types.InventoryTransactionData
 }
 
 // ID ...
@@ -51,31 +52,58 @@ func (*InventoryTransaction) ID() uint32 {
 
 // Marshal ...
 func (pk *InventoryTransaction) Marshal(w protocol.IO) {
-	// w.Varint32(&pk.LegacyRequestID)
-	// if pk.LegacyRequestID != 0 {
-	// 	protocol.FuncSlice(w, &pk.LegacySetItemSlots, func(slot *protocol.LegacySetItemSlot) {
-	// 		slot.Marshal(w)
-	// 	})
-	// }
-	// var id uint32
-	// switch pk.TransactionData.(type) {
-	// case nil, *types.NormalTransactionData:
-	// 	id = InventoryTransactionTypeNormal
-	// case *types.MismatchTransactionData:
-	// 	id = InventoryTransactionTypeMismatch
-	// case *types.UseItemTransactionData:
-	// 	id = InventoryTransactionTypeUseItem
-	// case *types.UseItemOnEntityTransactionData:
-	// 	id = InventoryTransactionTypeUseItemOnEntity
-	// case *types.ReleaseItemTransactionData:
-	// 	id = InventoryTransactionTypeReleaseItem
-	// }
-	// w.Varuint32(&id)
-	// w.Bool(&pk.HasNetworkIDs)
-	// protocol.FuncSlice(w, &pk.Actions, func(action *types.InventoryAction) {
-	// 	action.Marshal(w, pk.HasNetworkIDs)
-	// })
-	// if pk.TransactionData != nil {
-	// 	pk.TransactionData.Marshal(w)
-	// }
+	w.Varint32(&pk.LegacyRequestID)
+	if pk.LegacyRequestID != 0 {
+		protocol.Slice(w, &pk.LegacySetItemSlots)
+	}
+	id := uint32(InventoryTransactionTypeNormal)
+	lookupTransactionDataType(pk.TransactionData, &id)
+	w.Varuint32(&id)
+	if pk.TransactionData == nil && !lookupTransactionData(id, &pk.TransactionData) {
+		w.UnknownEnumOption(id, "inventory transaction data type")
+		return
+	}
+	w.Bool(&pk.HasNetworkIDs)
+	protocol.FuncSlice(w, &pk.Actions, func(action *types.InventoryAction) {
+		action.Marshal(w, pk.HasNetworkIDs)
+	})
+	pk.TransactionData.Marshal(w)
+}
+
+// lookupTransactionData looks up inventory transaction data for the ID passed.
+func lookupTransactionData(id uint32, x *types.InventoryTransactionData) bool {
+	switch id {
+	case InventoryTransactionTypeNormal:
+		*x = &types.NormalTransactionData{}
+	case InventoryTransactionTypeMismatch:
+		*x = &types.MismatchTransactionData{}
+	case InventoryTransactionTypeUseItem:
+		*x = &types.UseItemTransactionData{}
+	case InventoryTransactionTypeUseItemOnEntity:
+		*x = &types.UseItemOnEntityTransactionData{}
+	case InventoryTransactionTypeReleaseItem:
+		*x = &types.ReleaseItemTransactionData{}
+	default:
+		return false
+	}
+	return true
+}
+
+// lookupTransactionDataType looks up the ID of the inventory transaction data passed.
+func lookupTransactionDataType(x types.InventoryTransactionData, id *uint32) bool {
+	switch x.(type) {
+	case *types.NormalTransactionData:
+		*id = InventoryTransactionTypeNormal
+	case *types.MismatchTransactionData:
+		*id = InventoryTransactionTypeMismatch
+	case *types.UseItemTransactionData:
+		*id = InventoryTransactionTypeUseItem
+	case *types.UseItemOnEntityTransactionData:
+		*id = InventoryTransactionTypeUseItemOnEntity
+	case *types.ReleaseItemTransactionData:
+		*id = InventoryTransactionTypeReleaseItem
+	default:
+		return false
+	}
+	return true
 }
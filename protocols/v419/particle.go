@@ -0,0 +1,98 @@
+package v419
+
+import (
+	"github.com/flonja/multiversion/protocols/latest"
+	"github.com/flonja/multiversion/translator"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// fallbackParticle is the particle shown to 1.16.100 clients in place of particles that have no equivalent.
+const fallbackParticle = "minecraft:basic_smoke_particle"
+
+// particleTypes holds the particle types shown to 1.16.100 clients in place of particle types that were added later
+// on, as particle types of the latest version. The first particle type added after 1.16.100 at the end of the list is
+// the snowflake particle, but the candle flame and stalactite drip particles were inserted in between, renumbering
+// the particle types after them.
+var particleTypes = map[int32]int32{
+	latest.ParticleCandleFlame:         latest.ParticleFlame,
+	latest.ParticleStalactiteDripWater: latest.ParticleDripWater,
+	latest.ParticleStalactiteDripLava:  latest.ParticleDripLava,
+	latest.ParticleSnowflake:           latest.ParticleSnowballPoof,
+	latest.ParticleSculkSensorRedstone: latest.ParticleRedstone,
+	latest.ParticleWax:                 latest.ParticleVillagerHappy,
+	latest.ParticleElectricSpark:       latest.ParticleCritical,
+	latest.ParticleSculkSoul:           latest.ParticleSoul,
+	latest.ParticleSonicExplosion:      latest.ParticleHugeExplode,
+}
+
+// particleNames holds the particle names shown to 1.16.100 clients in place of particle names that were added later
+// on. Particles without an equivalent are replaced with the fallback particle.
+var particleNames = map[string]string{
+	"minecraft:cherry_leaves_particle":         "",
+	"minecraft:dust_plume":                     "",
+	"minecraft:sculk_charge_particle":          "",
+	"minecraft:sculk_charge_pop_particle":      "",
+	"minecraft:shriek_particle":                "",
+	"minecraft:vibration_signal":               "",
+	"minecraft:spore_blossom_shower_particle":  "",
+	"minecraft:spore_blossom_ambient_particle": "",
+	"minecraft:electric_spark_particle":        "minecraft:critical_hit_emitter",
+	"minecraft:wax_particle":                   "minecraft:villager_happy",
+	"minecraft:candle_flame_particle":          "minecraft:basic_flame_particle",
+	"minecraft:stalactite_lava_drip_particle":  "minecraft:lava_drip_particle",
+	"minecraft:stalactite_water_drip_particle": "minecraft:water_drip_particle",
+	"minecraft:snowflake_particle":             "minecraft:snowball_poof_particle",
+	"minecraft:sculk_soul_particle":            "minecraft:soul_particle",
+	"minecraft:sonic_explosion":                "minecraft:huge_explosion_emitter",
+}
+
+// levelEvents holds the level events sent to 1.16.100 clients in place of level events that were added later on.
+// Level events without an equivalent are not sent.
+var levelEvents = map[int32]int32{
+	packet.LevelEventParticlesVibrationSignal: 0,
+	packet.LevelEventParticlesDripstoneDrip:   0,
+	packet.LevelEventParticlesFizzEffect:      packet.LevelEventParticlesEvaporate,
+	packet.LevelEventWaxOn:                    0,
+	packet.LevelEventWaxOff:                   0,
+	packet.LevelEventScrape:                   0,
+	packet.LevelEventParticlesElectricSpark:   0,
+	packet.LevelEventParticleTurtleEgg:        0,
+	packet.LevelEventParticleSculkShriek:      0,
+	packet.LevelEventSculkCatalystBloom:       0,
+	packet.LevelEventSculkCharge:              0,
+	packet.LevelEventSculkChargePop:           0,
+	packet.LevelEventSonicExplosion:           packet.LevelEventParticlesExplosion,
+}
+
+// legacyParticleType returns the type of a particle in 1.16.100 from its type in the latest version. The particle
+// type passed must exist in 1.16.100.
+func legacyParticleType(particleType int32) int32 {
+	switch {
+	case particleType > latest.ParticleStalactiteDripLava:
+		return particleType - 3
+	case particleType > latest.ParticleCandleFlame:
+		return particleType - 1
+	}
+	return particleType
+}
+
+// newParticleTranslator creates the particle translator translating particles between the latest version and
+// 1.16.100.
+func newParticleTranslator() *translator.DefaultParticleTranslator {
+	t := translator.NewParticleTranslator(latest.ParticleSnowflake, fallbackParticle, legacyParticleType(latest.ParticleSmoke))
+	for particleType, substitute := range particleTypes {
+		t.RegisterType(particleType, legacyParticleType(substitute))
+	}
+	for particleType := int32(1); particleType < latest.ParticleSnowflake; particleType++ {
+		if _, ok := particleTypes[particleType]; !ok && legacyParticleType(particleType) != particleType {
+			t.RegisterType(particleType, legacyParticleType(particleType))
+		}
+	}
+	for name, substitute := range particleNames {
+		t.RegisterName(name, substitute)
+	}
+	for eventType, substitute := range levelEvents {
+		t.RegisterLevelEvent(eventType, substitute)
+	}
+	return t
+}
@@ -0,0 +1,706 @@
+package v419
+
+import (
+	"fmt"
+	"image/color"
+
+	legacypacket "github.com/flonja/multiversion/protocols/v419/packet"
+	"github.com/flonja/multiversion/protocols/v419/types"
+	legacypacket_v486 "github.com/flonja/multiversion/protocols/v486/packet"
+	types_v486 "github.com/flonja/multiversion/protocols/v486/types"
+	legacypacket_v589 "github.com/flonja/multiversion/protocols/v589/packet"
+	types_v589 "github.com/flonja/multiversion/protocols/v589/types"
+	"github.com/samber/lo"
+	"github.com/sandertv/gophertunnel/minecraft"
+	"github.com/sandertv/gophertunnel/minecraft/nbt"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// Step converts packets between v419 and v486.
+type Step struct {
+	// clientAuthoritativeInventory makes clients change their inventories themselves, even if the server enables
+	// server authoritative inventories.
+	clientAuthoritativeInventory bool
+}
+
+// NewStep creates a step converting packets between v419 and v486.
+func NewStep() *Step {
+	return &Step{}
+}
+
+func (*Step) Upgrade(pk packet.Packet, _ *minecraft.Conn) []packet.Packet {
+	switch pk := pk.(type) {
+	case *legacypacket_v589.AvailableCommands:
+		for ind1, command := range pk.Commands {
+			for ind2, overload := range command.Overloads {
+				for ind3, parameter := range overload.Parameters {
+					parameterType := uint32(0)
+					switch parameter.Type {
+					case 7:
+						parameterType = protocol.CommandArgTypeTarget
+					case 8:
+						parameterType = protocol.CommandArgTypeWildcardTarget
+					case 16:
+						parameterType = protocol.CommandArgTypeFilepath
+					case 32:
+						parameterType = protocol.CommandArgTypeString
+					case 40:
+						parameterType = protocol.CommandArgTypePosition
+					case 44:
+						parameterType = protocol.CommandArgTypeMessage
+					case 46:
+						parameterType = protocol.CommandArgTypeRawText
+					case 50:
+						parameterType = protocol.CommandArgTypeJSON
+					case 63:
+						parameterType = protocol.CommandArgTypeCommand
+					}
+					parameter.Type = parameterType
+					pk.Commands[ind1].Overloads[ind2].Parameters[ind3] = parameter
+				}
+			}
+		}
+		return []packet.Packet{pk}
+	case *legacypacket.ActorPickRequest:
+		return []packet.Packet{&packet.ActorPickRequest{
+			EntityUniqueID: pk.EntityUniqueID,
+			HotBarSlot:     pk.HotBarSlot,
+		}}
+	case *legacypacket.CraftingEvent:
+		return []packet.Packet{&packet.CraftingEvent{
+			WindowID:     pk.WindowID,
+			CraftingType: pk.CraftingType,
+			RecipeUUID:   pk.RecipeUUID,
+			Input: lo.Map(pk.Input, func(it protocol.ItemStack, _ int) protocol.ItemInstance {
+				return protocol.ItemInstance{Stack: it}
+			}),
+			Output: lo.Map(pk.Output, func(it protocol.ItemStack, _ int) protocol.ItemInstance {
+				return protocol.ItemInstance{Stack: it}
+			}),
+		}}
+	case *legacypacket.InventoryTransaction:
+		return []packet.Packet{&packet.InventoryTransaction{
+			LegacyRequestID:    pk.LegacyRequestID,
+			LegacySetItemSlots: pk.LegacySetItemSlots,
+			Actions: lo.Map(pk.Actions, func(action types.InventoryAction, _ int) protocol.InventoryAction {
+				return upgradeInventoryAction(action)
+			}),
+			TransactionData: upgradeTransactionData(pk.TransactionData),
+		}}
+	case *legacypacket.ItemStackRequest:
+		return []packet.Packet{&legacypacket_v486.ItemStackRequest{
+			Requests: lo.Map(pk.Requests, func(request types.ItemStackRequest, _ int) types_v486.ItemStackRequest {
+				return types_v486.ItemStackRequest{ItemStackRequest: request.ItemStackRequest}
+			}),
+		}}
+	case *legacypacket.CommandRequest:
+		return []packet.Packet{&legacypacket_v486.CommandRequest{
+			CommandLine:   pk.CommandLine,
+			CommandOrigin: pk.CommandOrigin,
+			Internal:      pk.Internal,
+		}}
+	case *legacypacket.MapInfoRequest:
+		return []packet.Packet{&packet.MapInfoRequest{
+			MapID: pk.MapID,
+		}}
+	case *legacypacket.MobEquipment:
+		return []packet.Packet{&packet.MobEquipment{
+			EntityRuntimeID: pk.EntityRuntimeID,
+			NewItem: protocol.ItemInstance{
+				StackNetworkID: pk.NewItem.NetworkID,
+				Stack: protocol.ItemStack{
+					ItemType: protocol.ItemType{
+						NetworkID:     pk.NewItem.NetworkID,
+						MetadataValue: uint32(pk.NewItem.MetadataValue),
+					},
+					Count:         uint16(pk.NewItem.Count),
+					NBTData:       pk.NewItem.NBTData,
+					CanBePlacedOn: pk.NewItem.CanBePlacedOn,
+					CanBreak:      pk.NewItem.CanBreak,
+					HasNetworkID:  pk.NewItem.NetworkID != 0,
+				},
+			},
+		}}
+	case *legacypacket.ModalFormResponse:
+		return []packet.Packet{&legacypacket_v486.ModalFormResponse{
+			FormID:       pk.FormID,
+			ResponseData: pk.ResponseData,
+		}}
+	case *legacypacket.NPCRequest:
+		return []packet.Packet{&packet.NPCRequest{
+			EntityRuntimeID: pk.EntityRuntimeID,
+			RequestType:     pk.RequestType,
+			CommandString:   pk.CommandString,
+			ActionType:      pk.ActionType,
+		}}
+	case *legacypacket.PlayerAction:
+		return []packet.Packet{&legacypacket_v486.PlayerAction{
+			EntityRuntimeID: pk.EntityRuntimeID,
+			ActionType:      pk.ActionType,
+			BlockPosition:   pk.BlockPosition,
+			BlockFace:       pk.BlockFace,
+		}}
+	case *legacypacket.PlayerAuthInput:
+		return []packet.Packet{&legacypacket_v486.PlayerAuthInput{
+			Pitch:         pk.Pitch,
+			Yaw:           pk.Yaw,
+			Position:      pk.Position,
+			MoveVector:    pk.MoveVector,
+			HeadYaw:       pk.HeadYaw,
+			InputData:     pk.InputData,
+			InputMode:     pk.InputMode,
+			PlayMode:      pk.PlayMode,
+			GazeDirection: pk.GazeDirection,
+			Tick:          pk.Tick,
+			Delta:         pk.Delta,
+		}}
+	case *legacypacket.PlayerSkin:
+		return []packet.Packet{&legacypacket_v486.PlayerSkin{
+			UUID:        pk.UUID,
+			Skin:        types_v486.Skin{Skin: types.LatestSkin(pk.Skin)},
+			NewSkinName: pk.NewSkinName,
+			OldSkinName: pk.OldSkinName,
+		}}
+	case *legacypacket.AddActor:
+		return []packet.Packet{&legacypacket_v486.AddActor{
+			EntityUniqueID:  pk.EntityUniqueID,
+			EntityRuntimeID: pk.EntityRuntimeID,
+			EntityType:      pk.EntityType,
+			Position:        pk.Position,
+			Velocity:        pk.Velocity,
+			Pitch:           pk.Pitch,
+			Yaw:             pk.Yaw,
+			HeadYaw:         pk.HeadYaw,
+			Attributes: lo.Map(pk.Attributes, func(a types.Attribute, _ int) protocol.AttributeValue {
+				return protocol.AttributeValue{
+					Name:  a.Name,
+					Value: a.Value,
+					Max:   a.Max,
+					Min:   a.Min,
+				}
+			}),
+			EntityMetadata: upgradeEntityMetadata(pk.EntityMetadata),
+			EntityLinks:    pk.EntityLinks,
+		}}
+	case *legacypacket.AddPlayer:
+		return []packet.Packet{&legacypacket_v486.AddPlayer{
+			UUID:            pk.UUID,
+			Username:        pk.Username,
+			EntityUniqueID:  pk.EntityUniqueID,
+			EntityRuntimeID: pk.EntityRuntimeID,
+			PlatformChatID:  pk.PlatformChatID,
+			Position:        pk.Position,
+			Velocity:        pk.Velocity,
+			Pitch:           pk.Pitch,
+			Yaw:             pk.Yaw,
+			HeadYaw:         pk.HeadYaw,
+			HeldItem:        protocol.ItemInstance{Stack: pk.HeldItem},
+			EntityMetadata:  upgradeEntityMetadata(pk.EntityMetadata),
+			AdventureSettings: packet.AdventureSettings{
+				Flags:                  pk.Flags,
+				CommandPermissionLevel: pk.CommandPermissionLevel,
+				ActionPermissions:      pk.ActionPermissions,
+				PermissionLevel:        pk.PermissionLevel,
+				PlayerUniqueID:         pk.PlayerUniqueID,
+			},
+			EntityLinks:   pk.EntityLinks,
+			DeviceID:      pk.DeviceID,
+			BuildPlatform: pk.BuildPlatform,
+		}}
+	case *legacypacket.SetActorData:
+		return []packet.Packet{&legacypacket_v486.SetActorData{
+			EntityRuntimeID: pk.EntityRuntimeID,
+			EntityMetadata:  upgradeEntityMetadata(pk.EntityMetadata),
+			Tick:            pk.Tick,
+		}}
+	case *legacypacket.StructureBlockUpdate:
+		return []packet.Packet{&legacypacket_v486.StructureBlockUpdate{
+			Position:           pk.Position,
+			StructureName:      pk.StructureName,
+			DataField:          pk.DataField,
+			IncludePlayers:     pk.IncludePlayers,
+			ShowBoundingBox:    pk.ShowBoundingBox,
+			StructureBlockType: pk.StructureBlockType,
+			Settings:           types_v486.StructureSettings{StructureSettings: upgradeStructureSettings(pk.Settings)},
+			RedstoneSaveMode:   pk.RedstoneSaveMode,
+			ShouldTrigger:      pk.ShouldTrigger,
+		}}
+	case *legacypacket.RequestChunkRadius:
+		return []packet.Packet{&legacypacket_v486.RequestChunkRadius{
+			ChunkRadius: pk.ChunkRadius,
+		}}
+	case *legacypacket.StructureTemplateDataRequest:
+		return []packet.Packet{&legacypacket_v486.StructureTemplateDataRequest{
+			StructureName: pk.StructureName,
+			Position:      pk.Position,
+			Settings:      types_v486.StructureSettings{StructureSettings: upgradeStructureSettings(pk.Settings)},
+			RequestType:   pk.RequestType,
+		}}
+	case *packet.ClientCacheStatus:
+		// The blob cache only produces blobs in the format used since 1.18, while this version uses two-dimensional
+		// biomes and sub chunks without an index, and its chunks can't be requested in sub chunks. The client cache is
+		// therefore not supported by this version, and the server is told it is disabled.
+		pk.Enabled = false
+		return []packet.Packet{pk}
+	case *packet.AdventureSettings, *packet.TickSync:
+		return nil
+	case *packet.PacketViolationWarning:
+		fmt.Println(pk)
+		return nil
+	}
+	return []packet.Packet{pk}
+}
+
+func (s *Step) Downgrade(pk packet.Packet, conn *minecraft.Conn) []packet.Packet {
+	switch pk := pk.(type) {
+	case *legacypacket_v486.AddActor:
+		return []packet.Packet{&legacypacket.AddActor{
+			EntityUniqueID:  pk.EntityUniqueID,
+			EntityRuntimeID: pk.EntityRuntimeID,
+			EntityType:      pk.EntityType,
+			Position:        pk.Position,
+			Velocity:        pk.Velocity,
+			Pitch:           pk.Pitch,
+			Yaw:             pk.Yaw,
+			HeadYaw:         pk.HeadYaw,
+			Attributes: lo.Map(pk.Attributes, func(a protocol.AttributeValue, _ int) types.Attribute {
+				return types.Attribute{
+					Name:  a.Name,
+					Value: a.Value,
+					Max:   a.Max,
+					Min:   a.Min,
+				}
+			}),
+			EntityMetadata: downgradeEntityMetadata(pk.EntityMetadata),
+			EntityLinks:    pk.EntityLinks,
+		}}
+	case *legacypacket_v486.AddPlayer:
+		return []packet.Packet{&legacypacket.AddPlayer{
+			UUID:                   pk.UUID,
+			Username:               pk.Username,
+			EntityUniqueID:         pk.EntityUniqueID,
+			EntityRuntimeID:        pk.EntityRuntimeID,
+			PlatformChatID:         pk.PlatformChatID,
+			Position:               pk.Position,
+			Velocity:               pk.Velocity,
+			Pitch:                  pk.Pitch,
+			Yaw:                    pk.Yaw,
+			HeadYaw:                pk.HeadYaw,
+			HeldItem:               pk.HeldItem.Stack,
+			EntityMetadata:         downgradeEntityMetadata(pk.EntityMetadata),
+			CommandPermissionLevel: pk.AdventureSettings.CommandPermissionLevel,
+			PermissionLevel:        pk.AdventureSettings.PermissionLevel,
+			PlayerUniqueID:         pk.AdventureSettings.PlayerUniqueID,
+			EntityLinks:            pk.EntityLinks,
+			DeviceID:               pk.DeviceID,
+			BuildPlatform:          pk.BuildPlatform,
+		}}
+	case *packet.AnimateEntity:
+		return []packet.Packet{&legacypacket.AnimateEntity{
+			Animation:        pk.Animation,
+			NextState:        pk.NextState,
+			StopCondition:    pk.StopCondition,
+			Controller:       pk.Controller,
+			BlendOutTime:     pk.BlendOutTime,
+			EntityRuntimeIDs: pk.EntityRuntimeIDs,
+		}}
+	case *legacypacket_v589.AvailableCommands:
+		return []packet.Packet{&legacypacket.AvailableCommands{
+			Commands: lo.Map(pk.Commands, func(c types_v589.Command, _ int) types.Command {
+				return types.Command{
+					Name:            c.Name,
+					Description:     c.Description,
+					Flags:           byte(c.Flags),
+					PermissionLevel: c.PermissionLevel,
+					//Aliases:         c.AliasesOffset,
+					Overloads: lo.Map(c.Overloads, func(o types_v589.CommandOverload, _ int) types.CommandOverload {
+						return types.CommandOverload{Parameters: lo.Map(o.Parameters, func(p protocol.CommandParameter, _ int) types.CommandParameter {
+							return types.CommandParameter{
+								Name:                p.Name,
+								Type:                types.DowngradeParamType(p.Type),
+								Optional:            p.Optional,
+								CollapseEnumOptions: true,
+								//Enum:                types.CommandEnum(p.Enum),
+								//Suffix:              p.Suffix,
+							}
+						})}
+					}),
+				}
+			}),
+		}}
+	case *packet.CameraShake:
+		return []packet.Packet{&legacypacket.CameraShake{
+			Intensity: pk.Intensity,
+			Duration:  pk.Duration,
+			Type:      pk.Type,
+		}}
+	case *packet.ClientBoundMapItemData:
+		return []packet.Packet{&legacypacket.ClientBoundMapItemData{
+			MapID:          pk.MapID,
+			UpdateFlags:    pk.UpdateFlags,
+			Dimension:      pk.Dimension,
+			LockedMap:      pk.LockedMap,
+			Scale:          pk.Scale,
+			MapsIncludedIn: pk.MapsIncludedIn,
+			TrackedObjects: pk.TrackedObjects,
+			Decorations:    pk.Decorations,
+			Height:         pk.Height,
+			Width:          pk.Width,
+			XOffset:        pk.XOffset,
+			YOffset:        pk.YOffset,
+			Pixels:         [][]color.RGBA{pk.Pixels},
+		}}
+	case *packet.EducationSettings:
+		return []packet.Packet{&legacypacket.EducationSettings{
+			CodeBuilderDefaultURI: pk.CodeBuilderDefaultURI,
+			CodeBuilderTitle:      pk.CodeBuilderTitle,
+			CanResizeCodeBuilder:  pk.CanResizeCodeBuilder,
+			OverrideURI:           pk.OverrideURI,
+			HasQuiz:               pk.HasQuiz,
+		}}
+	case *packet.Event:
+		// TODO: support
+		return []packet.Packet{&legacypacket.Event{
+			EntityRuntimeID: pk.EntityRuntimeID,
+			EventType:       0,
+			UsePlayerID:     pk.UsePlayerID,
+		}}
+	case *packet.GameRulesChanged:
+		return []packet.Packet{&legacypacket.GameRulesChanged{
+			GameRules: lo.SliceToMap(pk.GameRules, func(rule protocol.GameRule) (string, any) {
+				return rule.Name, rule.Value
+			}),
+		}}
+	case *packet.HurtArmour:
+		return []packet.Packet{&legacypacket.HurtArmour{
+			Cause:  pk.Cause,
+			Damage: pk.Damage,
+		}}
+	case *packet.MobArmourEquipment:
+		return []packet.Packet{&legacypacket.MobArmourEquipment{
+			EntityRuntimeID: pk.EntityRuntimeID,
+			Helmet:          downgradeItemStack(pk.Helmet.Stack),
+			Chestplate:      downgradeItemStack(pk.Chestplate.Stack),
+			Leggings:        downgradeItemStack(pk.Leggings.Stack),
+			Boots:           downgradeItemStack(pk.Boots.Stack),
+		}}
+	case *packet.MobEquipment:
+		return []packet.Packet{&legacypacket.MobEquipment{
+			EntityRuntimeID: pk.EntityRuntimeID,
+			NewItem:         downgradeItemStack(pk.NewItem.Stack),
+		}}
+	case *legacypacket_v486.NetworkChunkPublisherUpdate:
+		return []packet.Packet{&legacypacket.NetworkChunkPublisherUpdate{
+			Position: pk.Position,
+			Radius:   pk.Radius,
+		}}
+	case *packet.NetworkSettings:
+		return []packet.Packet{&legacypacket.NetworkSettings{
+			CompressionThreshold: pk.CompressionThreshold,
+		}}
+	case *packet.PhotoTransfer:
+		return []packet.Packet{&legacypacket.PhotoTransfer{
+			PhotoName: pk.PhotoName,
+			PhotoData: pk.PhotoData,
+			BookID:    pk.BookID,
+		}}
+	case *legacypacket_v486.PlayerList:
+		return []packet.Packet{&legacypacket.PlayerList{
+			ActionType: pk.ActionType,
+			Entries: lo.Map(pk.Entries, func(e types_v486.PlayerListEntry, _ int) legacypacket.PlayerListEntry {
+				return legacypacket.PlayerListEntry{
+					UUID:           e.UUID,
+					EntityUniqueID: e.EntityUniqueID,
+					Username:       e.Username,
+					XUID:           e.XUID,
+					PlatformChatID: e.PlatformChatID,
+					BuildPlatform:  e.BuildPlatform,
+					//Skin:           types.LegacySkin(e.Skin),
+					Teacher: e.Teacher,
+					Host:    e.Host,
+				}
+			}),
+		}}
+	case *legacypacket_v486.PlayerSkin:
+		return []packet.Packet{&legacypacket.PlayerSkin{
+			UUID: pk.UUID,
+			//Skin:        types.LegacySkin(pk.Skin),
+			NewSkinName: pk.NewSkinName,
+			OldSkinName: pk.OldSkinName,
+		}}
+	case *packet.PositionTrackingDBServerBroadcast:
+		data, _ := nbt.MarshalEncoding(&pk.Payload, nbt.LittleEndian)
+		return []packet.Packet{&legacypacket.PositionTrackingDBServerBroadcast{
+			BroadcastAction: pk.BroadcastAction,
+			TrackingID:      pk.TrackingID,
+			SerialisedData:  data,
+		}}
+	case *packet.ResourcePacksInfo:
+		return []packet.Packet{&legacypacket.ResourcePacksInfo{
+			TexturePackRequired: pk.TexturePackRequired,
+			HasScripts:          pk.HasScripts,
+			BehaviourPacks: lo.Map(pk.BehaviourPacks, func(pack protocol.BehaviourPackInfo, _ int) types.ResourcePackInfo {
+				return types.ResourcePackInfo{
+					UUID:            pack.UUID,
+					Version:         pack.Version,
+					Size:            pack.Size,
+					ContentKey:      pack.ContentKey,
+					SubPackName:     pack.SubPackName,
+					ContentIdentity: pack.ContentIdentity,
+					HasScripts:      pack.HasScripts,
+				}
+			}),
+			TexturePacks: lo.Map(pk.TexturePacks, func(pack protocol.TexturePackInfo, _ int) types.ResourcePackInfo {
+				return types.ResourcePackInfo{
+					UUID:            pack.UUID,
+					Version:         pack.Version,
+					Size:            pack.Size,
+					ContentKey:      pack.ContentKey,
+					SubPackName:     pack.SubPackName,
+					ContentIdentity: pack.ContentIdentity,
+					HasScripts:      pack.HasScripts,
+				}
+			}),
+		}}
+	case *legacypacket_v486.SetActorData:
+		// Entity data is only sent to clients of this version when entities are added.
+		return nil
+	case *packet.SetTitle:
+		return []packet.Packet{&legacypacket.SetTitle{
+			ActionType:      pk.ActionType,
+			Text:            pk.Text,
+			FadeInDuration:  pk.FadeInDuration,
+			RemainDuration:  pk.RemainDuration,
+			FadeOutDuration: pk.FadeOutDuration,
+		}}
+	case *legacypacket_v486.SpawnParticleEffect:
+		return []packet.Packet{&legacypacket.SpawnParticleEffect{
+			Dimension:      pk.Dimension,
+			EntityUniqueID: pk.EntityUniqueID,
+			Position:       pk.Position,
+			ParticleName:   pk.ParticleName,
+		}}
+	case *legacypacket_v486.StartGame:
+		// TODO: Adjust our mappings to account for any possible custom blocks.
+		return []packet.Packet{&legacypacket.StartGame{
+			EntityUniqueID:                 pk.EntityUniqueID,
+			EntityRuntimeID:                pk.EntityRuntimeID,
+			PlayerGameMode:                 pk.PlayerGameMode,
+			PlayerPosition:                 pk.PlayerPosition,
+			Pitch:                          pk.Pitch,
+			Yaw:                            pk.Yaw,
+			WorldSeed:                      pk.WorldSeed,
+			SpawnBiomeType:                 pk.SpawnBiomeType,
+			UserDefinedBiomeName:           pk.UserDefinedBiomeName,
+			Dimension:                      pk.Dimension,
+			Generator:                      pk.Generator,
+			WorldGameMode:                  pk.WorldGameMode,
+			Difficulty:                     pk.Difficulty,
+			WorldSpawn:                     pk.WorldSpawn,
+			AchievementsDisabled:           pk.AchievementsDisabled,
+			DayCycleLockTime:               pk.DayCycleLockTime,
+			EducationEditionOffer:          pk.EducationEditionOffer,
+			EducationFeaturesEnabled:       pk.EducationFeaturesEnabled,
+			EducationProductID:             pk.EducationProductID,
+			RainLevel:                      pk.RainLevel,
+			LightningLevel:                 pk.LightningLevel,
+			ConfirmedPlatformLockedContent: pk.ConfirmedPlatformLockedContent,
+			MultiPlayerGame:                pk.MultiPlayerGame,
+			LANBroadcastEnabled:            pk.LANBroadcastEnabled,
+			XBLBroadcastMode:               pk.XBLBroadcastMode,
+			PlatformBroadcastMode:          pk.PlatformBroadcastMode,
+			CommandsEnabled:                pk.CommandsEnabled,
+			TexturePackRequired:            pk.TexturePackRequired,
+			GameRules: lo.SliceToMap(pk.GameRules, func(rule protocol.GameRule) (string, any) {
+				return rule.Name, rule.Value
+			}),
+			Experiments:                     pk.Experiments,
+			ExperimentsPreviouslyToggled:    pk.ExperimentsPreviouslyToggled,
+			BonusChestEnabled:               pk.BonusChestEnabled,
+			StartWithMapEnabled:             pk.StartWithMapEnabled,
+			PlayerPermissions:               pk.PlayerPermissions,
+			ServerChunkTickRadius:           pk.ServerChunkTickRadius,
+			HasLockedBehaviourPack:          pk.HasLockedBehaviourPack,
+			HasLockedTexturePack:            pk.HasLockedTexturePack,
+			FromLockedWorldTemplate:         pk.FromLockedWorldTemplate,
+			MSAGamerTagsOnly:                pk.MSAGamerTagsOnly,
+			FromWorldTemplate:               pk.FromWorldTemplate,
+			WorldTemplateSettingsLocked:     pk.WorldTemplateSettingsLocked,
+			OnlySpawnV1Villagers:            pk.OnlySpawnV1Villagers,
+			BaseGameVersion:                 pk.BaseGameVersion,
+			LimitedWorldWidth:               pk.LimitedWorldWidth,
+			LimitedWorldDepth:               pk.LimitedWorldDepth,
+			NewNether:                       pk.NewNether,
+			ForceExperimentalGameplay:       pk.ForceExperimentalGameplay,
+			LevelID:                         pk.LevelID,
+			WorldName:                       pk.WorldName,
+			TemplateContentIdentity:         pk.TemplateContentIdentity,
+			Trial:                           pk.Trial,
+			ServerAuthoritativeMovementMode: uint32(pk.PlayerMovementSettings.MovementType),
+			Time:                            pk.Time,
+			EnchantmentSeed:                 pk.EnchantmentSeed,
+			MultiPlayerCorrelationID:        pk.MultiPlayerCorrelationID,
+			Blocks:                          pk.Blocks,
+			Items:                           pk.Items,
+			ServerAuthoritativeInventory:    pk.ServerAuthoritativeInventory && !s.clientAuthoritativeInventory,
+		}}
+	case *packet.AdventureSettings:
+		if pk.PlayerUniqueID != conn.GameData().EntityUniqueID {
+			// Clients of this version only accept the adventure settings of their own player.
+			return nil
+		}
+		flags := pk.Flags & (packet.AdventureFlagAllowFlight | packet.AdventureFlagFlying | packet.AdventureFlagNoClip)
+		if pk.ActionPermissions&packet.ActionPermissionBuild != 0 && pk.ActionPermissions&packet.ActionPermissionMine != 0 {
+			flags |= packet.AdventureFlagWorldBuilder
+		} else {
+			flags |= packet.AdventureFlagWorldImmutable
+		}
+		pk.Flags = flags
+		return []packet.Packet{pk}
+	case *legacypacket_v486.UpdateAttributes:
+		return []packet.Packet{&legacypacket.UpdateAttributes{
+			EntityRuntimeID: pk.EntityRuntimeID,
+			Attributes: lo.Map(pk.Attributes, func(attribute types_v486.Attribute, _ int) types.Attribute {
+				return types.Attribute{
+					Name:    attribute.Name,
+					Value:   attribute.Value,
+					Min:     attribute.Min,
+					Max:     attribute.Max,
+					Default: attribute.Default,
+				}
+			}),
+			Tick: pk.Tick,
+		}}
+	case *packet.InventoryContent:
+		return []packet.Packet{&legacypacket.InventoryContent{
+			WindowID: pk.WindowID,
+			Content: lo.Map(pk.Content, func(it protocol.ItemInstance, _ int) types.ItemInstance {
+				return types.ItemInstance{StackNetworkID: it.StackNetworkID, Stack: downgradeItemStack(it.Stack)}
+			}),
+		}}
+	case *packet.InventorySlot:
+		return []packet.Packet{&legacypacket.InventorySlot{
+			WindowID: pk.WindowID,
+			Slot:     pk.Slot,
+			NewItem:  types.ItemInstance{StackNetworkID: pk.NewItem.StackNetworkID, Stack: downgradeItemStack(pk.NewItem.Stack)},
+		}}
+	case *packet.CraftingData:
+		return []packet.Packet{&legacypacket.CraftingData{
+			Recipes:                      lo.FilterMap(pk.Recipes, downgradeRecipe),
+			PotionRecipes:                pk.PotionRecipes,
+			PotionContainerChangeRecipes: pk.PotionContainerChangeRecipes,
+			ClearRecipes:                 pk.ClearRecipes,
+		}}
+	case *packet.LevelChunk:
+		return []packet.Packet{&legacypacket.LevelChunk{
+			Position:      pk.Position,
+			SubChunkCount: pk.SubChunkCount,
+			CacheEnabled:  pk.CacheEnabled,
+			BlobHashes:    pk.BlobHashes,
+			RawPayload:    pk.RawPayload,
+		}}
+	}
+	return []packet.Packet{pk}
+}
+
+// downgradeItemStack downgrades an item stack to the item stack of the legacy version.
+func downgradeItemStack(stack protocol.ItemStack) types.ItemStack {
+	return types.ItemStack{
+		ItemType: types.ItemType{
+			NetworkID:     stack.NetworkID,
+			MetadataValue: int16(stack.MetadataValue),
+		},
+		Count:         int16(stack.Count),
+		NBTData:       stack.NBTData,
+		CanBePlacedOn: stack.CanBePlacedOn,
+		CanBreak:      stack.CanBreak,
+	}
+}
+
+// downgradeRecipe downgrades a recipe holding v486 item descriptors. Recipes of types that do not exist in the legacy
+// version are dropped.
+func downgradeRecipe(recipe protocol.Recipe, _ int) (types.Recipe, bool) {
+	switch recipe := recipe.(type) {
+	case *protocol.ShapelessRecipe:
+		return downgradeShapelessRecipe(*recipe), true
+	case *protocol.ShulkerBoxRecipe:
+		return &types.ShulkerBoxRecipe{ShapelessRecipe: *downgradeShapelessRecipe(recipe.ShapelessRecipe)}, true
+	case *protocol.ShapelessChemistryRecipe:
+		return &types.ShapelessChemistryRecipe{ShapelessRecipe: *downgradeShapelessRecipe(recipe.ShapelessRecipe)}, true
+	case *protocol.ShapedRecipe:
+		return downgradeShapedRecipe(*recipe), true
+	case *protocol.ShapedChemistryRecipe:
+		return &types.ShapedChemistryRecipe{ShapedRecipe: *downgradeShapedRecipe(recipe.ShapedRecipe)}, true
+	case *protocol.FurnaceRecipe:
+		return &types.FurnaceRecipe{
+			InputType: types.ItemType{NetworkID: recipe.InputType.NetworkID},
+			Output:    downgradeItemStack(recipe.Output),
+			Block:     recipe.Block,
+		}, true
+	case *protocol.FurnaceDataRecipe:
+		return &types.FurnaceDataRecipe{FurnaceRecipe: types.FurnaceRecipe{
+			InputType: types.ItemType{NetworkID: recipe.InputType.NetworkID, MetadataValue: int16(recipe.InputType.MetadataValue)},
+			Output:    downgradeItemStack(recipe.Output),
+			Block:     recipe.Block,
+		}}, true
+	case *protocol.MultiRecipe:
+		return &types.MultiRecipe{UUID: recipe.UUID, RecipeNetworkID: recipe.RecipeNetworkID}, true
+	}
+	return nil, false
+}
+
+// downgradeShapelessRecipe downgrades a shapeless recipe holding v486 item descriptors.
+func downgradeShapelessRecipe(recipe protocol.ShapelessRecipe) *types.ShapelessRecipe {
+	return &types.ShapelessRecipe{
+		RecipeID:        recipe.RecipeID,
+		Input:           lo.Map(recipe.Input, downgradeRecipeIngredient),
+		Output:          lo.Map(recipe.Output, func(stack protocol.ItemStack, _ int) types.ItemStack { return downgradeItemStack(stack) }),
+		UUID:            recipe.UUID,
+		Block:           recipe.Block,
+		Priority:        recipe.Priority,
+		RecipeNetworkID: recipe.RecipeNetworkID,
+	}
+}
+
+// downgradeShapedRecipe downgrades a shaped recipe holding v486 item descriptors.
+func downgradeShapedRecipe(recipe protocol.ShapedRecipe) *types.ShapedRecipe {
+	return &types.ShapedRecipe{
+		RecipeID:        recipe.RecipeID,
+		Width:           recipe.Width,
+		Height:          recipe.Height,
+		Input:           lo.Map(recipe.Input, downgradeRecipeIngredient),
+		Output:          lo.Map(recipe.Output, func(stack protocol.ItemStack, _ int) types.ItemStack { return downgradeItemStack(stack) }),
+		UUID:            recipe.UUID,
+		Block:           recipe.Block,
+		Priority:        recipe.Priority,
+		RecipeNetworkID: recipe.RecipeNetworkID,
+	}
+}
+
+// downgradeRecipeIngredient downgrades a recipe ingredient, of which the descriptor was already downgraded to a v486
+// item descriptor.
+func downgradeRecipeIngredient(item protocol.ItemDescriptorCount, _ int) types.RecipeIngredientItem {
+	descriptor, _ := item.Descriptor.(*types_v486.DefaultItemDescriptor)
+	if descriptor == nil || descriptor.NetworkID == 0 {
+		return types.RecipeIngredientItem{}
+	}
+	return types.RecipeIngredientItem{NetworkID: descriptor.NetworkID, MetadataValue: descriptor.MetadataValue, Count: item.Count}
+}
+
+// upgradeStructureSettings upgrades the structure settings of the legacy version.
+func upgradeStructureSettings(settings types.StructureSettings) protocol.StructureSettings {
+	return protocol.StructureSettings{
+		PaletteName:               settings.PaletteName,
+		IgnoreEntities:            settings.IgnoreEntities,
+		IgnoreBlocks:              settings.IgnoreBlocks,
+		Size:                      settings.Size,
+		Offset:                    settings.Offset,
+		LastEditingPlayerUniqueID: settings.LastEditingPlayerUniqueID,
+		Rotation:                  settings.Rotation,
+		Mirror:                    settings.Mirror,
+		Integrity:                 settings.Integrity,
+		Seed:                      settings.Seed,
+		Pivot:                     settings.Pivot,
+	}
+}
@@ -0,0 +1,39 @@
+package v419
+
+import (
+	"reflect"
+	"testing"
+
+	legacypacket "github.com/flonja/multiversion/protocols/v419/packet"
+	"github.com/flonja/multiversion/protocols/v419/types"
+	types_v486 "github.com/flonja/multiversion/protocols/v486/types"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// TestStepCraftingData checks that recipes are sent to legacy clients, and that recipes of types that do not exist in
+// the legacy version are dropped.
+func TestStepCraftingData(t *testing.T) {
+	downgraded := NewStep().Downgrade(&packet.CraftingData{Recipes: []protocol.Recipe{
+		&protocol.ShapelessRecipe{
+			RecipeID:        "smithing",
+			Input:           []protocol.ItemDescriptorCount{{Descriptor: &types_v486.DefaultItemDescriptor{NetworkID: 2, MetadataValue: 32767}, Count: 1}},
+			Output:          []protocol.ItemStack{{ItemType: protocol.ItemType{NetworkID: 3}, Count: 1}},
+			Block:           "smithing_table",
+			RecipeNetworkID: 7,
+		},
+		&protocol.SmithingTrimRecipe{RecipeNetworkID: 8},
+	}, ClearRecipes: true}, nil)
+
+	expected := []types.Recipe{&types.ShapelessRecipe{
+		RecipeID:        "smithing",
+		Input:           []types.RecipeIngredientItem{{NetworkID: 2, MetadataValue: 32767, Count: 1}},
+		Output:          []types.ItemStack{{ItemType: types.ItemType{NetworkID: 3}, Count: 1}},
+		Block:           "smithing_table",
+		RecipeNetworkID: 7,
+	}}
+	pk := downgraded[0].(*legacypacket.CraftingData)
+	if !reflect.DeepEqual(pk.Recipes, expected) || !pk.ClearRecipes {
+		t.Fatalf("expected recipes %#v, got %#v", expected, pk.Recipes)
+	}
+}
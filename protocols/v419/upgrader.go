@@ -1,28 +1,10 @@
 package v419
 
 import (
-	"github.com/flonja/multiversion/protocols/v486/types"
+	legacytypes "github.com/flonja/multiversion/protocols/v419/types"
 	"github.com/sandertv/gophertunnel/minecraft/protocol"
 )
 
-// upgradeBlockActorData upgrades a block actor from a legacy version to the latest version.
-func upgradeBlockActorData(data map[string]any) map[string]any {
-	switch data["id"] {
-	case "Sign":
-		textRaw, ok := data["Text"]
-		if !ok {
-			textRaw = ""
-		}
-		text, ok := textRaw.(string)
-		if !ok {
-			text = ""
-		}
-		data["FrontText"] = map[string]any{"Text": text}
-		data["BackText"] = map[string]any{"Text": ""}
-	}
-	return data
-}
-
 // upgradeEntityMetadata upgrades entity metadata from legacy version to latest version.
 func upgradeEntityMetadata(data map[uint32]any) map[uint32]any {
 	newData := make(map[uint32]any)
@@ -74,7 +56,7 @@ func upgradeEntityMetadata(data map[uint32]any) map[uint32]any {
 			key = protocol.EntityDataKeyAgent
 		case 90:
 			key = protocol.EntityDataKeyEatingCounter
-		case 91:
+		case entityDataKeyFlagsTwo:
 			key = protocol.EntityDataKeyFlagsTwo
 		case 94:
 			key = protocol.EntityDataKeyDataDuration
@@ -110,33 +92,71 @@ func upgradeEntityMetadata(data map[uint32]any) map[uint32]any {
 		newData[key] = value
 	}
 
-	var flag1, flag2 int64
-	if v, ok := newData[protocol.EntityDataKeyFlags]; ok {
-		flag1 = v.(int64)
+	return upgradeEntityFlags(newData)
+}
+
+// upgradeEntityFlags upgrades the entity flags held in latest entity metadata from legacy version to latest version.
+func upgradeEntityFlags(data map[uint32]any) map[uint32]any {
+	flags, hasFlags := data[protocol.EntityDataKeyFlags].(int64)
+	flagsTwo, hasFlagsTwo := data[protocol.EntityDataKeyFlagsTwo].(int64)
+	if !hasFlags && !hasFlagsTwo {
+		return data
 	}
-	if v, ok := newData[protocol.EntityDataKeyFlagsTwo]; ok {
-		flag2 = v.(int64)
+	flags, flagsTwo = entityFlags.UpgradeEntityFlags(flags, flagsTwo)
+	if hasFlags || flags != 0 {
+		data[protocol.EntityDataKeyFlags] = flags
 	}
-
-	flag2 <<= 1
-	flag2 |= (flag1 >> 63) & 1
-
-	newFlag1 := flag1 & ^(^0 << (protocol.EntityDataFlagDash - 1))
-	lastHalf := flag1 & (^0<<protocol.EntityDataFlagDash - 1)
-	lastHalf <<= 1
-	newFlag1 |= lastHalf
-
-	newData[protocol.EntityDataKeyFlagsTwo] = flag2
-	newData[protocol.EntityDataKeyFlags] = newFlag1
-
-	return newData
+	if hasFlagsTwo || flagsTwo != 0 {
+		data[protocol.EntityDataKeyFlagsTwo] = flagsTwo
+	}
+	return data
 }
 
-func upgradeCraftingDescription(descriptor *types.DefaultItemDescriptor) protocol.ItemDescriptor {
-	return &protocol.DefaultItemDescriptor{
-		NetworkID:     int16(descriptor.NetworkID),
-		MetadataValue: int16(descriptor.MetadataValue),
+// upgradeInventoryAction upgrades a legacy inventory action, which holds the stack network ID of the new item
+// separately.
+func upgradeInventoryAction(action legacytypes.InventoryAction) protocol.InventoryAction {
+	return protocol.InventoryAction{
+		SourceType:    action.SourceType,
+		WindowID:      action.WindowID,
+		SourceFlags:   action.SourceFlags,
+		InventorySlot: action.InventorySlot,
+		OldItem:       protocol.ItemInstance{Stack: action.OldItem},
+		NewItem:       protocol.ItemInstance{StackNetworkID: action.StackNetworkID, Stack: action.NewItem},
 	}
 }
 
-// TODO: add upgrade entity flags
+// upgradeTransactionData upgrades legacy inventory transaction data.
+func upgradeTransactionData(data legacytypes.InventoryTransactionData) protocol.InventoryTransactionData {
+	switch data := data.(type) {
+	case *legacytypes.MismatchTransactionData:
+		return &protocol.MismatchTransactionData{}
+	case *legacytypes.UseItemTransactionData:
+		return &protocol.UseItemTransactionData{
+			ActionType:      data.ActionType,
+			BlockPosition:   data.BlockPosition,
+			BlockFace:       data.BlockFace,
+			HotBarSlot:      data.HotBarSlot,
+			HeldItem:        protocol.ItemInstance{Stack: data.HeldItem},
+			Position:        data.Position,
+			ClickedPosition: data.ClickedPosition,
+			BlockRuntimeID:  data.BlockRuntimeID,
+		}
+	case *legacytypes.UseItemOnEntityTransactionData:
+		return &protocol.UseItemOnEntityTransactionData{
+			TargetEntityRuntimeID: data.TargetEntityRuntimeID,
+			ActionType:            data.ActionType,
+			HotBarSlot:            data.HotBarSlot,
+			HeldItem:              protocol.ItemInstance{Stack: data.HeldItem},
+			Position:              data.Position,
+			ClickedPosition:       data.ClickedPosition,
+		}
+	case *legacytypes.ReleaseItemTransactionData:
+		return &protocol.ReleaseItemTransactionData{
+			ActionType:   data.ActionType,
+			HotBarSlot:   data.HotBarSlot,
+			HeldItem:     protocol.ItemInstance{Stack: data.HeldItem},
+			HeadPosition: data.HeadPosition,
+		}
+	}
+	return &protocol.NormalTransactionData{}
+}
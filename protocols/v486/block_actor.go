@@ -0,0 +1,10 @@
+package v486
+
+import (
+	"github.com/flonja/multiversion/translator"
+)
+
+// blockActorRules holds the differences between the block actors of 1.18.10 and those of other legacy versions.
+var blockActorRules = translator.LegacyBlockActorRules{
+	SignFields: []string{"TextOwner", "SignTextColor", "IgnoreLighting"},
+}
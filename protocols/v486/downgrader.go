@@ -3,35 +3,10 @@ package v486
 import (
 	"github.com/flonja/multiversion/mapping"
 	"github.com/flonja/multiversion/protocols/v486/types"
+	"github.com/samber/lo"
 	"github.com/sandertv/gophertunnel/minecraft/protocol"
 )
 
-// downgradeBlockActorData downgrades a block actor from latest version to legacy version.
-func downgradeBlockActorData(data map[string]any) map[string]any {
-	switch data["id"] {
-	case "Sign":
-		delete(data, "BackText")
-		frontRaw, ok := data["FrontText"]
-		if !ok {
-			frontRaw = map[string]any{"Text": ""}
-		}
-		front, ok := frontRaw.(map[string]any)
-		if !ok {
-			front = map[string]any{"Text": ""}
-		}
-		textRaw, ok := front["Text"]
-		if !ok {
-			textRaw = ""
-		}
-		text, ok := textRaw.(string)
-		if !ok {
-			text = ""
-		}
-		data["Text"] = text
-	}
-	return data
-}
-
 // downgradeEntityMetadata downgrades entity metadata from latest version to legacy version.
 func downgradeEntityMetadata(data map[uint32]any) map[uint32]any {
 	newData := make(map[uint32]any)
@@ -60,9 +35,12 @@ func downgradeEntityMetadata(data map[uint32]any) map[uint32]any {
 		}
 		newData[key] = value
 	}
-	return newData
+	return downgradeEntityFlags(newData)
 }
 
+// downgradeCraftingDescription downgrades the item descriptor passed to a legacy item descriptor. Item tags are not
+// supported by the legacy version and are expanded into concrete items by the item translator beforehand, so they
+// end up as air, like any other descriptor that cannot be resolved.
 func downgradeCraftingDescription(descriptor protocol.ItemDescriptor, m mapping.Item) protocol.ItemDescriptor {
 	var networkId int32
 	var metadata int32
@@ -75,10 +53,10 @@ func downgradeCraftingDescription(descriptor protocol.ItemDescriptor, m mapping.
 			networkId = rid
 			metadata = int32(descriptor.MetadataValue)
 		}
-	case *protocol.ItemTagItemDescriptor:
-		/// ?????
 	case *protocol.ComplexAliasItemDescriptor:
-		/// ?????
+		if rid, ok := m.ItemNameToRuntimeID(descriptor.Name); ok {
+			networkId = rid
+		}
 	}
 	return &types.DefaultItemDescriptor{
 		NetworkID:     networkId,
@@ -86,4 +64,43 @@ func downgradeCraftingDescription(descriptor protocol.ItemDescriptor, m mapping.
 	}
 }
 
-// TODO: add downgrade entity flags
+// usesSmithingTemplate checks if the stack request action passed moves items from or to the smithing template slot,
+// which does not exist in the legacy version.
+func usesSmithingTemplate(action protocol.StackRequestAction, _ int) bool {
+	var slots []protocol.StackRequestSlotInfo
+	switch action := action.(type) {
+	case *protocol.TakeStackRequestAction:
+		slots = []protocol.StackRequestSlotInfo{action.Source, action.Destination}
+	case *protocol.PlaceStackRequestAction:
+		slots = []protocol.StackRequestSlotInfo{action.Source, action.Destination}
+	case *protocol.SwapStackRequestAction:
+		slots = []protocol.StackRequestSlotInfo{action.Source, action.Destination}
+	case *protocol.DropStackRequestAction:
+		slots = []protocol.StackRequestSlotInfo{action.Source}
+	case *protocol.DestroyStackRequestAction:
+		slots = []protocol.StackRequestSlotInfo{action.Source}
+	case *protocol.ConsumeStackRequestAction:
+		slots = []protocol.StackRequestSlotInfo{action.Source}
+	}
+	return lo.ContainsBy(slots, func(slot protocol.StackRequestSlotInfo) bool {
+		return slot.ContainerID == protocol.ContainerSmithingTableTemplate
+	})
+}
+
+// downgradeEntityFlags downgrades the entity flags held in legacy entity metadata from latest version to legacy
+// version.
+func downgradeEntityFlags(data map[uint32]any) map[uint32]any {
+	flags, hasFlags := data[protocol.EntityDataKeyFlags].(int64)
+	flagsTwo, hasFlagsTwo := data[protocol.EntityDataKeyFlagsTwo].(int64)
+	if !hasFlags && !hasFlagsTwo {
+		return data
+	}
+	flags, flagsTwo = entityFlags.DowngradeEntityFlags(flags, flagsTwo)
+	if hasFlags || flags != 0 {
+		data[protocol.EntityDataKeyFlags] = flags
+	}
+	if hasFlagsTwo || flagsTwo != 0 {
+		data[protocol.EntityDataKeyFlagsTwo] = flagsTwo
+	}
+	return data
+}
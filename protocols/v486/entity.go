@@ -0,0 +1,33 @@
+package v486
+
+import (
+	"github.com/flonja/multiversion/translator"
+	"github.com/samber/lo"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+)
+
+// entityFlags translates entity flags between the latest version and 1.18.10. All flags following the over
+// descending block flag were added after 1.18.10.
+var entityFlags = translator.NewEntityFlagTranslator(lo.RangeFrom[uint32](0, protocol.EntityDataFlagOverDescendingBlock+1))
+
+// unknownEntityTypes holds the entity types of the latest version that were added after 1.18.10.
+var unknownEntityTypes = []string{
+	"minecraft:allay",
+	"minecraft:camel",
+	"minecraft:chest_boat",
+	"minecraft:frog",
+	"minecraft:sniffer",
+	"minecraft:tadpole",
+	"minecraft:warden",
+}
+
+// entitySubstitutes holds the entities shown to 1.18.10 clients in place of entity types that were added later on.
+var entitySubstitutes = map[string]translator.EntitySubstitute{
+	"minecraft:allay":      {EntityType: "minecraft:vex"},
+	"minecraft:camel":      {EntityType: "minecraft:horse", Scale: 1.4},
+	"minecraft:chest_boat": {EntityType: "minecraft:boat"},
+	"minecraft:frog":       {EntityType: "minecraft:rabbit"},
+	"minecraft:sniffer":    {EntityType: "minecraft:ravager", Scale: 0.8},
+	"minecraft:tadpole":    {EntityType: "minecraft:tropicalfish", Scale: 0.5},
+	"minecraft:warden":     {EntityType: "minecraft:iron_golem", Scale: 1.05},
+}
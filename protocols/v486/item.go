@@ -0,0 +1,33 @@
+package v486
+
+import (
+	"github.com/flonja/multiversion/translator"
+	"github.com/samber/lo"
+)
+
+// enchantmentSwiftSneak is the ID of the swift sneak enchantment, which is the first enchantment added after 1.18.10.
+const enchantmentSwiftSneak = 37
+
+// itemNBTRules rewrites the NBT data of items for 1.18.10. The IDs of enchantments did not change, but armour trims
+// and the colour codes of materials did not exist yet.
+var itemNBTRules = translator.ItemNBTRules{
+	Enchantments: lo.SliceToMap(lo.RangeFrom[int16](0, enchantmentSwiftSneak), func(id int16) (int16, int16) {
+		return id, id
+	}),
+	StripTrims:  true,
+	TextColours: materialColours,
+}
+
+// materialColours holds the closest colour codes of the colours of materials added in 1.19.80, indexed by their codes.
+var materialColours = map[rune]rune{
+	'h': 'f', // Quartz.
+	'i': '7', // Iron.
+	'j': '8', // Netherite.
+	'm': '4', // Redstone.
+	'n': '6', // Copper.
+	'p': '6', // Gold.
+	'q': '2', // Emerald.
+	's': 'b', // Diamond.
+	't': '1', // Lapis.
+	'u': '5', // Amethyst.
+}
@@ -0,0 +1,54 @@
+package v486
+
+import (
+	"github.com/flonja/multiversion/protocols/latest"
+	"github.com/flonja/multiversion/translator"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// fallbackParticle is the particle shown to 1.18.10 clients in place of particles that have no equivalent.
+const fallbackParticle = "minecraft:basic_smoke_particle"
+
+// particleTypes holds the particle types shown to 1.18.10 clients in place of particle types that were added later
+// on. The first particle type added after 1.18.10 is the shriek particle.
+var particleTypes = map[int32]int32{
+	latest.ParticleSculkSoul:      latest.ParticleSoul,
+	latest.ParticleSonicExplosion: latest.ParticleHugeExplode,
+}
+
+// particleNames holds the particle names shown to 1.18.10 clients in place of particle names that were added later on.
+// Particles without an equivalent are replaced with the fallback particle.
+var particleNames = map[string]string{
+	"minecraft:cherry_leaves_particle":    "",
+	"minecraft:dust_plume":                "",
+	"minecraft:sculk_charge_particle":     "",
+	"minecraft:sculk_charge_pop_particle": "",
+	"minecraft:shriek_particle":           "",
+	"minecraft:sculk_soul_particle":       "minecraft:soul_particle",
+	"minecraft:sonic_explosion":           "minecraft:huge_explosion_emitter",
+}
+
+// levelEvents holds the level events sent to 1.18.10 clients in place of level events that were added later on.
+// Level events without an equivalent are not sent.
+var levelEvents = map[int32]int32{
+	packet.LevelEventParticleSculkShriek: 0,
+	packet.LevelEventSculkCatalystBloom:  0,
+	packet.LevelEventSculkCharge:         0,
+	packet.LevelEventSculkChargePop:      0,
+	packet.LevelEventSonicExplosion:      packet.LevelEventParticlesExplosion,
+}
+
+// newParticleTranslator creates the particle translator translating particles between the latest version and 1.18.10.
+func newParticleTranslator() *translator.DefaultParticleTranslator {
+	t := translator.NewParticleTranslator(latest.ParticleShriek, fallbackParticle, latest.ParticleSmoke)
+	for particleType, substitute := range particleTypes {
+		t.RegisterType(particleType, substitute)
+	}
+	for name, substitute := range particleNames {
+		t.RegisterName(name, substitute)
+	}
+	for eventType, substitute := range levelEvents {
+		t.RegisterLevelEvent(eventType, substitute)
+	}
+	return t
+}
@@ -0,0 +1,61 @@
+package v486
+
+import (
+	"github.com/flonja/multiversion/translator"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// soundSubstitutes holds the sounds played to 1.18.10 clients in place of sound event types that were added later on.
+// The first sound event type added after 1.18.10 is the tongue sound of frogs.
+var soundSubstitutes = map[uint32]translator.SoundSubstitute{
+	packet.SoundEventGoatCall0:             {SoundType: packet.SoundEventRaidHorn},
+	packet.SoundEventGoatCall1:             {SoundType: packet.SoundEventRaidHorn},
+	packet.SoundEventGoatCall2:             {SoundType: packet.SoundEventRaidHorn},
+	packet.SoundEventGoatCall3:             {SoundType: packet.SoundEventRaidHorn},
+	packet.SoundEventGoatCall4:             {SoundType: packet.SoundEventRaidHorn},
+	packet.SoundEventGoatCall5:             {SoundType: packet.SoundEventRaidHorn},
+	packet.SoundEventGoatCall6:             {SoundType: packet.SoundEventRaidHorn},
+	packet.SoundEventGoatCall7:             {SoundType: packet.SoundEventRaidHorn},
+	packet.SoundEventSculkSpread:           {SoundType: packet.SoundEventSculkSensorPowerOn},
+	packet.SoundEventSculkCharge:           {SoundType: packet.SoundEventSculkSensorPowerOn},
+	packet.SoundEventBrush:                 {SoundType: packet.SoundEventScrape},
+	packet.SoundEventBrushCompleted:        {SoundType: packet.SoundEventScrape},
+	packet.SoundEventSnifferEggCrack:       {SoundType: packet.SoundEventTurtleEggCrack},
+	packet.SoundEventSnifferEggHatched:     {SoundType: packet.SoundEventTurtleEggHatched},
+	packet.SoundEventShatterDecoratedPot:   {SoundType: packet.SoundEventGlass},
+	packet.SoundEventBreakDecoratedPot:     {SoundType: packet.SoundEventGlass},
+	packet.SoundEventDrinkMilk:             {SoundType: packet.SoundEventDrink},
+	packet.SoundEventSonicBoom:             {SoundType: packet.SoundEventExplode},
+	packet.SoundeventItemThrown:            {SoundType: packet.SoundEventThrow},
+	packet.SoundEventWaxedSignInteractFail: {SoundType: packet.SoundEventBlockClickFail},
+	packet.SoundEventDoorOpen:              {LevelEvent: packet.LevelEventSoundOpenDoor},
+	packet.SoundEventDoorClose:             {LevelEvent: packet.LevelEventSoundOpenDoor},
+	packet.SoundEventTrapdoorOpen:          {LevelEvent: packet.LevelEventSoundOpenDoor},
+	packet.SoundEventTrapdoorClose:         {LevelEvent: packet.LevelEventSoundOpenDoor},
+	packet.SoundEventFenceGateOpen:         {LevelEvent: packet.LevelEventSoundOpenDoor},
+	packet.SoundEventFenceGateClose:        {LevelEvent: packet.LevelEventSoundOpenDoor},
+	packet.SoundEventButtonClickOn:         {LevelEvent: packet.LevelEventSoundClick},
+	packet.SoundEventButtonClickOff:        {LevelEvent: packet.LevelEventSoundClick},
+	packet.SoundEventPressurePlateClickOn:  {LevelEvent: packet.LevelEventSoundClick},
+	packet.SoundEventPressurePlateClickOff: {LevelEvent: packet.LevelEventSoundClick},
+}
+
+// soundNames holds the sound names played to 1.18.10 clients in place of sound names that were added later on.
+var soundNames = map[string]string{
+	"mob.warden.attack": "mob.irongolem.throw",
+	"mob.warden.death":  "mob.irongolem.death",
+	"mob.warden.hurt":   "mob.irongolem.hit",
+	"mob.warden.step":   "mob.irongolem.walk",
+}
+
+// newSoundTranslator creates the sound translator translating sounds between the latest version and 1.18.10.
+func newSoundTranslator(entities translator.EntityTranslator) *translator.DefaultSoundTranslator {
+	t := translator.NewSoundTranslator(packet.SoundEventTongue, entities)
+	for soundType, substitute := range soundSubstitutes {
+		t.Register(soundType, substitute)
+	}
+	for name, substitute := range soundNames {
+		t.RegisterName(name, substitute)
+	}
+	return t
+}
@@ -0,0 +1,827 @@
+package v486
+
+import (
+	"encoding/json"
+
+	"github.com/flonja/multiversion/mapping"
+	legacypacket "github.com/flonja/multiversion/protocols/v486/packet"
+	"github.com/flonja/multiversion/protocols/v486/types"
+	legacypacket_v582 "github.com/flonja/multiversion/protocols/v582/packet"
+	"github.com/samber/lo"
+	"github.com/sandertv/gophertunnel/minecraft"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// Step converts packets between v486 and v582.
+type Step struct {
+	itemMapping mapping.Item
+}
+
+// NewStep creates a step using the v486 item mapping passed.
+func NewStep(itemMapping mapping.Item) Step {
+	return Step{itemMapping: itemMapping}
+}
+
+func (s Step) Upgrade(pk packet.Packet, conn *minecraft.Conn) []packet.Packet {
+	var newPks []packet.Packet
+	switch pk := pk.(type) {
+	case *legacypacket.AddActor:
+		newPks = append(newPks, &packet.AddActor{
+			EntityMetadata:   upgradeEntityMetadata(pk.EntityMetadata),
+			EntityRuntimeID:  pk.EntityRuntimeID,
+			EntityType:       pk.EntityType,
+			EntityUniqueID:   pk.EntityUniqueID,
+			HeadYaw:          pk.HeadYaw,
+			Pitch:            pk.Pitch,
+			Position:         pk.Position,
+			Velocity:         pk.Velocity,
+			Yaw:              pk.Yaw,
+			Attributes:       pk.Attributes,
+			EntityLinks:      pk.EntityLinks,
+			EntityProperties: protocol.EntityProperties{},
+		})
+	case *legacypacket.AddPlayer:
+		newPks = append(newPks, &packet.AddPlayer{
+			UUID:             pk.UUID,
+			Username:         pk.Username,
+			EntityRuntimeID:  pk.EntityRuntimeID,
+			PlatformChatID:   pk.PlatformChatID,
+			Position:         pk.Position,
+			Velocity:         pk.Velocity,
+			Pitch:            pk.Pitch,
+			Yaw:              pk.Yaw,
+			HeadYaw:          pk.HeadYaw,
+			HeldItem:         pk.HeldItem,
+			EntityMetadata:   upgradeEntityMetadata(pk.EntityMetadata),
+			DeviceID:         pk.DeviceID,
+			EntityLinks:      pk.EntityLinks,
+			GameType:         packet.GameTypeSurvival,
+			EntityProperties: protocol.EntityProperties{},
+			AbilityData: protocol.AbilityData{
+				EntityUniqueID:     pk.EntityUniqueID,
+				PlayerPermissions:  byte(pk.AdventureSettings.PermissionLevel),
+				CommandPermissions: byte(pk.AdventureSettings.CommandPermissionLevel),
+				Layers: []protocol.AbilityLayer{{
+					Type:      protocol.AbilityLayerTypeBase,
+					Abilities: protocol.AbilityCount - 1,
+				}},
+			},
+			BuildPlatform: int32(protocol.DeviceAndroid),
+		})
+	case *legacypacket.AddVolumeEntity:
+		newPks = append(newPks, &packet.AddVolumeEntity{
+			EntityRuntimeID:    pk.EntityRuntimeID,
+			EntityMetadata:     pk.EntityMetadata,
+			EncodingIdentifier: pk.EncodingIdentifier,
+			InstanceIdentifier: pk.InstanceIdentifier,
+			EngineVersion:      pk.EngineVersion,
+			Bounds:             [2]protocol.BlockPos{},
+			Dimension:          0,
+		})
+	case *legacypacket.CommandRequest:
+		newPks = append(newPks, &packet.CommandRequest{
+			CommandLine:   pk.CommandLine,
+			CommandOrigin: pk.CommandOrigin,
+			Internal:      pk.Internal,
+		})
+	case *packet.CraftingData:
+		for i, recipe := range pk.Recipes {
+			switch recipe := recipe.(type) {
+			case *protocol.ShapelessRecipe:
+				recipe.Input = lo.Map(recipe.Input, func(item protocol.ItemDescriptorCount, _ int) protocol.ItemDescriptorCount {
+					item.Descriptor = upgradeCraftingDescription(item.Descriptor.(*types.DefaultItemDescriptor))
+					return item
+				})
+				pk.Recipes[i] = recipe
+			case *protocol.ShapedRecipe:
+				recipe.Input = lo.Map(recipe.Input, func(item protocol.ItemDescriptorCount, _ int) protocol.ItemDescriptorCount {
+					item.Descriptor = upgradeCraftingDescription(item.Descriptor.(*types.DefaultItemDescriptor))
+					return item
+				})
+				pk.Recipes[i] = recipe
+			case *protocol.ShulkerBoxRecipe:
+				recipe.Input = lo.Map(recipe.Input, func(item protocol.ItemDescriptorCount, _ int) protocol.ItemDescriptorCount {
+					item.Descriptor = upgradeCraftingDescription(item.Descriptor.(*types.DefaultItemDescriptor))
+					return item
+				})
+				pk.Recipes[i] = recipe
+			case *protocol.ShapelessChemistryRecipe:
+				recipe.Input = lo.Map(recipe.Input, func(item protocol.ItemDescriptorCount, _ int) protocol.ItemDescriptorCount {
+					item.Descriptor = upgradeCraftingDescription(item.Descriptor.(*types.DefaultItemDescriptor))
+					return item
+				})
+				pk.Recipes[i] = recipe
+			case *protocol.ShapedChemistryRecipe:
+				recipe.Input = lo.Map(recipe.Input, func(item protocol.ItemDescriptorCount, _ int) protocol.ItemDescriptorCount {
+					item.Descriptor = upgradeCraftingDescription(item.Descriptor.(*types.DefaultItemDescriptor))
+					return item
+				})
+				pk.Recipes[i] = recipe
+			case *protocol.SmithingTransformRecipe:
+				recipe.Template.Descriptor = upgradeCraftingDescription(recipe.Template.Descriptor.(*types.DefaultItemDescriptor))
+				recipe.Base.Descriptor = upgradeCraftingDescription(recipe.Base.Descriptor.(*types.DefaultItemDescriptor))
+				recipe.Addition.Descriptor = upgradeCraftingDescription(recipe.Addition.Descriptor.(*types.DefaultItemDescriptor))
+				pk.Recipes[i] = recipe
+			case *protocol.SmithingTrimRecipe:
+				recipe.Template.Descriptor = upgradeCraftingDescription(recipe.Template.Descriptor.(*types.DefaultItemDescriptor))
+				recipe.Base.Descriptor = upgradeCraftingDescription(recipe.Base.Descriptor.(*types.DefaultItemDescriptor))
+				recipe.Addition.Descriptor = upgradeCraftingDescription(recipe.Addition.Descriptor.(*types.DefaultItemDescriptor))
+			}
+		}
+		newPks = append(newPks, pk)
+	case *packet.InventoryTransaction:
+		pk.LegacySetItemSlots = lo.Map(pk.LegacySetItemSlots, func(item protocol.LegacySetItemSlot, _ int) protocol.LegacySetItemSlot {
+			if item.ContainerID >= 21 { // RECIPE_BOOK
+				item.ContainerID += 1
+			}
+			return item
+		})
+		newPks = append(newPks, pk)
+	case *packet.ItemStackResponse:
+		for i2, respons := range pk.Responses {
+			for i3, info := range respons.ContainerInfo {
+				if info.ContainerID >= 21 { // RECIPE_BOOK
+					info.ContainerID += 1
+				}
+				pk.Responses[i2].ContainerInfo[i3] = info
+			}
+		}
+		newPks = append(newPks, pk)
+	case *legacypacket.ItemStackRequest:
+		newPks = append(newPks, &packet.ItemStackRequest{
+			Requests: lo.Map(pk.Requests, func(item types.ItemStackRequest, _ int) protocol.ItemStackRequest {
+				return protocol.ItemStackRequest{
+					RequestID: item.RequestID,
+					Actions: lo.Map(item.Actions, func(item protocol.StackRequestAction, _ int) protocol.StackRequestAction {
+						switch action := item.(type) {
+						case *types.TakeStackRequestAction:
+							return &action.TakeStackRequestAction
+						case *types.PlaceStackRequestAction:
+							return &action.PlaceStackRequestAction
+						case *types.SwapStackRequestAction:
+							return &action.SwapStackRequestAction
+						case *types.DropStackRequestAction:
+							return &action.DropStackRequestAction
+						case *types.DestroyStackRequestAction:
+							return &action.DestroyStackRequestAction
+						case *types.ConsumeStackRequestAction:
+							return &protocol.ConsumeStackRequestAction{DestroyStackRequestAction: action.DestroyStackRequestAction}
+						case *types.PlaceInContainerStackRequestAction:
+							return &action.PlaceInContainerStackRequestAction
+						case *types.TakeOutContainerStackRequestAction:
+							return &action.TakeOutContainerStackRequestAction
+						case *types.AutoCraftRecipeStackRequestAction:
+							return &action.AutoCraftRecipeStackRequestAction
+						}
+						return item
+					}),
+					FilterStrings: item.FilterStrings,
+				}
+			}),
+		})
+	case *legacypacket.ModalFormResponse:
+		responseData := protocol.Optional[[]byte]{}
+		cancelReason := protocol.Optional[uint8]{}
+		if string(pk.ResponseData) == "null" {
+			var cancelReasonType uint8 = packet.ModalFormCancelReasonUserClosed
+			cancelReason = protocol.Option(cancelReasonType)
+		} else {
+			responseData = protocol.Option(pk.ResponseData)
+		}
+		newPks = append(newPks, &packet.ModalFormResponse{
+			FormID:       pk.FormID,
+			ResponseData: responseData,
+			CancelReason: cancelReason,
+		})
+	case *legacypacket.NetworkChunkPublisherUpdate:
+		newPks = append(newPks, &packet.NetworkChunkPublisherUpdate{
+			Position:    pk.Position,
+			Radius:      pk.Radius,
+			SavedChunks: []protocol.ChunkPos{},
+		})
+	case *legacypacket.PlayerAction:
+		newPks = append(newPks, &packet.PlayerAction{
+			EntityRuntimeID: pk.EntityRuntimeID,
+			ActionType:      pk.ActionType,
+			BlockPosition:   pk.BlockPosition,
+			ResultPosition:  pk.BlockPosition,
+			BlockFace:       pk.BlockFace,
+		})
+	case *legacypacket.PlayerAuthInput:
+		newPks = append(newPks, &packet.PlayerAuthInput{
+			Pitch:            pk.Pitch,
+			Yaw:              pk.Yaw,
+			Position:         pk.Position,
+			MoveVector:       pk.MoveVector,
+			HeadYaw:          pk.HeadYaw,
+			InputData:        pk.InputData,
+			InputMode:        pk.InputMode,
+			PlayMode:         pk.PlayMode,
+			InteractionModel: packet.InteractionModelCrosshair,
+			GazeDirection:    pk.GazeDirection,
+			Tick:             pk.Tick,
+			Delta:            pk.Delta,
+			ItemInteractionData: func(data protocol.UseItemTransactionData) protocol.UseItemTransactionData {
+				data.LegacySetItemSlots = lo.Map(data.LegacySetItemSlots, func(item protocol.LegacySetItemSlot, _ int) protocol.LegacySetItemSlot {
+					if item.ContainerID >= 21 { // RECIPE_BOOK
+						item.ContainerID += 1
+					}
+					return item
+				})
+				return data
+			}(pk.ItemInteractionData),
+			ItemStackRequest: protocol.ItemStackRequest{
+				RequestID: pk.ItemStackRequest.RequestID,
+				Actions: lo.Map(pk.ItemStackRequest.Actions, func(item protocol.StackRequestAction, _ int) protocol.StackRequestAction {
+					switch action := item.(type) {
+					case *types.TakeStackRequestAction:
+						return &action.TakeStackRequestAction
+					case *types.PlaceStackRequestAction:
+						return &action.PlaceStackRequestAction
+					case *types.SwapStackRequestAction:
+						return &action.SwapStackRequestAction
+					case *types.DropStackRequestAction:
+						return &action.DropStackRequestAction
+					case *types.DestroyStackRequestAction:
+						return &action.DestroyStackRequestAction
+					case *types.ConsumeStackRequestAction:
+						return &protocol.ConsumeStackRequestAction{DestroyStackRequestAction: action.DestroyStackRequestAction}
+					case *types.PlaceInContainerStackRequestAction:
+						return &action.PlaceInContainerStackRequestAction
+					case *types.TakeOutContainerStackRequestAction:
+						return &action.TakeOutContainerStackRequestAction
+					case *types.AutoCraftRecipeStackRequestAction:
+						return &action.AutoCraftRecipeStackRequestAction
+					}
+					return item
+				}),
+				FilterStrings: pk.ItemStackRequest.FilterStrings,
+			},
+			BlockActions:       pk.BlockActions,
+			AnalogueMoveVector: pk.MoveVector,
+		})
+	case *legacypacket.PlayerList:
+		newPks = append(newPks, &packet.PlayerList{
+			ActionType: pk.ActionType,
+			Entries: lo.Map(pk.Entries, func(item types.PlayerListEntry, _ int) protocol.PlayerListEntry {
+				return item.PlayerListEntry
+			}),
+		})
+	case *legacypacket.PlayerSkin:
+		newPks = append(newPks, &packet.PlayerSkin{
+			UUID:        pk.UUID,
+			Skin:        pk.Skin.Skin,
+			NewSkinName: pk.NewSkinName,
+			OldSkinName: pk.OldSkinName,
+		})
+	case *legacypacket.RemoveVolumeEntity:
+		newPks = append(newPks, &packet.RemoveVolumeEntity{
+			EntityRuntimeID: pk.EntityRuntimeID,
+			Dimension:       0,
+		})
+	case *legacypacket.RequestChunkRadius:
+		newPks = append(newPks, &packet.RequestChunkRadius{
+			ChunkRadius:    pk.ChunkRadius,
+			MaxChunkRadius: pk.ChunkRadius,
+		})
+	case *legacypacket.SetActorData:
+		newPks = append(newPks, &packet.SetActorData{
+			EntityRuntimeID:  pk.EntityRuntimeID,
+			EntityMetadata:   upgradeEntityMetadata(pk.EntityMetadata),
+			EntityProperties: protocol.EntityProperties{},
+			Tick:             pk.Tick,
+		})
+	case *legacypacket.SpawnParticleEffect:
+		newPks = append(newPks, &packet.SpawnParticleEffect{
+			Dimension:       pk.Dimension,
+			EntityUniqueID:  pk.EntityUniqueID,
+			Position:        pk.Position,
+			ParticleName:    pk.ParticleName,
+			MoLangVariables: protocol.Optional[[]byte]{},
+		})
+	case *legacypacket.StartGame:
+		newPks = append(newPks, &legacypacket_v582.StartGame{
+			EntityUniqueID:                 pk.EntityUniqueID,
+			EntityRuntimeID:                pk.EntityRuntimeID,
+			PlayerGameMode:                 pk.PlayerGameMode,
+			PlayerPosition:                 pk.PlayerPosition,
+			Pitch:                          pk.Pitch,
+			Yaw:                            pk.Yaw,
+			WorldSeed:                      int64(pk.WorldSeed),
+			SpawnBiomeType:                 pk.SpawnBiomeType,
+			UserDefinedBiomeName:           pk.UserDefinedBiomeName,
+			Dimension:                      pk.Dimension,
+			Generator:                      pk.Generator,
+			WorldGameMode:                  pk.WorldGameMode,
+			Difficulty:                     pk.Difficulty,
+			WorldSpawn:                     pk.WorldSpawn,
+			AchievementsDisabled:           pk.AchievementsDisabled,
+			DayCycleLockTime:               pk.DayCycleLockTime,
+			EducationEditionOffer:          pk.EducationEditionOffer,
+			EducationFeaturesEnabled:       pk.EducationFeaturesEnabled,
+			EducationProductID:             pk.EducationProductID,
+			RainLevel:                      pk.RainLevel,
+			LightningLevel:                 pk.LightningLevel,
+			ConfirmedPlatformLockedContent: pk.ConfirmedPlatformLockedContent,
+			MultiPlayerGame:                pk.MultiPlayerGame,
+			LANBroadcastEnabled:            pk.LANBroadcastEnabled,
+			XBLBroadcastMode:               pk.XBLBroadcastMode,
+			PlatformBroadcastMode:          pk.PlatformBroadcastMode,
+			CommandsEnabled:                pk.CommandsEnabled,
+			TexturePackRequired:            pk.TexturePackRequired,
+			GameRules:                      pk.GameRules,
+			Experiments:                    pk.Experiments,
+			ExperimentsPreviouslyToggled:   pk.ExperimentsPreviouslyToggled,
+			BonusChestEnabled:              pk.BonusChestEnabled,
+			StartWithMapEnabled:            pk.StartWithMapEnabled,
+			PlayerPermissions:              pk.PlayerPermissions,
+			ServerChunkTickRadius:          pk.ServerChunkTickRadius,
+			HasLockedBehaviourPack:         pk.HasLockedBehaviourPack,
+			HasLockedTexturePack:           pk.HasLockedTexturePack,
+			FromLockedWorldTemplate:        pk.FromLockedWorldTemplate,
+			MSAGamerTagsOnly:               pk.MSAGamerTagsOnly,
+			FromWorldTemplate:              pk.FromWorldTemplate,
+			WorldTemplateSettingsLocked:    pk.WorldTemplateSettingsLocked,
+			OnlySpawnV1Villagers:           pk.OnlySpawnV1Villagers,
+			BaseGameVersion:                pk.BaseGameVersion,
+			LimitedWorldWidth:              pk.LimitedWorldWidth,
+			LimitedWorldDepth:              pk.LimitedWorldDepth,
+			NewNether:                      pk.NewNether,
+			EducationSharedResourceURI:     pk.EducationSharedResourceURI,
+			ForceExperimentalGameplay:      protocol.Option(pk.ForceExperimentalGameplay),
+			LevelID:                        pk.LevelID,
+			WorldName:                      pk.WorldName,
+			TemplateContentIdentity:        pk.TemplateContentIdentity,
+			Trial:                          pk.Trial,
+			PlayerMovementSettings:         pk.PlayerMovementSettings,
+			Time:                           pk.Time,
+			EnchantmentSeed:                pk.EnchantmentSeed,
+			Blocks:                         pk.Blocks,
+			Items:                          pk.Items,
+			MultiPlayerCorrelationID:       pk.MultiPlayerCorrelationID,
+			ServerAuthoritativeInventory:   pk.ServerAuthoritativeInventory,
+			GameVersion:                    pk.GameVersion,
+			ServerBlockStateChecksum:       pk.ServerBlockStateChecksum,
+		})
+	case *legacypacket.StructureBlockUpdate:
+		newPks = append(newPks, &packet.StructureBlockUpdate{
+			Position:           pk.Position,
+			StructureName:      pk.StructureName,
+			DataField:          pk.DataField,
+			IncludePlayers:     pk.IncludePlayers,
+			ShowBoundingBox:    pk.ShowBoundingBox,
+			StructureBlockType: pk.StructureBlockType,
+			Settings:           pk.Settings.StructureSettings,
+			RedstoneSaveMode:   pk.RedstoneSaveMode,
+			ShouldTrigger:      pk.ShouldTrigger,
+			Waterlogged:        pk.Waterlogged,
+		})
+	case *legacypacket.StructureTemplateDataRequest:
+		newPks = append(newPks, &packet.StructureTemplateDataRequest{
+			StructureName: pk.StructureName,
+			Position:      pk.Position,
+			Settings:      pk.Settings.StructureSettings,
+			RequestType:   pk.RequestType,
+		})
+	case *packet.SetActorData:
+		pk.EntityMetadata = upgradeEntityMetadata(pk.EntityMetadata)
+		newPks = append(newPks, pk)
+	case *legacypacket.UpdateAttributes:
+		newPks = append(newPks, &packet.UpdateAttributes{
+			EntityRuntimeID: pk.EntityRuntimeID,
+			Attributes: lo.Map(pk.Attributes, func(item types.Attribute, _ int) protocol.Attribute {
+				return item.Attribute
+			}),
+			Tick: pk.Tick,
+		})
+	case *packet.AdventureSettings:
+		handleFlag := func(flags uint32, secondFlag bool) uint32 {
+			layerMapping := map[uint32]uint32{
+				packet.AdventureFlagAllowFlight:  protocol.AbilityMayFly,
+				packet.AdventureFlagNoClip:       protocol.AbilityNoClip,
+				packet.AdventureFlagWorldBuilder: protocol.AbilityWorldBuilder,
+				packet.AdventureFlagFlying:       protocol.AbilityFlying,
+				packet.AdventureFlagMuted:        protocol.AbilityMuted,
+			}
+			if secondFlag {
+				layerMapping = map[uint32]uint32{
+					packet.ActionPermissionMine:             protocol.AbilityMine,
+					packet.ActionPermissionDoorsAndSwitches: protocol.AbilityDoorsAndSwitches,
+					packet.ActionPermissionOpenContainers:   protocol.AbilityOpenContainers,
+					packet.ActionPermissionAttackPlayers:    protocol.AbilityAttackPlayers,
+					packet.ActionPermissionAttackMobs:       protocol.AbilityAttackMobs,
+					packet.ActionPermissionOperator:         protocol.AbilityOperatorCommands,
+					packet.ActionPermissionBuild:            protocol.AbilityBuild,
+				}
+			}
+
+			out := uint32(0)
+			for flag, mapped := range layerMapping {
+				if (flags & flag) != 0 {
+					out |= mapped
+				}
+			}
+			return out
+		}
+
+		_ = handleFlag
+		//newPks = append(newPks, &packet.UpdateAbilities{
+		//	AbilityData: protocol.AbilityData{
+		//		EntityUniqueID:     pk.PlayerUniqueID,
+		//		PlayerPermissions:  byte(pk.PermissionLevel),
+		//		CommandPermissions: byte(pk.CommandPermissionLevel),
+		//		Layers: []protocol.AbilityLayer{
+		//			{
+		//				Type:      protocol.AbilityLayerTypeBase,
+		//				Abilities: protocol.AbilityCount - 1,
+		//				Values:    handleFlag(pk.Flags, false) | handleFlag(pk.ActionPermissions, true),
+		//				FlySpeed:  protocol.AbilityBaseFlySpeed,
+		//				WalkSpeed: protocol.AbilityBaseWalkSpeed,
+		//			},
+		//		},
+		//	},
+		//})
+	default:
+		newPks = append(newPks, pk)
+	}
+
+	return newPks
+}
+
+func (s Step) Downgrade(pk packet.Packet, _ *minecraft.Conn) []packet.Packet {
+	result := []packet.Packet{pk}
+
+	for i, pk := range result {
+		switch pk := pk.(type) {
+		case *packet.AddActor:
+			result[i] = &legacypacket.AddActor{
+				EntityMetadata:  downgradeEntityMetadata(pk.EntityMetadata),
+				EntityRuntimeID: pk.EntityRuntimeID,
+				EntityType:      pk.EntityType,
+				EntityUniqueID:  pk.EntityUniqueID,
+				HeadYaw:         pk.HeadYaw,
+				Pitch:           pk.Pitch,
+				Position:        pk.Position,
+				Velocity:        pk.Velocity,
+				Yaw:             pk.Yaw,
+				Attributes:      pk.Attributes,
+				EntityLinks:     pk.EntityLinks,
+			}
+		case *packet.AddPlayer:
+			result[i] = &legacypacket.AddPlayer{
+				UUID:            pk.UUID,
+				Username:        pk.Username,
+				EntityUniqueID:  pk.AbilityData.EntityUniqueID,
+				EntityRuntimeID: pk.EntityRuntimeID,
+				PlatformChatID:  pk.PlatformChatID,
+				Position:        pk.Position,
+				Velocity:        pk.Velocity,
+				Pitch:           pk.Pitch,
+				Yaw:             pk.Yaw,
+				HeadYaw:         pk.HeadYaw,
+				HeldItem:        pk.HeldItem,
+				EntityMetadata:  downgradeEntityMetadata(pk.EntityMetadata),
+				AdventureSettings: packet.AdventureSettings{
+					CommandPermissionLevel: uint32(pk.AbilityData.CommandPermissions),
+					PermissionLevel:        uint32(pk.AbilityData.PlayerPermissions),
+					PlayerUniqueID:         pk.AbilityData.EntityUniqueID,
+				},
+				DeviceID:    pk.DeviceID,
+				EntityLinks: pk.EntityLinks,
+			}
+		case *packet.AddVolumeEntity:
+			result[i] = &legacypacket.AddVolumeEntity{
+				EntityRuntimeID:    pk.EntityRuntimeID,
+				EntityMetadata:     pk.EntityMetadata,
+				EncodingIdentifier: pk.EncodingIdentifier,
+				InstanceIdentifier: pk.InstanceIdentifier,
+				EngineVersion:      pk.EngineVersion,
+			}
+		case *packet.CommandRequest:
+			result[i] = &legacypacket.CommandRequest{
+				CommandLine:   pk.CommandLine,
+				CommandOrigin: pk.CommandOrigin,
+				Internal:      pk.Internal,
+			}
+		case *packet.CraftingData:
+			// Smithing recipes take a template, which the legacy smithing table has no slot for, so servers reject every
+			// smithing request of legacy clients. Smithing is therefore not supported, and smithing recipes are hidden.
+			pk.Recipes = lo.Reject(pk.Recipes, func(recipe protocol.Recipe, _ int) bool {
+				switch recipe.(type) {
+				case *protocol.SmithingTransformRecipe, *protocol.SmithingTrimRecipe:
+					return true
+				}
+				return false
+			})
+			for i, recipe := range pk.Recipes {
+				switch recipe := recipe.(type) {
+				case *protocol.ShapelessRecipe:
+					recipe.Input = lo.Map(recipe.Input, func(item protocol.ItemDescriptorCount, _ int) protocol.ItemDescriptorCount {
+						item.Descriptor = downgradeCraftingDescription(item.Descriptor, s.itemMapping)
+						return item
+					})
+					pk.Recipes[i] = recipe
+				case *protocol.ShapedRecipe:
+					recipe.Input = lo.Map(recipe.Input, func(item protocol.ItemDescriptorCount, _ int) protocol.ItemDescriptorCount {
+						item.Descriptor = downgradeCraftingDescription(item.Descriptor, s.itemMapping)
+						return item
+					})
+					pk.Recipes[i] = recipe
+				case *protocol.ShulkerBoxRecipe:
+					recipe.Input = lo.Map(recipe.Input, func(item protocol.ItemDescriptorCount, _ int) protocol.ItemDescriptorCount {
+						item.Descriptor = downgradeCraftingDescription(item.Descriptor, s.itemMapping)
+						return item
+					})
+					pk.Recipes[i] = recipe
+				case *protocol.ShapelessChemistryRecipe:
+					recipe.Input = lo.Map(recipe.Input, func(item protocol.ItemDescriptorCount, _ int) protocol.ItemDescriptorCount {
+						item.Descriptor = downgradeCraftingDescription(item.Descriptor, s.itemMapping)
+						return item
+					})
+					pk.Recipes[i] = recipe
+				case *protocol.ShapedChemistryRecipe:
+					recipe.Input = lo.Map(recipe.Input, func(item protocol.ItemDescriptorCount, _ int) protocol.ItemDescriptorCount {
+						item.Descriptor = downgradeCraftingDescription(item.Descriptor, s.itemMapping)
+						return item
+					})
+					pk.Recipes[i] = recipe
+				}
+			}
+			result[i] = pk
+		case *packet.InventoryTransaction:
+			pk.LegacySetItemSlots = lo.Map(pk.LegacySetItemSlots, func(item protocol.LegacySetItemSlot, _ int) protocol.LegacySetItemSlot {
+				if item.ContainerID > 21 { // RECIPE_BOOK
+					item.ContainerID -= 1
+				}
+				return item
+			})
+			result[i] = pk
+		case *packet.ItemStackResponse:
+			for i2, respons := range pk.Responses {
+				respons.ContainerInfo = lo.Reject(respons.ContainerInfo, func(info protocol.StackResponseContainerInfo, _ int) bool {
+					return info.ContainerID == protocol.ContainerSmithingTableTemplate
+				})
+				for i3, info := range respons.ContainerInfo {
+					if info.ContainerID > 21 { // RECIPE_BOOK
+						info.ContainerID -= 1
+					}
+					respons.ContainerInfo[i3] = info
+				}
+				pk.Responses[i2] = respons
+			}
+			result[i] = pk
+		case *packet.ItemStackRequest:
+			result[i] = &legacypacket.ItemStackRequest{
+				Requests: lo.Map(pk.Requests, func(item protocol.ItemStackRequest, _ int) types.ItemStackRequest {
+					item.Actions = lo.Map(lo.Reject(item.Actions, usesSmithingTemplate), func(item protocol.StackRequestAction, _ int) protocol.StackRequestAction {
+						switch action := item.(type) {
+						case *protocol.TakeStackRequestAction:
+							return &types.TakeStackRequestAction{TakeStackRequestAction: *action}
+						case *protocol.PlaceStackRequestAction:
+							return &types.PlaceStackRequestAction{PlaceStackRequestAction: *action}
+						case *protocol.SwapStackRequestAction:
+							return &types.SwapStackRequestAction{SwapStackRequestAction: *action}
+						case *protocol.DropStackRequestAction:
+							return &types.DropStackRequestAction{DropStackRequestAction: *action}
+						case *protocol.DestroyStackRequestAction:
+							return &types.DestroyStackRequestAction{DestroyStackRequestAction: *action}
+						case *protocol.ConsumeStackRequestAction:
+							return &types.ConsumeStackRequestAction{DestroyStackRequestAction: action.DestroyStackRequestAction}
+						case *protocol.PlaceInContainerStackRequestAction:
+							return &types.PlaceInContainerStackRequestAction{PlaceInContainerStackRequestAction: *action}
+						case *protocol.TakeOutContainerStackRequestAction:
+							return &types.TakeOutContainerStackRequestAction{TakeOutContainerStackRequestAction: *action}
+						case *protocol.AutoCraftRecipeStackRequestAction:
+							return &types.AutoCraftRecipeStackRequestAction{AutoCraftRecipeStackRequestAction: *action}
+						}
+						return item
+					})
+					return types.ItemStackRequest{ItemStackRequest: item}
+				}),
+			}
+		case *packet.ModalFormResponse:
+			var responseData []byte
+			if val, ok := pk.ResponseData.Value(); ok {
+				responseData = val
+			}
+			if _, cancelled := pk.CancelReason.Value(); cancelled {
+				if resp, err := json.Marshal(nil); err == nil {
+					responseData = resp
+				}
+			}
+			result[i] = &legacypacket.ModalFormResponse{
+				FormID:       pk.FormID,
+				ResponseData: responseData,
+			}
+		case *packet.NetworkChunkPublisherUpdate:
+			result[i] = &legacypacket.NetworkChunkPublisherUpdate{
+				Position: pk.Position,
+				Radius:   pk.Radius,
+			}
+		case *packet.PlayerAction:
+			result[i] = &legacypacket.PlayerAction{
+				EntityRuntimeID: pk.EntityRuntimeID,
+				ActionType:      pk.ActionType,
+				BlockPosition:   pk.BlockPosition,
+				BlockFace:       pk.BlockFace,
+			}
+		case *packet.PlayerAuthInput:
+			pk.ItemStackRequest.Actions = lo.Reject(pk.ItemStackRequest.Actions, usesSmithingTemplate)
+			result[i] = &legacypacket.PlayerAuthInput{
+				Pitch:         pk.Pitch,
+				Yaw:           pk.Yaw,
+				Position:      pk.Position,
+				MoveVector:    pk.MoveVector,
+				HeadYaw:       pk.HeadYaw,
+				InputData:     pk.InputData,
+				InputMode:     pk.InputMode,
+				PlayMode:      pk.PlayMode,
+				GazeDirection: pk.GazeDirection,
+				Tick:          pk.Tick,
+				Delta:         pk.Delta,
+				ItemInteractionData: func(data protocol.UseItemTransactionData) protocol.UseItemTransactionData {
+					data.LegacySetItemSlots = lo.Map(data.LegacySetItemSlots, func(item protocol.LegacySetItemSlot, _ int) protocol.LegacySetItemSlot {
+						if item.ContainerID > 21 { // RECIPE_BOOK
+							item.ContainerID -= 1
+						}
+						return item
+					})
+					return data
+				}(pk.ItemInteractionData),
+				ItemStackRequest: types.ItemStackRequest{ItemStackRequest: pk.ItemStackRequest},
+				BlockActions:     pk.BlockActions,
+			}
+		case *packet.PlayerList:
+			result[i] = &legacypacket.PlayerList{
+				ActionType: pk.ActionType,
+				Entries: lo.Map(pk.Entries, func(item protocol.PlayerListEntry, _ int) types.PlayerListEntry {
+					return types.PlayerListEntry{PlayerListEntry: item}
+				}),
+			}
+		case *packet.PlayerSkin:
+			result[i] = &legacypacket.PlayerSkin{
+				UUID:        pk.UUID,
+				Skin:        types.Skin{Skin: pk.Skin},
+				NewSkinName: pk.NewSkinName,
+				OldSkinName: pk.OldSkinName,
+			}
+		case *packet.RemoveVolumeEntity:
+			result[i] = &legacypacket.RemoveVolumeEntity{
+				EntityRuntimeID: pk.EntityRuntimeID,
+			}
+		case *packet.RequestChunkRadius:
+			result[i] = &legacypacket.RequestChunkRadius{
+				ChunkRadius: pk.ChunkRadius,
+			}
+		case *packet.SetActorData:
+			result[i] = &legacypacket.SetActorData{
+				EntityRuntimeID: pk.EntityRuntimeID,
+				EntityMetadata:  downgradeEntityMetadata(pk.EntityMetadata),
+				Tick:            pk.Tick,
+			}
+		case *packet.SpawnParticleEffect:
+			result[i] = &legacypacket.SpawnParticleEffect{
+				Dimension:      pk.Dimension,
+				EntityUniqueID: pk.EntityUniqueID,
+				Position:       pk.Position,
+				ParticleName:   pk.ParticleName,
+			}
+		case *legacypacket_v582.StartGame:
+			_, enabled := pk.ForceExperimentalGameplay.Value()
+			result[i] = &legacypacket.StartGame{
+				EntityUniqueID:                 pk.EntityUniqueID,
+				EntityRuntimeID:                pk.EntityRuntimeID,
+				PlayerGameMode:                 pk.PlayerGameMode,
+				PlayerPosition:                 pk.PlayerPosition,
+				Pitch:                          pk.Pitch,
+				Yaw:                            pk.Yaw,
+				WorldSeed:                      int32(pk.WorldSeed),
+				SpawnBiomeType:                 pk.SpawnBiomeType,
+				UserDefinedBiomeName:           pk.UserDefinedBiomeName,
+				Dimension:                      pk.Dimension,
+				Generator:                      pk.Generator,
+				WorldGameMode:                  pk.WorldGameMode,
+				Difficulty:                     pk.Difficulty,
+				WorldSpawn:                     pk.WorldSpawn,
+				AchievementsDisabled:           pk.AchievementsDisabled,
+				DayCycleLockTime:               pk.DayCycleLockTime,
+				EducationEditionOffer:          pk.EducationEditionOffer,
+				EducationFeaturesEnabled:       pk.EducationFeaturesEnabled,
+				EducationProductID:             pk.EducationProductID,
+				RainLevel:                      pk.RainLevel,
+				LightningLevel:                 pk.LightningLevel,
+				ConfirmedPlatformLockedContent: pk.ConfirmedPlatformLockedContent,
+				MultiPlayerGame:                pk.MultiPlayerGame,
+				LANBroadcastEnabled:            pk.LANBroadcastEnabled,
+				XBLBroadcastMode:               pk.XBLBroadcastMode,
+				PlatformBroadcastMode:          pk.PlatformBroadcastMode,
+				CommandsEnabled:                pk.CommandsEnabled,
+				TexturePackRequired:            pk.TexturePackRequired,
+				GameRules:                      pk.GameRules,
+				Experiments:                    pk.Experiments,
+				ExperimentsPreviouslyToggled:   pk.ExperimentsPreviouslyToggled,
+				BonusChestEnabled:              pk.BonusChestEnabled,
+				StartWithMapEnabled:            pk.StartWithMapEnabled,
+				PlayerPermissions:              pk.PlayerPermissions,
+				ServerChunkTickRadius:          pk.ServerChunkTickRadius,
+				HasLockedBehaviourPack:         pk.HasLockedBehaviourPack,
+				HasLockedTexturePack:           pk.HasLockedTexturePack,
+				FromLockedWorldTemplate:        pk.FromLockedWorldTemplate,
+				MSAGamerTagsOnly:               pk.MSAGamerTagsOnly,
+				FromWorldTemplate:              pk.FromWorldTemplate,
+				WorldTemplateSettingsLocked:    pk.WorldTemplateSettingsLocked,
+				OnlySpawnV1Villagers:           pk.OnlySpawnV1Villagers,
+				BaseGameVersion:                pk.BaseGameVersion,
+				LimitedWorldWidth:              pk.LimitedWorldWidth,
+				LimitedWorldDepth:              pk.LimitedWorldDepth,
+				NewNether:                      pk.NewNether,
+				EducationSharedResourceURI:     pk.EducationSharedResourceURI,
+				ForceExperimentalGameplay:      enabled,
+				LevelID:                        pk.LevelID,
+				WorldName:                      pk.WorldName,
+				TemplateContentIdentity:        pk.TemplateContentIdentity,
+				Trial:                          pk.Trial,
+				PlayerMovementSettings:         pk.PlayerMovementSettings,
+				Time:                           pk.Time,
+				EnchantmentSeed:                pk.EnchantmentSeed,
+				Blocks:                         pk.Blocks,
+				Items:                          pk.Items,
+				MultiPlayerCorrelationID:       pk.MultiPlayerCorrelationID,
+				ServerAuthoritativeInventory:   pk.ServerAuthoritativeInventory,
+				GameVersion:                    pk.GameVersion,
+				ServerBlockStateChecksum:       pk.ServerBlockStateChecksum,
+			}
+		case *packet.UpdateAttributes:
+			result[i] = &legacypacket.UpdateAttributes{
+				EntityRuntimeID: pk.EntityRuntimeID,
+				Attributes: lo.Map(pk.Attributes, func(item protocol.Attribute, _ int) types.Attribute {
+					return types.Attribute{Attribute: item}
+				}),
+				Tick: pk.Tick,
+			}
+		case *packet.StructureBlockUpdate:
+			result[i] = &legacypacket.StructureBlockUpdate{
+				Position:           pk.Position,
+				StructureName:      pk.StructureName,
+				DataField:          pk.DataField,
+				IncludePlayers:     pk.IncludePlayers,
+				ShowBoundingBox:    pk.ShowBoundingBox,
+				StructureBlockType: pk.StructureBlockType,
+				Settings:           types.StructureSettings{StructureSettings: pk.Settings},
+				RedstoneSaveMode:   pk.RedstoneSaveMode,
+				ShouldTrigger:      pk.ShouldTrigger,
+				Waterlogged:        pk.Waterlogged,
+			}
+		case *packet.StructureTemplateDataRequest:
+			result[i] = &legacypacket.StructureTemplateDataRequest{
+				StructureName: pk.StructureName,
+				Position:      pk.Position,
+				Settings:      types.StructureSettings{StructureSettings: pk.Settings},
+				RequestType:   pk.RequestType,
+			}
+		case *packet.UpdateAbilities:
+			handleFlag := func(layers []protocol.AbilityLayer, secondFlag bool) uint32 {
+				layerMapping := map[uint32]uint32{
+					protocol.AbilityAttackPlayers: packet.AdventureSettingsFlagsNoPvM,
+					protocol.AbilityMayFly:        packet.AdventureFlagAllowFlight,
+					protocol.AbilityNoClip:        packet.AdventureFlagNoClip,
+					protocol.AbilityWorldBuilder:  packet.AdventureFlagWorldBuilder,
+					protocol.AbilityFlying:        packet.AdventureFlagFlying,
+					protocol.AbilityMuted:         packet.AdventureFlagMuted,
+				}
+				if secondFlag {
+					layerMapping = map[uint32]uint32{
+						protocol.AbilityMine:             packet.ActionPermissionMine,
+						protocol.AbilityDoorsAndSwitches: packet.ActionPermissionDoorsAndSwitches,
+						protocol.AbilityOpenContainers:   packet.ActionPermissionOpenContainers,
+						protocol.AbilityAttackPlayers:    packet.ActionPermissionAttackPlayers,
+						protocol.AbilityAttackMobs:       packet.ActionPermissionAttackMobs,
+						protocol.AbilityOperatorCommands: packet.ActionPermissionOperator,
+						protocol.AbilityBuild:            packet.ActionPermissionBuild,
+					}
+				}
+
+				out := uint32(0)
+				for _, layer := range layers {
+					for flag, mapped := range layerMapping {
+						if (layer.Values & flag) != 0 {
+							out |= mapped
+						}
+					}
+				}
+				return out
+			}
+
+			result[i] = &packet.AdventureSettings{
+				Flags:                  handleFlag(pk.AbilityData.Layers, false),
+				CommandPermissionLevel: uint32(pk.AbilityData.CommandPermissions),
+				ActionPermissions:      handleFlag(pk.AbilityData.Layers, true),
+				PermissionLevel:        uint32(pk.AbilityData.PlayerPermissions),
+				PlayerUniqueID:         pk.AbilityData.EntityUniqueID,
+			}
+		}
+	}
+
+	return result
+}
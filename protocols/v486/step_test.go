@@ -0,0 +1,56 @@
+package v486
+
+import (
+	"reflect"
+	"testing"
+
+	legacypacket "github.com/flonja/multiversion/protocols/v486/packet"
+	"github.com/flonja/multiversion/protocols/v486/types"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// TestStepSmithingRecipes checks that smithing recipes, which take a template the legacy version has no slot for, are
+// hidden from legacy clients.
+func TestStepSmithingRecipes(t *testing.T) {
+	shapeless := &protocol.ShapelessRecipe{RecipeID: "shapeless", Block: "crafting_table", RecipeNetworkID: 6}
+	downgraded := NewStep(nil).Downgrade(&packet.CraftingData{Recipes: []protocol.Recipe{
+		shapeless,
+		&protocol.SmithingTransformRecipe{
+			RecipeNetworkID: 7,
+			Template:        protocol.ItemDescriptorCount{Descriptor: &protocol.DefaultItemDescriptor{NetworkID: 1}, Count: 1},
+			Base:            protocol.ItemDescriptorCount{Descriptor: &protocol.DefaultItemDescriptor{NetworkID: 2}, Count: 1},
+			Addition:        protocol.ItemDescriptorCount{Descriptor: &protocol.DefaultItemDescriptor{NetworkID: 3}, Count: 1},
+			Block:           "smithing_table",
+		},
+		&protocol.SmithingTrimRecipe{RecipeNetworkID: 8},
+	}}, nil)
+
+	if recipes := downgraded[0].(*packet.CraftingData).Recipes; !reflect.DeepEqual(recipes, []protocol.Recipe{shapeless}) {
+		t.Fatalf("expected only the shapeless recipe, got %#v", recipes)
+	}
+}
+
+// TestStepConsumeAction checks that the request the server receives for a legacy craft holds the consume actions of
+// the client, and no actions for slots the client does not have.
+func TestStepConsumeAction(t *testing.T) {
+	input := protocol.DestroyStackRequestAction{Count: 1, Source: protocol.StackRequestSlotInfo{ContainerID: protocol.ContainerCraftingInput, Slot: 28}}
+	upgraded := NewStep(nil).Upgrade(&legacypacket.ItemStackRequest{Requests: []types.ItemStackRequest{{ItemStackRequest: protocol.ItemStackRequest{
+		RequestID: -1,
+		Actions: []protocol.StackRequestAction{
+			&protocol.CraftRecipeStackRequestAction{RecipeNetworkID: 6},
+			&types.ConsumeStackRequestAction{DestroyStackRequestAction: input},
+		},
+	}}}}, nil)
+
+	expected := []protocol.ItemStackRequest{{
+		RequestID: -1,
+		Actions: []protocol.StackRequestAction{
+			&protocol.CraftRecipeStackRequestAction{RecipeNetworkID: 6},
+			&protocol.ConsumeStackRequestAction{DestroyStackRequestAction: input},
+		},
+	}}
+	if requests := upgraded[0].(*packet.ItemStackRequest).Requests; !reflect.DeepEqual(requests, expected) {
+		t.Fatalf("expected requests %#v, got %#v", expected, requests)
+	}
+}
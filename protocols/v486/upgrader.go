@@ -5,24 +5,6 @@ import (
 	"github.com/sandertv/gophertunnel/minecraft/protocol"
 )
 
-// upgradeBlockActorData upgrades a block actor from a legacy version to the latest version.
-func upgradeBlockActorData(data map[string]any) map[string]any {
-	switch data["id"] {
-	case "Sign":
-		textRaw, ok := data["Text"]
-		if !ok {
-			textRaw = ""
-		}
-		text, ok := textRaw.(string)
-		if !ok {
-			text = ""
-		}
-		data["FrontText"] = map[string]any{"Text": text}
-		data["BackText"] = map[string]any{"Text": ""}
-	}
-	return data
-}
-
 // upgradeEntityMetadata upgrades entity metadata from legacy version to latest version.
 func upgradeEntityMetadata(data map[uint32]any) map[uint32]any {
 	newData := make(map[uint32]any)
@@ -51,7 +33,7 @@ func upgradeEntityMetadata(data map[uint32]any) map[uint32]any {
 		}
 		newData[key] = value
 	}
-	return newData
+	return upgradeEntityFlags(newData)
 }
 
 func upgradeCraftingDescription(descriptor *types.DefaultItemDescriptor) protocol.ItemDescriptor {
@@ -61,4 +43,19 @@ func upgradeCraftingDescription(descriptor *types.DefaultItemDescriptor) protoco
 	}
 }
 
-// TODO: add upgrade entity flags
+// upgradeEntityFlags upgrades the entity flags held in latest entity metadata from legacy version to latest version.
+func upgradeEntityFlags(data map[uint32]any) map[uint32]any {
+	flags, hasFlags := data[protocol.EntityDataKeyFlags].(int64)
+	flagsTwo, hasFlagsTwo := data[protocol.EntityDataKeyFlagsTwo].(int64)
+	if !hasFlags && !hasFlagsTwo {
+		return data
+	}
+	flags, flagsTwo = entityFlags.UpgradeEntityFlags(flags, flagsTwo)
+	if hasFlags || flags != 0 {
+		data[protocol.EntityDataKeyFlags] = flags
+	}
+	if hasFlagsTwo || flagsTwo != 0 {
+		data[protocol.EntityDataKeyFlagsTwo] = flagsTwo
+	}
+	return data
+}
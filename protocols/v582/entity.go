@@ -0,0 +1,16 @@
+package v582
+
+import "github.com/flonja/multiversion/translator"
+
+// unknownEntityTypes holds the entity types of the latest version that only exist behind the "Next Major Update"
+// experiment in 1.19.80, which servers of the latest version never enable.
+var unknownEntityTypes = []string{
+	"minecraft:camel",
+	"minecraft:sniffer",
+}
+
+// entitySubstitutes holds the entities shown to 1.19.80 clients in place of entity types that are not available.
+var entitySubstitutes = map[string]translator.EntitySubstitute{
+	"minecraft:camel":   {EntityType: "minecraft:horse", Scale: 1.4},
+	"minecraft:sniffer": {EntityType: "minecraft:ravager", Scale: 0.8},
+}
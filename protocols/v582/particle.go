@@ -0,0 +1,17 @@
+package v582
+
+import (
+	"github.com/flonja/multiversion/protocols/latest"
+	"github.com/flonja/multiversion/translator"
+)
+
+// fallbackParticle is the particle shown to 1.19.80 clients in place of particles that have no equivalent.
+const fallbackParticle = "minecraft:basic_smoke_particle"
+
+// newParticleTranslator creates the particle translator translating particles between the latest version and 1.19.80.
+// The first particle type added after 1.19.80 is the dust plume particle.
+func newParticleTranslator() *translator.DefaultParticleTranslator {
+	t := translator.NewParticleTranslator(latest.ParticleDustPlume, fallbackParticle, latest.ParticleSmoke)
+	t.RegisterName("minecraft:dust_plume", "")
+	return t
+}
@@ -4,11 +4,12 @@ import (
 	_ "embed"
 	"github.com/flonja/multiversion/mapping"
 	"github.com/flonja/multiversion/packbuilder"
+	"github.com/flonja/multiversion/protocols/chain"
 	"github.com/flonja/multiversion/protocols/latest"
 	"github.com/flonja/multiversion/protocols/v582/items"
 	legacypacket "github.com/flonja/multiversion/protocols/v582/packet"
+	v589 "github.com/flonja/multiversion/protocols/v589"
 	legacypacket_v589 "github.com/flonja/multiversion/protocols/v589/packet"
-	"github.com/flonja/multiversion/protocols/v589/types"
 	"github.com/flonja/multiversion/translator"
 	"github.com/samber/lo"
 	"github.com/sandertv/gophertunnel/minecraft"
@@ -17,6 +18,7 @@ import (
 	"github.com/sandertv/gophertunnel/minecraft/resource"
 	"golang.org/x/exp/maps"
 	"io"
+	"time"
 )
 
 var (
@@ -24,13 +26,31 @@ var (
 	itemRuntimeIDData []byte
 	//go:embed block_states.nbt
 	blockStateData []byte
+	//go:embed biome_id_map.json
+	biomeIDData []byte
+	//go:embed block_fallbacks.json
+	blockFallbackData []byte
+	//go:embed item_fallbacks.json
+	itemFallbackData []byte
 )
 
+// unknownPackets holds the IDs of the packets of the latest version that were added after 1.19.80.
+var unknownPackets = append([]uint32{packet.IDCameraPresets}, lo.RangeWithSteps[uint32](packet.IDCameraInstruction, packet.IDAgentAnimation+1, 1)...)
+
+// blobTimeout is the time after which chunks waiting for their blobs are dropped.
+const blobTimeout = time.Second * 5
+
 type Protocol struct {
-	itemMapping     mapping.Item
-	blockMapping    mapping.Block
-	itemTranslator  translator.ItemTranslator
-	blockTranslator translator.BlockTranslator
+	itemMapping        mapping.Item
+	blockMapping       mapping.Block
+	itemTranslator     translator.ItemTranslator
+	blockTranslator    translator.BlockTranslator
+	entityTranslator   translator.EntityTranslator
+	particleTranslator translator.ParticleTranslator
+	contexts           *translator.Contexts
+	blobs              *translator.BlobCache
+	packets            *translator.PacketFilter
+	chain              *chain.ChainedProtocol
 }
 
 func New() *Protocol {
@@ -38,11 +58,52 @@ func New() *Protocol {
 	blockMapping := mapping.NewBlockMapping(blockStateData)
 	latestBlockMapping := latest.NewBlockMapping()
 
-	itemTranslator := translator.NewItemTranslator(itemMapping, latest.NewItemMapping(), blockMapping, latestBlockMapping)
+	entityTranslator := translator.NewEntityTranslator(unknownEntityTypes)
+	for entityType, substitute := range entitySubstitutes {
+		entityTranslator.Register(entityType, substitute)
+	}
+	itemTranslator := translator.NewItemTranslator(itemMapping, latest.NewItemMapping(), blockMapping, latestBlockMapping).WithItemFallback(mapping.NewItemFallback(itemFallbackData))
 	itemTranslator.Register(items.DiscRelic{}, "minecraft:music_disc_relic")
-	return &Protocol{itemMapping: itemMapping, blockMapping: blockMapping,
-		itemTranslator:  itemTranslator,
-		blockTranslator: translator.NewBlockTranslator(blockMapping, latestBlockMapping)}
+	p := &Protocol{itemMapping: itemMapping, blockMapping: blockMapping,
+		itemTranslator:   itemTranslator,
+		blockTranslator:  translator.NewBlockTranslator(blockMapping, latestBlockMapping, mapping.NewBiomeMapping(biomeIDData), latest.NewBiomeMapping()).WithBlockFallback(mapping.NewBlockFallback(blockFallbackData)),
+		entityTranslator: entityTranslator}
+	p.contexts = translator.NewContexts(p.itemTranslator, p.blockTranslator)
+	p.blobs = translator.NewBlobCache(blobTimeout)
+	p.particleTranslator = newParticleTranslator()
+	p.packets = translator.NewPacketFilter(unknownPackets, translator.NewEmulator())
+	p.chain = chain.NewChainedProtocol(p, Step{}, v589.Step{}, chain.TranslatorStep{Contexts: p.contexts, Blobs: p.blobs, Entities: p.entityTranslator,
+		Particles: p.particleTranslator}).
+		WithPacketFilter(p.packets)
+	return p
+}
+
+// WithUnknownPacketFunc sets the function called for packets that do not exist in this version, right before they are
+// dropped. It may return packets of this version that are sent in their place.
+func (p *Protocol) WithUnknownPacketFunc(f translator.UnknownPacketFunc) *Protocol {
+	p.packets.SetUnknownPacketFunc(f)
+	return p
+}
+
+// WithEmulator sets the emulator used to emulate packets that do not exist in this version. By default, toasts, NPC
+// dialogues, sign editing and camera fades are emulated. If nil, packets are never emulated.
+func (p *Protocol) WithEmulator(emulator translator.Emulator) *Protocol {
+	p.packets.SetEmulator(emulator)
+	return p
+}
+
+// WithUnknownEntityPolicy sets the policy for entity types that are not available in this version and have no
+// substitute registered. By default, such entities are dropped.
+func (p *Protocol) WithUnknownEntityPolicy(policy translator.UnknownEntityPolicy) *Protocol {
+	p.entityTranslator.SetUnknownEntityPolicy(policy)
+	return p
+}
+
+// WithItemStandIns replaces every item that does not exist in this version with a custom item, using the PNG textures
+// found in the directory passed. The custom items are included in the resource pack returned by ResourcePack.
+func (p *Protocol) WithItemStandIns(textureDir string) *Protocol {
+	p.itemTranslator.RegisterStandIns(textureDir)
+	return p
 }
 
 func (p Protocol) ResourcePack(ver string) *resource.Pack {
@@ -61,16 +122,13 @@ func (Protocol) Ver() string {
 	return "1.19.83"
 }
 
-func (Protocol) Packets(_ bool) packet.Pool {
-	pool := packet.NewClientPool()
-	for k, v := range packet.NewServerPool() {
-		pool[k] = v
-	}
-	pool[packet.IDAvailableCommands] = func() packet.Packet { return &legacypacket_v589.AvailableCommands{} }
-	pool[packet.IDEmote] = func() packet.Packet { return &legacypacket.Emote{} }
-	pool[packet.IDStartGame] = func() packet.Packet { return &legacypacket.StartGame{} }
-	pool[packet.IDUnlockedRecipes] = func() packet.Packet { return &legacypacket.UnlockedRecipes{} }
-	return pool
+func (p Protocol) Packets(_ bool) packet.Pool {
+	return p.packets.Pool(packet.Pool{
+		packet.IDAvailableCommands: func() packet.Packet { return &legacypacket_v589.AvailableCommands{} },
+		packet.IDEmote:             func() packet.Packet { return &legacypacket.Emote{} },
+		packet.IDStartGame:         func() packet.Packet { return &legacypacket.StartGame{} },
+		packet.IDUnlockedRecipes:   func() packet.Packet { return &legacypacket.UnlockedRecipes{} },
+	})
 }
 
 func (Protocol) Encryption(key [32]byte) packet.Encryption {
@@ -92,292 +150,18 @@ func (Protocol) NewWriter(w interface {
 }
 
 func (p Protocol) ConvertToLatest(pk packet.Packet, conn *minecraft.Conn) []packet.Packet {
-	var newPks []packet.Packet
-	switch pk := pk.(type) {
-	case *packet.ClientCacheStatus:
-		pk.Enabled = false
-		newPks = append(newPks, pk)
-	case *legacypacket.Emote:
-		newPks = append(newPks, &packet.Emote{
-			EntityRuntimeID: pk.EntityRuntimeID,
-			EmoteID:         pk.EmoteID,
-			XUID:            conn.IdentityData().XUID,
-			PlatformID:      conn.ClientData().PlatformOnlineID,
-			Flags:           pk.Flags,
-		})
-	case *legacypacket.StartGame:
-		// todo: figure out what to do when there are no custom items
-		//if len(lo.Filter(pk.Items, func(item protocol.ItemEntry, _ int) bool {
-		//	return item.ComponentBased
-		//})) == 0 {
-		//	pks = append(pks, &packet.ItemComponent{
-		//		Items: func() (entries []protocol.ItemComponentEntry) {
-		//			for _, item := range p.itemMapping.CustomItems() {
-		//				name, _ := item.EncodeItem()
-		//				entries = append(entries, protocol.ItemComponentEntry{
-		//					Name: name,
-		//					Data: packbuilder.Components(item),
-		//				})
-		//			}
-		//			return entries
-		//		}(),
-		//	})
-		//}
-		newPks = append(newPks, &packet.StartGame{
-			EntityUniqueID:                 pk.EntityUniqueID,
-			EntityRuntimeID:                pk.EntityRuntimeID,
-			PlayerGameMode:                 pk.PlayerGameMode,
-			PlayerPosition:                 pk.PlayerPosition,
-			Pitch:                          pk.Pitch,
-			Yaw:                            pk.Yaw,
-			WorldSeed:                      pk.WorldSeed,
-			SpawnBiomeType:                 pk.SpawnBiomeType,
-			UserDefinedBiomeName:           pk.UserDefinedBiomeName,
-			Dimension:                      pk.Dimension,
-			Generator:                      pk.Generator,
-			WorldGameMode:                  pk.WorldGameMode,
-			Difficulty:                     pk.Difficulty,
-			WorldSpawn:                     pk.WorldSpawn,
-			AchievementsDisabled:           pk.AchievementsDisabled,
-			EditorWorld:                    pk.EditorWorld,
-			CreatedInEditor:                pk.CreatedInEditor,
-			ExportedFromEditor:             pk.ExportedFromEditor,
-			DayCycleLockTime:               pk.DayCycleLockTime,
-			EducationEditionOffer:          pk.EducationEditionOffer,
-			EducationFeaturesEnabled:       pk.EducationFeaturesEnabled,
-			EducationProductID:             pk.EducationProductID,
-			RainLevel:                      pk.RainLevel,
-			LightningLevel:                 pk.LightningLevel,
-			ConfirmedPlatformLockedContent: pk.ConfirmedPlatformLockedContent,
-			MultiPlayerGame:                pk.MultiPlayerGame,
-			LANBroadcastEnabled:            pk.LANBroadcastEnabled,
-			XBLBroadcastMode:               pk.XBLBroadcastMode,
-			PlatformBroadcastMode:          pk.PlatformBroadcastMode,
-			CommandsEnabled:                pk.CommandsEnabled,
-			TexturePackRequired:            pk.TexturePackRequired,
-			GameRules:                      pk.GameRules,
-			Experiments:                    pk.Experiments,
-			ExperimentsPreviouslyToggled:   pk.ExperimentsPreviouslyToggled,
-			BonusChestEnabled:              pk.BonusChestEnabled,
-			StartWithMapEnabled:            pk.StartWithMapEnabled,
-			PlayerPermissions:              pk.PlayerPermissions,
-			ServerChunkTickRadius:          pk.ServerChunkTickRadius,
-			HasLockedBehaviourPack:         pk.HasLockedBehaviourPack,
-			HasLockedTexturePack:           pk.HasLockedTexturePack,
-			FromLockedWorldTemplate:        pk.FromLockedWorldTemplate,
-			MSAGamerTagsOnly:               pk.MSAGamerTagsOnly,
-			FromWorldTemplate:              pk.FromWorldTemplate,
-			WorldTemplateSettingsLocked:    pk.WorldTemplateSettingsLocked,
-			OnlySpawnV1Villagers:           pk.OnlySpawnV1Villagers,
-			PersonaDisabled:                pk.PersonaDisabled,
-			CustomSkinsDisabled:            pk.CustomSkinsDisabled,
-			EmoteChatMuted:                 pk.EmoteChatMuted,
-			BaseGameVersion:                pk.BaseGameVersion,
-			LimitedWorldWidth:              pk.LimitedWorldWidth,
-			LimitedWorldDepth:              pk.LimitedWorldDepth,
-			NewNether:                      pk.NewNether,
-			EducationSharedResourceURI:     pk.EducationSharedResourceURI,
-			ForceExperimentalGameplay:      pk.ForceExperimentalGameplay,
-			LevelID:                        pk.LevelID,
-			WorldName:                      pk.WorldName,
-			TemplateContentIdentity:        pk.TemplateContentIdentity,
-			Trial:                          pk.Trial,
-			PlayerMovementSettings:         pk.PlayerMovementSettings,
-			Time:                           pk.Time,
-			EnchantmentSeed:                pk.EnchantmentSeed,
-			Blocks:                         pk.Blocks,
-			Items:                          pk.Items,
-			MultiPlayerCorrelationID:       pk.MultiPlayerCorrelationID,
-			ServerAuthoritativeInventory:   pk.ServerAuthoritativeInventory,
-			GameVersion:                    pk.GameVersion,
-			PropertyData:                   pk.PropertyData,
-			ServerBlockStateChecksum:       pk.ServerBlockStateChecksum,
-			ClientSideGeneration:           pk.ClientSideGeneration,
-			WorldTemplateID:                pk.WorldTemplateID,
-			ChatRestrictionLevel:           pk.ChatRestrictionLevel,
-			DisablePlayerInteractions:      pk.DisablePlayerInteractions,
-			UseBlockNetworkIDHashes:        pk.UseBlockNetworkIDHashes,
-			ServerAuthoritativeSound:       false,
-		})
-	case *legacypacket.UnlockedRecipes:
-		unlockType := packet.UnlockedRecipesTypeInitiallyUnlocked
-		if pk.NewUnlocks {
-			unlockType = packet.UnlockedRecipesTypeNewlyUnlocked
-		}
-
-		newPks = append(newPks,
-			&packet.UnlockedRecipes{
-				UnlockType: packet.UnlockedRecipesTypeRemoveAllUnlocked,
-			},
-			&packet.UnlockedRecipes{
-				UnlockType: uint32(unlockType),
-				Recipes:    pk.Recipes,
-			})
-	case *legacypacket_v589.AvailableCommands:
-		newPks = append(newPks, &packet.AvailableCommands{
-			EnumValues: pk.EnumValues,
-			Suffixes:   pk.Suffixes,
-			Enums:      pk.Enums,
-			Commands: lo.Map(pk.Commands, func(item types.Command, _ int) protocol.Command {
-				return protocol.Command{
-					Name:            item.Name,
-					Description:     item.Description,
-					Flags:           item.Flags,
-					PermissionLevel: item.PermissionLevel,
-					AliasesOffset:   item.AliasesOffset,
-					Overloads: lo.Map(item.Overloads, func(item types.CommandOverload, _ int) protocol.CommandOverload {
-						return protocol.CommandOverload{
-							Parameters: item.Parameters,
-							Chaining:   false,
-						}
-					}),
-				}
-			}),
-			DynamicEnums: pk.DynamicEnums,
-			Constraints:  pk.Constraints,
-		})
-	default:
-		newPks = append(newPks, pk)
-	}
-	return p.blockTranslator.UpgradeBlockPackets(p.itemTranslator.UpgradeItemPackets(newPks, conn), conn)
+	return p.chain.ConvertToLatest(pk, conn)
 }
 
-func (p Protocol) ConvertFromLatest(pk packet.Packet, conn *minecraft.Conn) (result []packet.Packet) {
-	result = p.blockTranslator.DowngradeBlockPackets(p.itemTranslator.DowngradeItemPackets([]packet.Packet{pk}, conn), conn)
+func (p Protocol) ConvertFromLatest(pk packet.Packet, conn *minecraft.Conn) []packet.Packet {
+	return p.chain.ConvertFromLatest(pk, conn)
+}
 
-	for i, pk := range result {
-		switch pk := pk.(type) {
-		case *packet.Emote:
-			result[i] = &legacypacket.Emote{
-				EntityRuntimeID: pk.EntityRuntimeID,
-				EmoteID:         pk.EmoteID,
-				Flags:           pk.Flags,
-			}
-		case *packet.StartGame:
-			// todo: figure out what to do when there are no custom items
-			//if len(lo.Filter(pk.Items, func(item protocol.ItemEntry, _ int) bool {
-			//	return item.ComponentBased
-			//})) == 0 {
-			//pks = append(pks, &packet.ItemComponent{
-			//	Items: func() (entries []protocol.ItemComponentEntry) {
-			//		for _, item := range p.itemMapping.CustomItems() {
-			//			name, _ := item.EncodeItem()
-			//			entries = append(entries, protocol.ItemComponentEntry{
-			//				Name: name,
-			//				Data: packbuilder.Components(item),
-			//			})
-			//		}
-			//		return entries
-			//	}(),
-			//})
-			//}
-			result[i] = &legacypacket.StartGame{
-				EntityUniqueID:                 pk.EntityUniqueID,
-				EntityRuntimeID:                pk.EntityRuntimeID,
-				PlayerGameMode:                 pk.PlayerGameMode,
-				PlayerPosition:                 pk.PlayerPosition,
-				Pitch:                          pk.Pitch,
-				Yaw:                            pk.Yaw,
-				WorldSeed:                      pk.WorldSeed,
-				SpawnBiomeType:                 pk.SpawnBiomeType,
-				UserDefinedBiomeName:           pk.UserDefinedBiomeName,
-				Dimension:                      pk.Dimension,
-				Generator:                      pk.Generator,
-				WorldGameMode:                  pk.WorldGameMode,
-				Difficulty:                     pk.Difficulty,
-				WorldSpawn:                     pk.WorldSpawn,
-				AchievementsDisabled:           pk.AchievementsDisabled,
-				EditorWorld:                    pk.EditorWorld,
-				CreatedInEditor:                pk.CreatedInEditor,
-				ExportedFromEditor:             pk.ExportedFromEditor,
-				DayCycleLockTime:               pk.DayCycleLockTime,
-				EducationEditionOffer:          pk.EducationEditionOffer,
-				EducationFeaturesEnabled:       pk.EducationFeaturesEnabled,
-				EducationProductID:             pk.EducationProductID,
-				RainLevel:                      pk.RainLevel,
-				LightningLevel:                 pk.LightningLevel,
-				ConfirmedPlatformLockedContent: pk.ConfirmedPlatformLockedContent,
-				MultiPlayerGame:                pk.MultiPlayerGame,
-				LANBroadcastEnabled:            pk.LANBroadcastEnabled,
-				XBLBroadcastMode:               pk.XBLBroadcastMode,
-				PlatformBroadcastMode:          pk.PlatformBroadcastMode,
-				CommandsEnabled:                pk.CommandsEnabled,
-				TexturePackRequired:            pk.TexturePackRequired,
-				GameRules:                      pk.GameRules,
-				Experiments:                    pk.Experiments,
-				ExperimentsPreviouslyToggled:   pk.ExperimentsPreviouslyToggled,
-				BonusChestEnabled:              pk.BonusChestEnabled,
-				StartWithMapEnabled:            pk.StartWithMapEnabled,
-				PlayerPermissions:              pk.PlayerPermissions,
-				ServerChunkTickRadius:          pk.ServerChunkTickRadius,
-				HasLockedBehaviourPack:         pk.HasLockedBehaviourPack,
-				HasLockedTexturePack:           pk.HasLockedTexturePack,
-				FromLockedWorldTemplate:        pk.FromLockedWorldTemplate,
-				MSAGamerTagsOnly:               pk.MSAGamerTagsOnly,
-				FromWorldTemplate:              pk.FromWorldTemplate,
-				WorldTemplateSettingsLocked:    pk.WorldTemplateSettingsLocked,
-				OnlySpawnV1Villagers:           pk.OnlySpawnV1Villagers,
-				PersonaDisabled:                pk.PersonaDisabled,
-				CustomSkinsDisabled:            pk.CustomSkinsDisabled,
-				EmoteChatMuted:                 pk.EmoteChatMuted,
-				BaseGameVersion:                pk.BaseGameVersion,
-				LimitedWorldWidth:              pk.LimitedWorldWidth,
-				LimitedWorldDepth:              pk.LimitedWorldDepth,
-				NewNether:                      pk.NewNether,
-				EducationSharedResourceURI:     pk.EducationSharedResourceURI,
-				ForceExperimentalGameplay:      pk.ForceExperimentalGameplay,
-				LevelID:                        pk.LevelID,
-				WorldName:                      pk.WorldName,
-				TemplateContentIdentity:        pk.TemplateContentIdentity,
-				Trial:                          pk.Trial,
-				PlayerMovementSettings:         pk.PlayerMovementSettings,
-				Time:                           pk.Time,
-				EnchantmentSeed:                pk.EnchantmentSeed,
-				Blocks:                         pk.Blocks,
-				Items:                          pk.Items,
-				MultiPlayerCorrelationID:       pk.MultiPlayerCorrelationID,
-				ServerAuthoritativeInventory:   pk.ServerAuthoritativeInventory,
-				GameVersion:                    pk.GameVersion,
-				PropertyData:                   pk.PropertyData,
-				ServerBlockStateChecksum:       pk.ServerBlockStateChecksum,
-				ClientSideGeneration:           pk.ClientSideGeneration,
-				WorldTemplateID:                pk.WorldTemplateID,
-				ChatRestrictionLevel:           pk.ChatRestrictionLevel,
-				DisablePlayerInteractions:      pk.DisablePlayerInteractions,
-				UseBlockNetworkIDHashes:        pk.UseBlockNetworkIDHashes,
-			}
-		case *packet.UnlockedRecipes:
-			newUnlocks := false
-			if pk.UnlockType == packet.UnlockedRecipesTypeInitiallyUnlocked || pk.UnlockType == packet.UnlockedRecipesTypeNewlyUnlocked {
-				newUnlocks = true
-			}
-			result[i] = &legacypacket.UnlockedRecipes{
-				NewUnlocks: newUnlocks,
-				Recipes:    pk.Recipes,
-			}
-		case *packet.AvailableCommands:
-			result[i] = &legacypacket_v589.AvailableCommands{
-				EnumValues: pk.EnumValues,
-				Suffixes:   pk.Suffixes,
-				Enums:      pk.Enums,
-				Commands: lo.Map(pk.Commands, func(item protocol.Command, _ int) types.Command {
-					return types.Command{
-						Name:            item.Name,
-						Description:     item.Description,
-						Flags:           item.Flags,
-						PermissionLevel: item.PermissionLevel,
-						AliasesOffset:   item.AliasesOffset,
-						Overloads: lo.Map(item.Overloads, func(item protocol.CommandOverload, _ int) types.CommandOverload {
-							return types.CommandOverload{
-								Parameters: item.Parameters,
-							}
-						}),
-					}
-				}),
-				DynamicEnums: pk.DynamicEnums,
-				Constraints:  pk.Constraints,
-			}
-		}
-	}
-	return result
+// Release releases the translation state of the connection passed. It must be called once the connection is closed,
+// as the state is otherwise only released when a Disconnect packet is sent to the connection.
+func (p Protocol) Release(conn *minecraft.Conn) {
+	p.contexts.Release(conn)
+	p.packets.Release(conn)
+	p.blobs.Release(conn)
+	p.entityTranslator.Release(conn)
 }
@@ -0,0 +1,253 @@
+package v582
+
+import (
+	legacypacket "github.com/flonja/multiversion/protocols/v582/packet"
+	"github.com/sandertv/gophertunnel/minecraft"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// Step converts packets between v582 and v589.
+type Step struct{}
+
+func (Step) Upgrade(pk packet.Packet, conn *minecraft.Conn) []packet.Packet {
+	var newPks []packet.Packet
+	switch pk := pk.(type) {
+	case *legacypacket.Emote:
+		newPks = append(newPks, &packet.Emote{
+			EntityRuntimeID: pk.EntityRuntimeID,
+			EmoteID:         pk.EmoteID,
+			XUID:            conn.IdentityData().XUID,
+			PlatformID:      conn.ClientData().PlatformOnlineID,
+			Flags:           pk.Flags,
+		})
+	case *legacypacket.StartGame:
+		// todo: figure out what to do when there are no custom items
+		//if len(lo.Filter(pk.Items, func(item protocol.ItemEntry, _ int) bool {
+		//	return item.ComponentBased
+		//})) == 0 {
+		//	pks = append(pks, &packet.ItemComponent{
+		//		Items: func() (entries []protocol.ItemComponentEntry) {
+		//			for _, item := range p.itemMapping.CustomItems() {
+		//				name, _ := item.EncodeItem()
+		//				entries = append(entries, protocol.ItemComponentEntry{
+		//					Name: name,
+		//					Data: packbuilder.Components(item),
+		//				})
+		//			}
+		//			return entries
+		//		}(),
+		//	})
+		//}
+		newPks = append(newPks, &packet.StartGame{
+			EntityUniqueID:                 pk.EntityUniqueID,
+			EntityRuntimeID:                pk.EntityRuntimeID,
+			PlayerGameMode:                 pk.PlayerGameMode,
+			PlayerPosition:                 pk.PlayerPosition,
+			Pitch:                          pk.Pitch,
+			Yaw:                            pk.Yaw,
+			WorldSeed:                      pk.WorldSeed,
+			SpawnBiomeType:                 pk.SpawnBiomeType,
+			UserDefinedBiomeName:           pk.UserDefinedBiomeName,
+			Dimension:                      pk.Dimension,
+			Generator:                      pk.Generator,
+			WorldGameMode:                  pk.WorldGameMode,
+			Difficulty:                     pk.Difficulty,
+			WorldSpawn:                     pk.WorldSpawn,
+			AchievementsDisabled:           pk.AchievementsDisabled,
+			EditorWorld:                    pk.EditorWorld,
+			CreatedInEditor:                pk.CreatedInEditor,
+			ExportedFromEditor:             pk.ExportedFromEditor,
+			DayCycleLockTime:               pk.DayCycleLockTime,
+			EducationEditionOffer:          pk.EducationEditionOffer,
+			EducationFeaturesEnabled:       pk.EducationFeaturesEnabled,
+			EducationProductID:             pk.EducationProductID,
+			RainLevel:                      pk.RainLevel,
+			LightningLevel:                 pk.LightningLevel,
+			ConfirmedPlatformLockedContent: pk.ConfirmedPlatformLockedContent,
+			MultiPlayerGame:                pk.MultiPlayerGame,
+			LANBroadcastEnabled:            pk.LANBroadcastEnabled,
+			XBLBroadcastMode:               pk.XBLBroadcastMode,
+			PlatformBroadcastMode:          pk.PlatformBroadcastMode,
+			CommandsEnabled:                pk.CommandsEnabled,
+			TexturePackRequired:            pk.TexturePackRequired,
+			GameRules:                      pk.GameRules,
+			Experiments:                    pk.Experiments,
+			ExperimentsPreviouslyToggled:   pk.ExperimentsPreviouslyToggled,
+			BonusChestEnabled:              pk.BonusChestEnabled,
+			StartWithMapEnabled:            pk.StartWithMapEnabled,
+			PlayerPermissions:              pk.PlayerPermissions,
+			ServerChunkTickRadius:          pk.ServerChunkTickRadius,
+			HasLockedBehaviourPack:         pk.HasLockedBehaviourPack,
+			HasLockedTexturePack:           pk.HasLockedTexturePack,
+			FromLockedWorldTemplate:        pk.FromLockedWorldTemplate,
+			MSAGamerTagsOnly:               pk.MSAGamerTagsOnly,
+			FromWorldTemplate:              pk.FromWorldTemplate,
+			WorldTemplateSettingsLocked:    pk.WorldTemplateSettingsLocked,
+			OnlySpawnV1Villagers:           pk.OnlySpawnV1Villagers,
+			PersonaDisabled:                pk.PersonaDisabled,
+			CustomSkinsDisabled:            pk.CustomSkinsDisabled,
+			EmoteChatMuted:                 pk.EmoteChatMuted,
+			BaseGameVersion:                pk.BaseGameVersion,
+			LimitedWorldWidth:              pk.LimitedWorldWidth,
+			LimitedWorldDepth:              pk.LimitedWorldDepth,
+			NewNether:                      pk.NewNether,
+			EducationSharedResourceURI:     pk.EducationSharedResourceURI,
+			ForceExperimentalGameplay:      pk.ForceExperimentalGameplay,
+			LevelID:                        pk.LevelID,
+			WorldName:                      pk.WorldName,
+			TemplateContentIdentity:        pk.TemplateContentIdentity,
+			Trial:                          pk.Trial,
+			PlayerMovementSettings:         pk.PlayerMovementSettings,
+			Time:                           pk.Time,
+			EnchantmentSeed:                pk.EnchantmentSeed,
+			Blocks:                         pk.Blocks,
+			Items:                          pk.Items,
+			MultiPlayerCorrelationID:       pk.MultiPlayerCorrelationID,
+			ServerAuthoritativeInventory:   pk.ServerAuthoritativeInventory,
+			GameVersion:                    pk.GameVersion,
+			PropertyData:                   pk.PropertyData,
+			ServerBlockStateChecksum:       pk.ServerBlockStateChecksum,
+			ClientSideGeneration:           pk.ClientSideGeneration,
+			WorldTemplateID:                pk.WorldTemplateID,
+			ChatRestrictionLevel:           pk.ChatRestrictionLevel,
+			DisablePlayerInteractions:      pk.DisablePlayerInteractions,
+			UseBlockNetworkIDHashes:        pk.UseBlockNetworkIDHashes,
+			ServerAuthoritativeSound:       false,
+		})
+	case *legacypacket.UnlockedRecipes:
+		unlockType := packet.UnlockedRecipesTypeInitiallyUnlocked
+		if pk.NewUnlocks {
+			unlockType = packet.UnlockedRecipesTypeNewlyUnlocked
+		}
+
+		newPks = append(newPks,
+			&packet.UnlockedRecipes{
+				UnlockType: packet.UnlockedRecipesTypeRemoveAllUnlocked,
+			},
+			&packet.UnlockedRecipes{
+				UnlockType: uint32(unlockType),
+				Recipes:    pk.Recipes,
+			})
+	default:
+		newPks = append(newPks, pk)
+	}
+	return newPks
+}
+
+func (Step) Downgrade(pk packet.Packet, _ *minecraft.Conn) []packet.Packet {
+	result := []packet.Packet{pk}
+
+	for i, pk := range result {
+		switch pk := pk.(type) {
+		case *packet.Emote:
+			result[i] = &legacypacket.Emote{
+				EntityRuntimeID: pk.EntityRuntimeID,
+				EmoteID:         pk.EmoteID,
+				Flags:           pk.Flags,
+			}
+		case *packet.StartGame:
+			// todo: figure out what to do when there are no custom items
+			//if len(lo.Filter(pk.Items, func(item protocol.ItemEntry, _ int) bool {
+			//	return item.ComponentBased
+			//})) == 0 {
+			//pks = append(pks, &packet.ItemComponent{
+			//	Items: func() (entries []protocol.ItemComponentEntry) {
+			//		for _, item := range p.itemMapping.CustomItems() {
+			//			name, _ := item.EncodeItem()
+			//			entries = append(entries, protocol.ItemComponentEntry{
+			//				Name: name,
+			//				Data: packbuilder.Components(item),
+			//			})
+			//		}
+			//		return entries
+			//	}(),
+			//})
+			//}
+			result[i] = &legacypacket.StartGame{
+				EntityUniqueID:                 pk.EntityUniqueID,
+				EntityRuntimeID:                pk.EntityRuntimeID,
+				PlayerGameMode:                 pk.PlayerGameMode,
+				PlayerPosition:                 pk.PlayerPosition,
+				Pitch:                          pk.Pitch,
+				Yaw:                            pk.Yaw,
+				WorldSeed:                      pk.WorldSeed,
+				SpawnBiomeType:                 pk.SpawnBiomeType,
+				UserDefinedBiomeName:           pk.UserDefinedBiomeName,
+				Dimension:                      pk.Dimension,
+				Generator:                      pk.Generator,
+				WorldGameMode:                  pk.WorldGameMode,
+				Difficulty:                     pk.Difficulty,
+				WorldSpawn:                     pk.WorldSpawn,
+				AchievementsDisabled:           pk.AchievementsDisabled,
+				EditorWorld:                    pk.EditorWorld,
+				CreatedInEditor:                pk.CreatedInEditor,
+				ExportedFromEditor:             pk.ExportedFromEditor,
+				DayCycleLockTime:               pk.DayCycleLockTime,
+				EducationEditionOffer:          pk.EducationEditionOffer,
+				EducationFeaturesEnabled:       pk.EducationFeaturesEnabled,
+				EducationProductID:             pk.EducationProductID,
+				RainLevel:                      pk.RainLevel,
+				LightningLevel:                 pk.LightningLevel,
+				ConfirmedPlatformLockedContent: pk.ConfirmedPlatformLockedContent,
+				MultiPlayerGame:                pk.MultiPlayerGame,
+				LANBroadcastEnabled:            pk.LANBroadcastEnabled,
+				XBLBroadcastMode:               pk.XBLBroadcastMode,
+				PlatformBroadcastMode:          pk.PlatformBroadcastMode,
+				CommandsEnabled:                pk.CommandsEnabled,
+				TexturePackRequired:            pk.TexturePackRequired,
+				GameRules:                      pk.GameRules,
+				Experiments:                    pk.Experiments,
+				ExperimentsPreviouslyToggled:   pk.ExperimentsPreviouslyToggled,
+				BonusChestEnabled:              pk.BonusChestEnabled,
+				StartWithMapEnabled:            pk.StartWithMapEnabled,
+				PlayerPermissions:              pk.PlayerPermissions,
+				ServerChunkTickRadius:          pk.ServerChunkTickRadius,
+				HasLockedBehaviourPack:         pk.HasLockedBehaviourPack,
+				HasLockedTexturePack:           pk.HasLockedTexturePack,
+				FromLockedWorldTemplate:        pk.FromLockedWorldTemplate,
+				MSAGamerTagsOnly:               pk.MSAGamerTagsOnly,
+				FromWorldTemplate:              pk.FromWorldTemplate,
+				WorldTemplateSettingsLocked:    pk.WorldTemplateSettingsLocked,
+				OnlySpawnV1Villagers:           pk.OnlySpawnV1Villagers,
+				PersonaDisabled:                pk.PersonaDisabled,
+				CustomSkinsDisabled:            pk.CustomSkinsDisabled,
+				EmoteChatMuted:                 pk.EmoteChatMuted,
+				BaseGameVersion:                pk.BaseGameVersion,
+				LimitedWorldWidth:              pk.LimitedWorldWidth,
+				LimitedWorldDepth:              pk.LimitedWorldDepth,
+				NewNether:                      pk.NewNether,
+				EducationSharedResourceURI:     pk.EducationSharedResourceURI,
+				ForceExperimentalGameplay:      pk.ForceExperimentalGameplay,
+				LevelID:                        pk.LevelID,
+				WorldName:                      pk.WorldName,
+				TemplateContentIdentity:        pk.TemplateContentIdentity,
+				Trial:                          pk.Trial,
+				PlayerMovementSettings:         pk.PlayerMovementSettings,
+				Time:                           pk.Time,
+				EnchantmentSeed:                pk.EnchantmentSeed,
+				Blocks:                         pk.Blocks,
+				Items:                          pk.Items,
+				MultiPlayerCorrelationID:       pk.MultiPlayerCorrelationID,
+				ServerAuthoritativeInventory:   pk.ServerAuthoritativeInventory,
+				GameVersion:                    pk.GameVersion,
+				PropertyData:                   pk.PropertyData,
+				ServerBlockStateChecksum:       pk.ServerBlockStateChecksum,
+				ClientSideGeneration:           pk.ClientSideGeneration,
+				WorldTemplateID:                pk.WorldTemplateID,
+				ChatRestrictionLevel:           pk.ChatRestrictionLevel,
+				DisablePlayerInteractions:      pk.DisablePlayerInteractions,
+				UseBlockNetworkIDHashes:        pk.UseBlockNetworkIDHashes,
+			}
+		case *packet.UnlockedRecipes:
+			newUnlocks := false
+			if pk.UnlockType == packet.UnlockedRecipesTypeInitiallyUnlocked || pk.UnlockType == packet.UnlockedRecipesTypeNewlyUnlocked {
+				newUnlocks = true
+			}
+			result[i] = &legacypacket.UnlockedRecipes{
+				NewUnlocks: newUnlocks,
+				Recipes:    pk.Recipes,
+			}
+		}
+	}
+	return result
+}
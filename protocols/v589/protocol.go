@@ -3,15 +3,15 @@ package v486
 import (
 	_ "embed"
 	"github.com/flonja/multiversion/mapping"
+	"github.com/flonja/multiversion/protocols/chain"
 	"github.com/flonja/multiversion/protocols/latest"
 	legacypacket "github.com/flonja/multiversion/protocols/v589/packet"
-	"github.com/flonja/multiversion/protocols/v589/types"
 	"github.com/flonja/multiversion/translator"
-	"github.com/samber/lo"
 	"github.com/sandertv/gophertunnel/minecraft"
 	"github.com/sandertv/gophertunnel/minecraft/protocol"
 	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
 	"io"
+	"time"
 )
 
 var (
@@ -21,20 +21,49 @@ var (
 	blockStateData []byte
 )
 
+// unknownPackets holds the IDs of the packets of the latest version that were added after 1.20.0.
+var unknownPackets = []uint32{packet.IDAgentAnimation}
+
+// blobTimeout is the time after which chunks waiting for their blobs are dropped.
+const blobTimeout = time.Second * 5
+
 type Protocol struct {
 	itemMapping     mapping.Item
 	blockMapping    mapping.Block
 	itemTranslator  translator.ItemTranslator
 	blockTranslator translator.BlockTranslator
+	contexts        *translator.Contexts
+	blobs           *translator.BlobCache
+	packets         *translator.PacketFilter
+	chain           *chain.ChainedProtocol
 }
 
 func New() *Protocol {
 	itemMapping := mapping.NewItemMapping(itemRuntimeIDData, 121)
 	blockMapping := mapping.NewBlockMapping(blockStateData)
 	latestBlockMapping := latest.NewBlockMapping()
-	return &Protocol{itemMapping: itemMapping, blockMapping: blockMapping,
+	p := &Protocol{itemMapping: itemMapping, blockMapping: blockMapping,
 		itemTranslator:  translator.NewItemTranslator(itemMapping, latest.NewItemMapping(), blockMapping, latestBlockMapping),
-		blockTranslator: translator.NewBlockTranslator(blockMapping, latestBlockMapping)}
+		blockTranslator: translator.NewBlockTranslator(blockMapping, latestBlockMapping, latest.NewBiomeMapping(), latest.NewBiomeMapping())}
+	p.contexts = translator.NewContexts(p.itemTranslator, p.blockTranslator)
+	p.blobs = translator.NewBlobCache(blobTimeout)
+	p.packets = translator.NewPacketFilter(unknownPackets, translator.NewEmulator())
+	p.chain = chain.NewChainedProtocol(p, Step{}, chain.TranslatorStep{Contexts: p.contexts, Blobs: p.blobs}).WithPacketFilter(p.packets)
+	return p
+}
+
+// WithUnknownPacketFunc sets the function called for packets that do not exist in this version, right before they are
+// dropped. It may return packets of this version that are sent in their place.
+func (p *Protocol) WithUnknownPacketFunc(f translator.UnknownPacketFunc) *Protocol {
+	p.packets.SetUnknownPacketFunc(f)
+	return p
+}
+
+// WithEmulator sets the emulator used to emulate packets that do not exist in this version. By default, toasts, NPC
+// dialogues, sign editing and camera fades are emulated. If nil, packets are never emulated.
+func (p *Protocol) WithEmulator(emulator translator.Emulator) *Protocol {
+	p.packets.SetEmulator(emulator)
+	return p
 }
 
 func (p Protocol) ID() int32 {
@@ -45,13 +74,10 @@ func (p Protocol) Ver() string {
 	return "1.20.1"
 }
 
-func (Protocol) Packets(_ bool) packet.Pool {
-	pool := packet.NewClientPool()
-	for k, v := range packet.NewServerPool() {
-		pool[k] = v
-	}
-	pool[packet.IDAvailableCommands] = func() packet.Packet { return &legacypacket.AvailableCommands{} }
-	return pool
+func (p Protocol) Packets(_ bool) packet.Pool {
+	return p.packets.Pool(packet.Pool{
+		packet.IDAvailableCommands: func() packet.Packet { return &legacypacket.AvailableCommands{} },
+	})
 }
 
 func (Protocol) Encryption(key [32]byte) packet.Encryption {
@@ -73,67 +99,17 @@ func (Protocol) NewWriter(w interface {
 }
 
 func (p Protocol) ConvertToLatest(pk packet.Packet, conn *minecraft.Conn) []packet.Packet {
-	var newPks []packet.Packet
-	switch pk := pk.(type) {
-	case *legacypacket.AvailableCommands:
-		newPks = append(newPks, &packet.AvailableCommands{
-			EnumValues: pk.EnumValues,
-			Suffixes:   pk.Suffixes,
-			Enums:      pk.Enums,
-			Commands: lo.Map(pk.Commands, func(item types.Command, _ int) protocol.Command {
-				return protocol.Command{
-					Name:            item.Name,
-					Description:     item.Description,
-					Flags:           item.Flags,
-					PermissionLevel: item.PermissionLevel,
-					AliasesOffset:   item.AliasesOffset,
-					Overloads: lo.Map(item.Overloads, func(item types.CommandOverload, _ int) protocol.CommandOverload {
-						return protocol.CommandOverload{
-							Parameters: item.Parameters,
-							Chaining:   false,
-						}
-					}),
-				}
-			}),
-			DynamicEnums: pk.DynamicEnums,
-			Constraints:  pk.Constraints,
-		})
-	default:
-		newPks = append(newPks, pk)
-	}
-
-	return p.blockTranslator.UpgradeBlockPackets(p.itemTranslator.UpgradeItemPackets(newPks, conn), conn)
+	return p.chain.ConvertToLatest(pk, conn)
+}
+
+func (p Protocol) ConvertFromLatest(pk packet.Packet, conn *minecraft.Conn) []packet.Packet {
+	return p.chain.ConvertFromLatest(pk, conn)
 }
 
-func (p Protocol) ConvertFromLatest(pk packet.Packet, conn *minecraft.Conn) (result []packet.Packet) {
-	result = p.blockTranslator.DowngradeBlockPackets(p.itemTranslator.DowngradeItemPackets([]packet.Packet{pk}, conn), conn)
-
-	for i, pk := range result {
-		switch pk := pk.(type) {
-		case *packet.AvailableCommands:
-			result[i] = &legacypacket.AvailableCommands{
-				EnumValues: pk.EnumValues,
-				Suffixes:   pk.Suffixes,
-				Enums:      pk.Enums,
-				Commands: lo.Map(pk.Commands, func(item protocol.Command, _ int) types.Command {
-					return types.Command{
-						Name:            item.Name,
-						Description:     item.Description,
-						Flags:           item.Flags,
-						PermissionLevel: item.PermissionLevel,
-						AliasesOffset:   item.AliasesOffset,
-						Overloads: lo.Map(item.Overloads, func(item protocol.CommandOverload, _ int) types.CommandOverload {
-							return types.CommandOverload{
-								Parameters: item.Parameters,
-							}
-						}),
-					}
-				}),
-				DynamicEnums: pk.DynamicEnums,
-				Constraints:  pk.Constraints,
-			}
-		}
-	}
-
-	return result
+// Release releases the translation state of the connection passed. It must be called once the connection is closed,
+// as the state is otherwise only released when a Disconnect packet is sent to the connection.
+func (p Protocol) Release(conn *minecraft.Conn) {
+	p.contexts.Release(conn)
+	p.packets.Release(conn)
+	p.blobs.Release(conn)
 }
@@ -0,0 +1,70 @@
+package v486
+
+import (
+	legacypacket "github.com/flonja/multiversion/protocols/v589/packet"
+	"github.com/flonja/multiversion/protocols/v589/types"
+	"github.com/samber/lo"
+	"github.com/sandertv/gophertunnel/minecraft"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// Step converts packets between v589 and the latest version.
+type Step struct{}
+
+func (Step) Upgrade(pk packet.Packet, _ *minecraft.Conn) []packet.Packet {
+	switch pk := pk.(type) {
+	case *legacypacket.AvailableCommands:
+		return []packet.Packet{&packet.AvailableCommands{
+			EnumValues: pk.EnumValues,
+			Suffixes:   pk.Suffixes,
+			Enums:      pk.Enums,
+			Commands: lo.Map(pk.Commands, func(item types.Command, _ int) protocol.Command {
+				return protocol.Command{
+					Name:            item.Name,
+					Description:     item.Description,
+					Flags:           item.Flags,
+					PermissionLevel: item.PermissionLevel,
+					AliasesOffset:   item.AliasesOffset,
+					Overloads: lo.Map(item.Overloads, func(item types.CommandOverload, _ int) protocol.CommandOverload {
+						return protocol.CommandOverload{
+							Parameters: item.Parameters,
+							Chaining:   false,
+						}
+					}),
+				}
+			}),
+			DynamicEnums: pk.DynamicEnums,
+			Constraints:  pk.Constraints,
+		}}
+	}
+	return []packet.Packet{pk}
+}
+
+func (Step) Downgrade(pk packet.Packet, _ *minecraft.Conn) []packet.Packet {
+	switch pk := pk.(type) {
+	case *packet.AvailableCommands:
+		return []packet.Packet{&legacypacket.AvailableCommands{
+			EnumValues: pk.EnumValues,
+			Suffixes:   pk.Suffixes,
+			Enums:      pk.Enums,
+			Commands: lo.Map(pk.Commands, func(item protocol.Command, _ int) types.Command {
+				return types.Command{
+					Name:            item.Name,
+					Description:     item.Description,
+					Flags:           item.Flags,
+					PermissionLevel: item.PermissionLevel,
+					AliasesOffset:   item.AliasesOffset,
+					Overloads: lo.Map(item.Overloads, func(item protocol.CommandOverload, _ int) types.CommandOverload {
+						return types.CommandOverload{
+							Parameters: item.Parameters,
+						}
+					}),
+				}
+			}),
+			DynamicEnums: pk.DynamicEnums,
+			Constraints:  pk.Constraints,
+		}}
+	}
+	return []packet.Packet{pk}
+}
@@ -0,0 +1,354 @@
+package translator
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cespare/xxhash"
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"github.com/flonja/multiversion/internal/chunk"
+	"github.com/sandertv/gophertunnel/minecraft"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// maxBlobs is the maximum number of translated blobs kept by a blob store. The oldest blobs are dropped once the
+// store holds more blobs.
+const maxBlobs = 4096
+
+// BlobCache translates the blobs of the client blob cache for legacy clients. Blobs are translated once and stored
+// under the hash of their translated payload, so that the blob hashes sent to the client always match the payloads.
+//
+// Chunks referring to blobs that were not translated yet are held back while the blobs are requested from the server
+// on behalf of the client. These requests are sent to the server along with the next packet the client sends, so the
+// cache must be used in both directions. Chunks of which the blobs did not arrive within the timeout are dropped.
+// Blobs missed by the client are requested from the server under their original hash, and translated when they arrive.
+type BlobCache struct {
+	timeout time.Duration
+	// shared holds the blobs translated for all connections without custom blocks.
+	shared *blobStore
+
+	mu    sync.Mutex
+	conns map[*minecraft.Conn]*blobRequests
+}
+
+// blobStore holds translated blobs, indexed by their hash.
+type blobStore struct {
+	// hashes holds the hashes of translated blobs, indexed by the hash of the original blob.
+	hashes map[uint64]uint64
+	// originals holds the hashes of original blobs, indexed by the hash of the translated blob.
+	originals map[uint64]uint64
+	// blobs holds the payloads of translated blobs, indexed by their hash.
+	blobs map[uint64][]byte
+	// order holds the hashes of the original blobs in the order they were stored.
+	order []uint64
+}
+
+// blobRequests holds the blobs of a single connection that are waiting to be translated.
+type blobRequests struct {
+	// store is the blob store used for the connection.
+	store *blobStore
+	// kinds holds the kinds of the blobs that were not translated yet, indexed by the hash of the original blob.
+	kinds map[uint64]blobKind
+	// unsent holds the hashes of the blobs that were not requested from the server yet.
+	unsent []uint64
+	// held holds the chunk packets waiting for their blobs, in the order they were sent.
+	held []heldChunk
+	// r is the height range of the dimension the connection is in.
+	r cube.Range
+}
+
+// heldChunk is a chunk packet held back until its blobs are translated.
+type heldChunk struct {
+	pk packet.Packet
+	// deadline is the time after which the chunk is dropped if its blobs did not arrive.
+	deadline time.Time
+}
+
+// blobKind describes the contents of a blob.
+type blobKind struct {
+	// biomes is true if the blob holds the biomes of a chunk, and false if it holds a sub chunk.
+	biomes bool
+	// index is the index of the sub chunk held by the blob.
+	index byte
+}
+
+// NewBlobCache creates a new blob cache. Chunks of which the blobs did not arrive within the timeout passed are
+// dropped, so that they are not held for the rest of the connection if the server never sends the blobs.
+func NewBlobCache(timeout time.Duration) *BlobCache {
+	return &BlobCache{timeout: timeout, shared: newBlobStore(), conns: make(map[*minecraft.Conn]*blobRequests)}
+}
+
+// newBlobStore creates an empty blob store.
+func newBlobStore() *blobStore {
+	return &blobStore{hashes: make(map[uint64]uint64), originals: make(map[uint64]uint64), blobs: make(map[uint64][]byte)}
+}
+
+// DowngradeBlobPackets rewrites the blob hashes of chunks sent through the client cache to those of the translated
+// blobs, holding back chunks whose blobs were not translated yet. Blobs sent by the server are translated using the
+// block translator and dimension of the context passed.
+func (b *BlobCache) DowngradeBlobPackets(pks []packet.Packet, ctx *Context, conn *minecraft.Conn) (result []packet.Packet) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	r := ctx.Dimension.Range()
+	b.requests(conn).expire()
+	for _, pk := range pks {
+		switch pk := pk.(type) {
+		case *packet.StartGame:
+			store := b.shared
+			if len(pk.Blocks) > 0 {
+				// Custom blocks change the translation of blobs, so they can't be shared with other connections.
+				store = newBlobStore()
+			}
+			b.conns[conn] = &blobRequests{store: store, kinds: make(map[uint64]blobKind)}
+		case *packet.LevelChunk:
+			if pk.CacheEnabled && !b.requests(conn).hold(pk, r, b.timeout) {
+				continue
+			}
+		case *packet.SubChunk:
+			if pk.CacheEnabled && !b.requests(conn).hold(pk, r, b.timeout) {
+				continue
+			}
+		case *packet.ClientCacheMissResponse:
+			requests := b.requests(conn)
+			blobs := pk.Blobs[:0]
+			for _, blob := range pk.Blobs {
+				if kind, ok := requests.kinds[blob.Hash]; ok {
+					// The blob was requested on behalf of the client, so it is only stored.
+					delete(requests.kinds, blob.Hash)
+					requests.store.add(blob.Hash, translateBlob(blob.Payload, kind, ctx.Blocks, r))
+					continue
+				}
+				if hash, ok := requests.store.hashes[blob.Hash]; ok {
+					blob = protocol.CacheBlob{Hash: hash, Payload: requests.store.blobs[hash]}
+				}
+				blobs = append(blobs, blob)
+			}
+			pk.Blobs = blobs
+
+			result = append(result, requests.release()...)
+			if len(pk.Blobs) == 0 {
+				continue
+			}
+		case *packet.ChangeDimension:
+			// Chunks held back belong to the previous dimension, so they are dropped.
+			b.requests(conn).held = nil
+		case *packet.Disconnect:
+			delete(b.conns, conn)
+		}
+		result = append(result, pk)
+	}
+	return result
+}
+
+// UpgradeBlobPackets rewrites the hashes of the blobs the client reports to those of the original blobs. Blobs missed
+// by the client are sent by the server, and replaced by the translated blobs when the response is downgraded. Blobs
+// that need to be translated are requested from the server along with the packets passed.
+func (b *BlobCache) UpgradeBlobPackets(pks []packet.Packet, conn *minecraft.Conn) []packet.Packet {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	requests, ok := b.conns[conn]
+	if !ok {
+		return pks
+	}
+	requests.expire()
+	for _, pk := range pks {
+		if status, ok := pk.(*packet.ClientCacheBlobStatus); ok {
+			status.MissHashes = requests.store.originalHashes(status.MissHashes)
+			status.HitHashes = requests.store.originalHashes(status.HitHashes)
+		}
+	}
+	if len(requests.unsent) > 0 {
+		pks = append(pks, &packet.ClientCacheBlobStatus{MissHashes: requests.unsent})
+		requests.unsent = nil
+	}
+	return pks
+}
+
+// Release releases the blobs and chunks held back for the connection passed. It should be called for connections
+// that were closed without a Disconnect packet being sent.
+func (b *BlobCache) Release(conn *minecraft.Conn) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.conns, conn)
+}
+
+// requests returns the blob requests of the connection passed. Connections that did not start the game yet use the
+// shared blob store.
+func (b *BlobCache) requests(conn *minecraft.Conn) *blobRequests {
+	requests, ok := b.conns[conn]
+	if !ok {
+		requests = &blobRequests{store: b.shared, kinds: make(map[uint64]blobKind)}
+		b.conns[conn] = requests
+	}
+	return requests
+}
+
+// hold rewrites the blob hashes of the chunk packet passed to those of the translated blobs. If some of the blobs were
+// not translated yet, the packet is held back until they arrive or the timeout passed, in which case hold returns
+// false.
+func (s *blobRequests) hold(pk packet.Packet, r cube.Range, timeout time.Duration) bool {
+	s.r = r
+	if s.resolve(pk) {
+		return true
+	}
+	s.held = append(s.held, heldChunk{pk: pk, deadline: time.Now().Add(timeout)})
+	return false
+}
+
+// resolve rewrites the blob hashes of the chunk packet passed to those of the translated blobs. If some of the blobs
+// were not translated yet, they are requested and resolve returns false.
+func (s *blobRequests) resolve(pk packet.Packet) bool {
+	complete := true
+	s.visit(pk, func(hash uint64, kind blobKind) {
+		if _, ok := s.store.hashes[hash]; ok {
+			return
+		}
+		complete = false
+		if _, ok := s.kinds[hash]; !ok {
+			s.kinds[hash] = kind
+			s.unsent = append(s.unsent, hash)
+		}
+	})
+	if !complete {
+		return false
+	}
+	switch pk := pk.(type) {
+	case *packet.LevelChunk:
+		for i, hash := range pk.BlobHashes {
+			pk.BlobHashes[i] = s.store.hashes[hash]
+		}
+	case *packet.SubChunk:
+		for i, entry := range pk.SubChunkEntries {
+			if entry.Result == protocol.SubChunkResultSuccess {
+				pk.SubChunkEntries[i].BlobHash = s.store.hashes[entry.BlobHash]
+			}
+		}
+	}
+	return true
+}
+
+// release returns the held chunk packets whose blobs were all translated.
+func (s *blobRequests) release() (result []packet.Packet) {
+	held := s.held[:0]
+	for _, c := range s.held {
+		if s.resolve(c.pk) {
+			result = append(result, c.pk)
+			continue
+		}
+		held = append(held, c)
+	}
+	s.held = held
+	return result
+}
+
+// expire drops the held chunk packets of which the blobs did not arrive before their deadline. Blobs only needed by
+// the dropped packets are forgotten, so that they are requested again if another chunk refers to them.
+func (s *blobRequests) expire() {
+	now := time.Now()
+	held := s.held[:0]
+	for _, c := range s.held {
+		if now.Before(c.deadline) {
+			held = append(held, c)
+		}
+	}
+	if len(held) == len(s.held) {
+		return
+	}
+	s.held = held
+
+	needed := make(map[uint64]struct{})
+	for _, c := range s.held {
+		s.visit(c.pk, func(hash uint64, _ blobKind) {
+			needed[hash] = struct{}{}
+		})
+	}
+	for hash := range s.kinds {
+		if _, ok := needed[hash]; !ok {
+			delete(s.kinds, hash)
+		}
+	}
+	unsent := s.unsent[:0]
+	for _, hash := range s.unsent {
+		if _, ok := needed[hash]; ok {
+			unsent = append(unsent, hash)
+		}
+	}
+	s.unsent = unsent
+}
+
+// visit calls the function passed for the hash and kind of every blob the chunk packet passed refers to.
+func (s *blobRequests) visit(pk packet.Packet, f func(hash uint64, kind blobKind)) {
+	r := s.r
+	switch pk := pk.(type) {
+	case *packet.LevelChunk:
+		// The biomes are always held by the last blob, after the blobs of the sub chunks.
+		for i, hash := range pk.BlobHashes {
+			f(hash, blobKind{biomes: i == len(pk.BlobHashes)-1, index: byte(i)})
+		}
+	case *packet.SubChunk:
+		for _, entry := range pk.SubChunkEntries {
+			if entry.Result == protocol.SubChunkResultSuccess {
+				f(entry.BlobHash, blobKind{index: byte(int(pk.Position.Y()) + int(entry.Offset[1]) - (r[0] >> 4))})
+			}
+		}
+	}
+}
+
+// originalHashes replaces the hashes of translated blobs passed with the hashes of the original blobs.
+func (s *blobStore) originalHashes(hashes []uint64) []uint64 {
+	for i, hash := range hashes {
+		if original, ok := s.originals[hash]; ok {
+			hashes[i] = original
+		}
+	}
+	return hashes
+}
+
+// add stores the translated payload of the blob with the hash passed, dropping the oldest blobs if the store is full.
+func (s *blobStore) add(original uint64, payload []byte) {
+	if _, ok := s.hashes[original]; ok {
+		return
+	}
+	hash := xxhash.Sum64(payload)
+	s.hashes[original], s.originals[hash], s.blobs[hash] = hash, original, payload
+	s.order = append(s.order, original)
+
+	for len(s.order) > maxBlobs {
+		oldest := s.order[0]
+		s.order = s.order[1:]
+		if hash := s.hashes[oldest]; s.originals[hash] == oldest {
+			delete(s.originals, hash)
+			delete(s.blobs, hash)
+		}
+		delete(s.hashes, oldest)
+	}
+}
+
+// translateBlob translates the payload of a blob of the kind passed. The payload is returned untouched if it could
+// not be decoded.
+func translateBlob(payload []byte, kind blobKind, blocks BlockTranslator, r cube.Range) []byte {
+	_, latest := blocks.BlockMappings()
+	buf := bytes.NewBuffer(payload)
+	if kind.biomes {
+		c, err := chunk.NetworkDecode(latest.Air(), buf, 0, false, r)
+		if err != nil {
+			fmt.Println(err)
+			return payload
+		}
+		replaceBiomes(c.BiomeSub(), blocks.DowngradeBiomeID)
+		return append(chunk.EncodeBiomes(c, chunk.NetworkEncoding), buf.Bytes()...)
+	}
+	ind := kind.index
+	sub, err := chunk.DecodeSubChunk(latest.Air(), r, buf, &ind, chunk.NetworkEncoding)
+	if err != nil {
+		fmt.Println(err)
+		return payload
+	}
+	blocks.DowngradeSubChunk(sub)
+	return append(chunk.EncodeSubChunk(sub, chunk.NetworkEncoding, chunk.SubChunkVersion9, r, int(ind)), buf.Bytes()...)
+}
@@ -0,0 +1,100 @@
+package translator
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cespare/xxhash"
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/flonja/multiversion/internal/chunk"
+	"github.com/sandertv/gophertunnel/minecraft"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// newTestBlobs returns the blobs of a chunk in the overworld with a single sub chunk, followed by its biomes.
+func newTestBlobs(blocks BlockTranslator) []protocol.CacheBlob {
+	_, latest := blocks.BlockMappings()
+	r := world.Overworld.Range()
+	c := newTestChunk(latest)
+	blobs := []protocol.CacheBlob{
+		{Payload: chunk.EncodeSubChunk(c.Sub()[0], chunk.NetworkEncoding, chunk.SubChunkVersion9, r, 0)},
+		{Payload: chunk.EncodeBiomes(c, chunk.NetworkEncoding)},
+	}
+	for i := range blobs {
+		// The hashes of the server differ from those of the translated blobs.
+		blobs[i].Hash = xxhash.Sum64(blobs[i].Payload) + 1
+	}
+	return blobs
+}
+
+// TestBlobCacheTranslatesBlobs checks that chunks are held back until their blobs are translated, and that blobs
+// missed by the client are requested from the server under their original hash and translated when they arrive.
+func TestBlobCacheTranslatesBlobs(t *testing.T) {
+	b, conn := NewBlobCache(time.Minute), &minecraft.Conn{}
+	ctx := &Context{Blocks: newTestBlockTranslator(), Dimension: NewDimension(packet.DimensionOverworld)}
+	blobs := newTestBlobs(ctx.Blocks)
+	original := []uint64{blobs[0].Hash, blobs[1].Hash}
+
+	levelChunk := &packet.LevelChunk{SubChunkCount: 1, CacheEnabled: true, BlobHashes: append([]uint64(nil), original...)}
+	if result := b.DowngradeBlobPackets([]packet.Packet{levelChunk}, ctx, conn); len(result) != 0 {
+		t.Fatalf("expected the chunk to be held back, got %v packets", len(result))
+	}
+	requests := b.UpgradeBlobPackets(nil, conn)
+	if len(requests) != 1 || !reflect.DeepEqual(requests[0].(*packet.ClientCacheBlobStatus).MissHashes, original) {
+		t.Fatalf("expected the blobs of the chunk to be requested, got %#v", requests)
+	}
+
+	result := b.DowngradeBlobPackets([]packet.Packet{&packet.ClientCacheMissResponse{Blobs: append([]protocol.CacheBlob(nil), blobs...)}}, ctx, conn)
+	if len(result) != 1 || result[0] != levelChunk {
+		t.Fatalf("expected only the held chunk to be sent, got %#v", result)
+	}
+	translated := levelChunk.BlobHashes
+	if reflect.DeepEqual(translated, original) {
+		t.Fatalf("blob hashes of the chunk were not translated")
+	}
+
+	status := &packet.ClientCacheBlobStatus{MissHashes: []uint64{translated[0]}, HitHashes: []uint64{translated[1]}}
+	if pks := b.UpgradeBlobPackets([]packet.Packet{status}, conn); len(pks) != 1 {
+		t.Fatalf("expected no packets to be added, got %v", len(pks))
+	}
+	if status.MissHashes[0] != original[0] || status.HitHashes[0] != original[1] {
+		t.Fatalf("expected the original hashes to be reported to the server, got misses %v and hits %v", status.MissHashes, status.HitHashes)
+	}
+
+	result = b.DowngradeBlobPackets([]packet.Packet{&packet.ClientCacheMissResponse{Blobs: []protocol.CacheBlob{blobs[0]}}}, ctx, conn)
+	if len(result) != 1 {
+		t.Fatalf("expected the response to the client to be sent, got %v packets", len(result))
+	}
+	blob := result[0].(*packet.ClientCacheMissResponse).Blobs[0]
+	if blob.Hash != translated[0] || xxhash.Sum64(blob.Payload) != blob.Hash {
+		t.Fatalf("expected the translated blob %v, got blob %v", translated[0], blob.Hash)
+	}
+}
+
+// TestBlobCacheTimeout checks that chunks are dropped if their blobs do not arrive within the timeout, and that their
+// blobs are requested again for chunks sent later.
+func TestBlobCacheTimeout(t *testing.T) {
+	b, conn := NewBlobCache(10*time.Millisecond), &minecraft.Conn{}
+	ctx := &Context{Blocks: newTestBlockTranslator(), Dimension: NewDimension(packet.DimensionOverworld)}
+	blobs := newTestBlobs(ctx.Blocks)
+	hashes := []uint64{blobs[0].Hash, blobs[1].Hash}
+
+	b.DowngradeBlobPackets([]packet.Packet{&packet.LevelChunk{SubChunkCount: 1, CacheEnabled: true, BlobHashes: append([]uint64(nil), hashes...)}}, ctx, conn)
+	b.UpgradeBlobPackets(nil, conn)
+	time.Sleep(20 * time.Millisecond)
+
+	if requests := b.UpgradeBlobPackets(nil, conn); len(requests) != 0 {
+		t.Fatalf("expected no blobs to be requested, got %#v", requests)
+	}
+	if held, kinds := len(b.conns[conn].held), len(b.conns[conn].kinds); held != 0 || kinds != 0 {
+		t.Fatalf("expected the chunk and its blobs to be dropped, got %v chunks and %v blobs", held, kinds)
+	}
+
+	b.DowngradeBlobPackets([]packet.Packet{&packet.LevelChunk{SubChunkCount: 1, CacheEnabled: true, BlobHashes: append([]uint64(nil), hashes...)}}, ctx, conn)
+	requests := b.UpgradeBlobPackets(nil, conn)
+	if len(requests) != 1 || !reflect.DeepEqual(requests[0].(*packet.ClientCacheBlobStatus).MissHashes, hashes) {
+		t.Fatalf("expected the blobs to be requested again, got %#v", requests)
+	}
+}
@@ -3,9 +3,11 @@ package translator
 import (
 	"bytes"
 	"fmt"
+	"sync/atomic"
 
 	"github.com/df-mc/dragonfly/server/block/cube"
 	"github.com/df-mc/dragonfly/server/world"
+	"github.com/df-mc/worldupgrader/blockupgrader"
 	"github.com/flonja/multiversion/internal/chunk"
 	"github.com/flonja/multiversion/mapping"
 	"github.com/sandertv/gophertunnel/minecraft"
@@ -17,76 +19,212 @@ import (
 type BlockTranslator interface {
 	// DowngradeBlockRuntimeID downgrades the input block runtime IDs to a legacy block runtime ID.
 	DowngradeBlockRuntimeID(uint32) uint32
-	// DowngradeChunk downgrades the input chunk to a legacy chunk.
-	DowngradeChunk(*chunk.Chunk, bool) *chunk.Chunk
+	// DowngradeChunk downgrades the input chunk to a legacy chunk with the height range passed.
+	DowngradeChunk(*chunk.Chunk, cube.Range) *chunk.Chunk
 	// DowngradeSubChunk downgrades the input sub chunk to a legacy sub chunk.
 	DowngradeSubChunk(*chunk.SubChunk)
+	// DowngradeBiomeID downgrades the input biome ID to a legacy biome ID.
+	DowngradeBiomeID(uint32) uint32
 	// DowngradeBlockPackets downgrades the input block packets to legacy block packets.
 	DowngradeBlockPackets([]packet.Packet, *minecraft.Conn) (result []packet.Packet)
 	// UpgradeBlockRuntimeID upgrades the input block runtime IDs to the latest block runtime ID.
 	UpgradeBlockRuntimeID(uint32) uint32
-	// UpgradeChunk upgrades the input chunk to the latest chunk.
-	UpgradeChunk(*chunk.Chunk, bool) *chunk.Chunk
+	// UpgradeChunk upgrades the input chunk to the latest chunk with the height range passed.
+	UpgradeChunk(*chunk.Chunk, cube.Range) *chunk.Chunk
 	// UpgradeSubChunk upgrades the input sub chunk to the latest sub chunk.
 	UpgradeSubChunk(*chunk.SubChunk)
+	// UpgradeBiomeID upgrades the input biome ID to the latest biome ID.
+	UpgradeBiomeID(uint32) uint32
 	// UpgradeBlockPackets upgrades the input block packets to the latest block packets.
 	UpgradeBlockPackets([]packet.Packet, *minecraft.Conn) (result []packet.Packet)
+	// BlockMappings returns the legacy and latest block mappings used by the translator.
+	BlockMappings() (mapping.Block, mapping.Block)
+	// SetItemTranslator sets the item translator used to translate the items held by block actors.
+	SetItemTranslator(items ItemTranslator)
+	// SetHeightPolicy sets the policy for blocks outside the height range of the legacy version.
+	SetHeightPolicy(policy HeightPolicy)
+	// Overlay returns a copy of the translator for a single connection in the dimension passed, which adjusts
+	// copy-on-write overlays of the block mappings.
+	Overlay(dimension *Dimension) BlockTranslator
 }
 
+// HeightPolicy is the policy for blocks outside the height range of the legacy version.
+type HeightPolicy int
+
+const (
+	// HeightPolicyDrop drops all blocks outside the legacy height range.
+	HeightPolicyDrop HeightPolicy = iota
+	// HeightPolicyClamp drops all blocks outside the legacy height range, but places the closest block below and above
+	// the range on the lowest and highest layer of the range where those are air, so that the legacy client does not
+	// look into the void.
+	HeightPolicyClamp
+	// HeightPolicyShift shifts all blocks vertically, so that the bottom of the height range of the server lines up with
+	// the bottom of the legacy height range. Blocks shifted above the legacy height range are dropped. The positions of
+	// players, entities, sounds and particles are shifted along with the blocks.
+	HeightPolicyShift
+)
+
 type DefaultBlockTranslator struct {
-	mapping mapping.Block
-	latest  mapping.Block
+	mapping      mapping.Block
+	latest       mapping.Block
+	biomeMapping mapping.Biome
+	latestBiomes mapping.Biome
+	fallback     *mapping.BlockFallback
+	blockActors  *BlockActorRegistry
+	items        ItemTranslator
+	// legacyRanges holds the height ranges of the dimensions in the legacy version that differ from those of the
+	// server, indexed by dimension ID.
+	legacyRanges map[int32]cube.Range
+	// legacyFormat is true if chunks are encoded in the format used before 1.18, with two-dimensional biomes.
+	legacyFormat bool
+	heightPolicy HeightPolicy
+	// dimension is the dimension of the connection the translator is used for. It is nil for translators shared by
+	// all connections.
+	dimension *Dimension
+
+	// tables holds the runtime ID translation tables. They are built the first time they are needed, and built again
+	// once the mappings are adjusted. The tables of a shared translator are built once and used by all of its overlays
+	// until they are adjusted.
+	tables atomic.Pointer[runtimeIDTables]
+}
+
+func NewBlockTranslator(mapping mapping.Block, latestMapping mapping.Block, biomeMapping mapping.Biome, latestBiomeMapping mapping.Biome) *DefaultBlockTranslator {
+	return &DefaultBlockTranslator{mapping: mapping, latest: latestMapping, biomeMapping: biomeMapping, latestBiomes: latestBiomeMapping}
+}
+
+// WithBlockFallback sets the fallback rules used to find the closest matching block for block states that do not
+// exist in the other version. Without fallback rules, such block states are replaced with air.
+func (t *DefaultBlockTranslator) WithBlockFallback(fallback *mapping.BlockFallback) *DefaultBlockTranslator {
+	t.fallback = fallback
+	t.tables.Store(nil)
+	return t
+}
+
+// WithBlockActors sets the converters used to translate the data of block actors. Without converters, block actor
+// data is sent untouched.
+func (t *DefaultBlockTranslator) WithBlockActors(registry *BlockActorRegistry) *DefaultBlockTranslator {
+	t.blockActors = registry
+	return t
+}
+
+// WithLegacyChunkFormat sets the translator to translate chunks to the format used before 1.18, which has
+// two-dimensional biomes and a height range of 0-255 in the overworld.
+func (t *DefaultBlockTranslator) WithLegacyChunkFormat() *DefaultBlockTranslator {
+	t.legacyRanges = map[int32]cube.Range{packet.DimensionOverworld: {0, 255}}
+	t.legacyFormat = true
+	return t
+}
+
+func (t *DefaultBlockTranslator) SetItemTranslator(items ItemTranslator) {
+	t.items = items
+}
+
+func (t *DefaultBlockTranslator) SetHeightPolicy(policy HeightPolicy) {
+	t.heightPolicy = policy
+}
+
+func (t *DefaultBlockTranslator) BlockMappings() (mapping.Block, mapping.Block) {
+	return t.mapping, t.latest
 }
 
-func NewBlockTranslator(mapping mapping.Block, latestMapping mapping.Block) *DefaultBlockTranslator {
-	return &DefaultBlockTranslator{mapping: mapping, latest: latestMapping}
+func (t *DefaultBlockTranslator) Overlay(dimension *Dimension) BlockTranslator {
+	overlay := &DefaultBlockTranslator{mapping: mapping.NewBlockOverlay(t.mapping), latest: mapping.NewBlockOverlay(t.latest),
+		biomeMapping: t.biomeMapping, latestBiomes: t.latestBiomes, fallback: t.fallback, blockActors: t.blockActors, legacyRanges: t.legacyRanges,
+		legacyFormat: t.legacyFormat, heightPolicy: t.heightPolicy, dimension: dimension}
+	// Until the overlays are adjusted, they translate exactly like the shared mappings, so they use the tables of the
+	// shared translator, which are built for the first connection only.
+	overlay.tables.Store(t.runtimeIDTables())
+	return overlay
+}
+
+// runtimeIDTables returns the runtime ID translation tables of the translator, building them if needed.
+func (t *DefaultBlockTranslator) runtimeIDTables() *runtimeIDTables {
+	if tables := t.tables.Load(); tables != nil {
+		return tables
+	}
+	tables := newRuntimeIDTables(t)
+	t.tables.Store(tables)
+	return tables
+}
+
+// ranges returns the height range of the dimension the connection of the translator is in, together with the height
+// range of the same dimension in the legacy version.
+func (t *DefaultBlockTranslator) ranges() (cube.Range, cube.Range) {
+	id, r := int32(packet.DimensionOverworld), world.Overworld.Range()
+	if t.dimension != nil {
+		id, r = t.dimension.ID(), t.dimension.Range()
+	}
+	if legacy, ok := t.legacyRanges[id]; ok {
+		return r, legacy
+	}
+	return r, r
+}
+
+// shift returns the number of blocks the positions of blocks are shifted up by when downgrading.
+func (t *DefaultBlockTranslator) shift() int32 {
+	if t.heightPolicy != HeightPolicyShift {
+		return 0
+	}
+	r, legacy := t.ranges()
+	return int32(legacy.Min() - r.Min())
+}
+
+// adjust adjusts both block mappings for the custom states passed and drops the runtime ID tables, so that they are
+// built again for the adjusted mappings.
+func (t *DefaultBlockTranslator) adjust(entries []protocol.BlockEntry) {
+	if len(entries) == 0 {
+		return
+	}
+	t.mapping.Adjust(entries)
+	t.latest.Adjust(entries)
+	t.tables.Store(nil)
 }
 
 func (t *DefaultBlockTranslator) DowngradeBlockRuntimeID(input uint32) uint32 {
-	state, ok := t.latest.RuntimeIDToState(input)
+	return t.runtimeIDTables().downgradeRuntimeID(input)
+}
+
+// downgradeRuntimeID downgrades the input block runtime ID without using the runtime ID tables.
+func (t *DefaultBlockTranslator) downgradeRuntimeID(input uint32) uint32 {
+	hash, ok := t.latest.RuntimeIDToHash(input)
 	if !ok {
 		return t.mapping.Air()
 	}
-	runtimeID, ok := t.mapping.StateToRuntimeID(state)
+	runtimeID, ok := t.mapping.HashToRuntimeID(hash)
 	if !ok {
-		return t.mapping.Air()
+		state, _ := t.latest.RuntimeIDToState(input)
+		return t.fallbackRuntimeID(state, t.mapping)
 	}
 	return runtimeID
 }
 
-func (t *DefaultBlockTranslator) DowngradeChunk(input *chunk.Chunk, oldFormat bool) *chunk.Chunk {
-	start := 0
-	r := world.Overworld.Range()
-	if oldFormat {
-		start = 4
-		r = cube.Range{0, 255}
-	}
-	downgraded := chunk.New(t.latest.Air(), r)
-
-	i := 0
+func (t *DefaultBlockTranslator) DowngradeChunk(input *chunk.Chunk, r cube.Range) *chunk.Chunk {
 	// First downgrade the blocks.
-	for _, sub := range input.Sub()[start : len(input.Sub())-start] {
+	for _, sub := range input.Sub() {
 		t.DowngradeSubChunk(sub)
-		downgraded.Sub()[i] = sub
-		i += 1
 	}
-	i = 0
 	// Then downgrade the biome ids.
-	for _, sub := range input.BiomeSub()[start : len(input.BiomeSub())-start] {
-		// todo
-		sub.Palette().Replace(func(v uint32) uint32 {
-			return 0 // at least the client doesn't crash now
-		})
-		downgraded.BiomeSub()[i] = sub
-		i += 1
-	}
-	return downgraded
+	replaceBiomes(input.BiomeSub(), t.DowngradeBiomeID)
+	return t.fitChunk(input, t.mapping.Air(), r, t.shift())
 }
 
 func (t *DefaultBlockTranslator) DowngradeSubChunk(input *chunk.SubChunk) {
+	tables := t.runtimeIDTables()
 	for _, storage := range input.Layers() {
-		storage.Palette().Replace(t.DowngradeBlockRuntimeID)
+		storage.Palette().Replace(tables.downgradeRuntimeID)
+	}
+}
+
+func (t *DefaultBlockTranslator) DowngradeBiomeID(input uint32) uint32 {
+	name, ok := t.latestBiomes.BiomeIDToName(input)
+	if !ok {
+		return t.biomeMapping.Plains()
+	}
+	id, ok := t.biomeMapping.BiomeNameToID(name)
+	if !ok {
+		return t.biomeMapping.Plains()
 	}
+	return id
 }
 
 func (t *DefaultBlockTranslator) downgradeEntityMetadata(metadata map[uint32]any) map[uint32]any {
@@ -97,46 +235,175 @@ func (t *DefaultBlockTranslator) downgradeEntityMetadata(metadata map[uint32]any
 }
 
 func (t *DefaultBlockTranslator) UpgradeBlockRuntimeID(input uint32) uint32 {
-	state, ok := t.mapping.RuntimeIDToState(input)
+	return t.runtimeIDTables().upgradeRuntimeID(input)
+}
+
+// upgradeRuntimeID upgrades the input block runtime ID without using the runtime ID tables.
+func (t *DefaultBlockTranslator) upgradeRuntimeID(input uint32) uint32 {
+	hash, ok := t.mapping.RuntimeIDToHash(input)
 	if !ok {
 		return t.latest.Air()
 	}
-	runtimeID, ok := t.latest.StateToRuntimeID(state)
+	runtimeID, ok := t.latest.HashToRuntimeID(hash)
 	if !ok {
-		return t.latest.Air()
+		state, _ := t.mapping.RuntimeIDToState(input)
+		return t.fallbackRuntimeID(state, t.latest)
 	}
 	return runtimeID
 }
 
-func (t *DefaultBlockTranslator) UpgradeChunk(input *chunk.Chunk, oldFormat bool) *chunk.Chunk {
-	start := 0
-	r := world.Overworld.Range()
-	if oldFormat {
-		start = 4
-		r = cube.Range{0, 255}
+// fallbackRuntimeID returns the runtime ID of the block in the mapping passed that most closely matches the state
+// passed, or air if no fallback rules are set or none of them match.
+func (t *DefaultBlockTranslator) fallbackRuntimeID(state blockupgrader.BlockState, m mapping.Block) uint32 {
+	if t.fallback != nil {
+		if runtimeID, ok := t.fallback.Resolve(state, m); ok {
+			return runtimeID
+		}
 	}
-	upgraded := chunk.New(t.mapping.Air(), r)
+	return m.Air()
+}
 
-	i := 0
+func (t *DefaultBlockTranslator) UpgradeChunk(input *chunk.Chunk, r cube.Range) *chunk.Chunk {
 	// First upgrade the blocks.
-	for _, sub := range input.Sub()[start : len(input.Sub())-start] {
+	for _, sub := range input.Sub() {
 		t.UpgradeSubChunk(sub)
-		upgraded.Sub()[i] = sub
-		i += 1
 	}
-	i = 0
 	// Then upgrade the biome ids.
-	for _, sub := range input.BiomeSub()[start : len(input.BiomeSub())-start] {
-		// todo
-		upgraded.BiomeSub()[i] = sub
-		i += 1
+	replaceBiomes(input.BiomeSub(), t.UpgradeBiomeID)
+	return t.fitChunk(input, t.latest.Air(), r, -t.shift())
+}
+
+// fitChunk fits the translated chunk passed into a chunk with the height range passed, moving its blocks up by the
+// shift passed. Blocks outside the height range are dropped or clamped according to the height policy.
+func (t *DefaultBlockTranslator) fitChunk(input *chunk.Chunk, air uint32, r cube.Range, shift int32) *chunk.Chunk {
+	if r == input.Range() && shift == 0 {
+		return input
 	}
-	return upgraded
+	output := chunk.New(air, r)
+	// offset is the number of sub chunks the input chunk starts below the output chunk.
+	offset := (r.Min() - input.Range().Min() - int(shift)) >> 4
+	subs, biomes := input.Sub(), input.BiomeSub()
+	for i := range output.Sub() {
+		j := i + offset
+		switch {
+		case j < 0:
+			output.BiomeSub()[i] = biomes[0]
+		case j >= len(subs):
+			output.BiomeSub()[i] = biomes[len(biomes)-1]
+		default:
+			// Empty sub chunks are left to the output chunk, so that they hold the air of the right version.
+			if len(subs[j].Layers()) > 0 {
+				output.Sub()[i] = subs[j]
+			}
+			output.BiomeSub()[i] = biomes[j]
+		}
+	}
+	if t.heightPolicy == HeightPolicyClamp {
+		clampChunk(input, output, offset, air)
+	}
+	return output
+}
+
+// clampChunk places the closest blocks below and above the output chunk on its lowest and highest layer where those
+// are air. The input chunk starts offset sub chunks below the output chunk.
+func clampChunk(input, output *chunk.Chunk, offset int, air uint32) {
+	split := func(i int) int {
+		if i < 0 {
+			return 0
+		} else if i > len(input.Sub()) {
+			return len(input.Sub())
+		}
+		return i
+	}
+	below, above := input.Sub()[:split(offset)], input.Sub()[split(offset+len(output.Sub())):]
+	lowest, highest := output.Sub()[0], output.Sub()[len(output.Sub())-1]
+	for x := byte(0); x < 16; x++ {
+		for z := byte(0); z < 16; z++ {
+			if rid, ok := firstBlock(below, x, z, air, true); ok && lowest.Block(x, 0, z, 0) == air {
+				lowest.SetBlock(x, 0, z, 0, rid)
+			}
+			if rid, ok := firstBlock(above, x, z, air, false); ok && highest.Block(x, 15, z, 0) == air {
+				highest.SetBlock(x, 15, z, 0, rid)
+			}
+		}
+	}
+}
+
+// firstBlock returns the first block that is not air in the column passed, searching the sub chunks passed from the
+// top down if top is true, or from the bottom up otherwise.
+func firstBlock(subs []*chunk.SubChunk, x, z byte, air uint32, top bool) (uint32, bool) {
+	for i := range subs {
+		sub := subs[i]
+		if top {
+			sub = subs[len(subs)-1-i]
+		}
+		if len(sub.Layers()) == 0 {
+			continue
+		}
+		for i := 0; i < 16; i++ {
+			y := byte(i)
+			if top {
+				y = byte(15 - i)
+			}
+			if rid := sub.Layers()[0].At(x, y, z); rid != air {
+				return rid, true
+			}
+		}
+	}
+	return 0, false
 }
 
 func (t *DefaultBlockTranslator) UpgradeSubChunk(input *chunk.SubChunk) {
+	tables := t.runtimeIDTables()
 	for _, storage := range input.Layers() {
-		storage.Palette().Replace(t.UpgradeBlockRuntimeID)
+		storage.Palette().Replace(tables.upgradeRuntimeID)
+	}
+}
+
+func (t *DefaultBlockTranslator) UpgradeBiomeID(input uint32) uint32 {
+	name, ok := t.biomeMapping.BiomeIDToName(input)
+	if !ok {
+		return t.latestBiomes.Plains()
+	}
+	id, ok := t.latestBiomes.BiomeNameToID(name)
+	if !ok {
+		return t.latestBiomes.Plains()
+	}
+	return id
+}
+
+// downgradeBlockActor downgrades the block actor data passed using the block actor converters of the translator.
+func (t *DefaultBlockTranslator) downgradeBlockActor(data map[string]any) map[string]any {
+	if t.blockActors == nil {
+		return data
+	}
+	return t.blockActors.Downgrade(data, t.items)
+}
+
+// upgradeBlockActor upgrades the block actor data passed using the block actor converters of the translator.
+func (t *DefaultBlockTranslator) upgradeBlockActor(data map[string]any) map[string]any {
+	if t.blockActors == nil {
+		return data
+	}
+	return t.blockActors.Upgrade(data, t.items)
+}
+
+// shiftBlockActorData moves the block actor data passed up by the shift passed.
+func shiftBlockActorData(data map[string]any, shift int32) {
+	if y, ok := data["y"].(int32); ok && shift != 0 {
+		data["y"] = y + shift
+	}
+}
+
+// replaceBiomes replaces the biome IDs of all biome storages passed using the function passed. Storages may be shared
+// between multiple sub chunks, so every palette is only replaced once.
+func replaceBiomes(storages []*chunk.PalettedStorage, f func(uint32) uint32) {
+	replaced := make(map[*chunk.Palette]struct{})
+	for _, storage := range storages {
+		if _, ok := replaced[storage.Palette()]; !ok {
+			storage.Palette().Replace(f)
+			replaced[storage.Palette()] = struct{}{}
+		}
 	}
 }
 
@@ -148,30 +415,35 @@ func (t *DefaultBlockTranslator) upgradeEntityMetadata(metadata map[uint32]any)
 }
 
 func (t *DefaultBlockTranslator) DowngradeBlockPackets(pks []packet.Packet, conn *minecraft.Conn) (result []packet.Packet) {
-	oldFormat := conn.GameData().BaseGameVersion == "1.17.40"
+	r, legacy := t.ranges()
+	shift := t.shift()
 	for _, pk := range pks {
+		shiftPositions(pk, shift)
 		switch pk := pk.(type) {
 		case *packet.LevelChunk:
 			count := int(pk.SubChunkCount)
-			if count == protocol.SubChunkRequestModeLimitless || count == protocol.SubChunkRequestModeLimited {
-				break
-			}
 			buf := bytes.NewBuffer(pk.RawPayload)
 			writeBuf := bytes.NewBuffer(nil)
-			if !pk.CacheEnabled && !conn.ClientCacheEnabled() {
-				r := world.Overworld.Range()
-				if oldFormat {
-					r = cube.Range{0, 255}
+			// Blobs sent through the client cache are translated by the BlobCache, so the payload only holds the border
+			// blocks and block entities of the chunk.
+			if !pk.CacheEnabled && (count == protocol.SubChunkRequestModeLimitless || count == protocol.SubChunkRequestModeLimited) {
+				// The sub chunks are requested separately, so the payload only holds the biomes of the chunk.
+				c, err := chunk.NetworkDecode(t.latest.Air(), buf, 0, false, r)
+				if err != nil {
+					fmt.Println(err)
+					break
 				}
-
-				c, err := chunk.NetworkDecode(t.latest.Air(), buf, count, oldFormat, r)
+				replaceBiomes(c.BiomeSub(), t.DowngradeBiomeID)
+				writeBuf.Write(chunk.EncodeBiomes(c, chunk.NetworkEncoding))
+			} else if !pk.CacheEnabled {
+				c, err := chunk.NetworkDecode(t.latest.Air(), buf, count, false, r)
 				if err != nil {
 					fmt.Println(err)
 					break
 				}
-				t.DowngradeChunk(c, oldFormat)
+				c = t.DowngradeChunk(c, legacy)
 
-				payload, err := chunk.NetworkEncode(t.mapping.Air(), c, oldFormat)
+				payload, err := chunk.NetworkEncode(c, t.legacyFormat)
 				if err != nil {
 					fmt.Println(err)
 					break
@@ -201,7 +473,8 @@ func (t *DefaultBlockTranslator) DowngradeBlockPackets(pks []packet.Packet, conn
 				if err = dec.Decode(&decNbt); err != nil {
 					break
 				}
-				t.mapping.DowngradeBlockActorData(decNbt)
+				decNbt = t.downgradeBlockActor(decNbt)
+				shiftBlockActorData(decNbt, shift)
 
 				if err = enc.Encode(decNbt); err != nil {
 					break
@@ -209,17 +482,12 @@ func (t *DefaultBlockTranslator) DowngradeBlockPackets(pks []packet.Packet, conn
 			}
 			pk.RawPayload = append(writeBuf.Bytes(), buf.Bytes()...)
 		case *packet.SubChunk:
-			r := world.Overworld.Range()
-			if oldFormat {
-				r = cube.Range{0, 255}
-			}
-
 			for i, entry := range pk.SubChunkEntries {
 				if entry.Result == protocol.SubChunkResultSuccess {
 					buf := bytes.NewBuffer(entry.RawPayload)
 					writeBuf := bytes.NewBuffer(nil)
-					if !pk.CacheEnabled && !conn.ClientCacheEnabled() {
-						ind := byte(i)
+					if !pk.CacheEnabled {
+						ind := byte(int(pk.Position.Y()) + int(entry.Offset[1]) - (r[0] >> 4))
 						subChunk, err := chunk.DecodeSubChunk(t.latest.Air(), r, buf, &ind, chunk.NetworkEncoding)
 						if err != nil {
 							fmt.Println(err)
@@ -236,7 +504,7 @@ func (t *DefaultBlockTranslator) DowngradeBlockPackets(pks []packet.Packet, conn
 						if err := dec.Decode(&decNbt); err != nil {
 							break
 						}
-						t.mapping.DowngradeBlockActorData(decNbt)
+						decNbt = t.downgradeBlockActor(decNbt)
 
 						if err := enc.Encode(decNbt); err != nil {
 							break
@@ -247,38 +515,28 @@ func (t *DefaultBlockTranslator) DowngradeBlockPackets(pks []packet.Packet, conn
 					pk.SubChunkEntries[i] = entry
 				}
 			}
-		case *packet.ClientCacheMissResponse:
-			r := world.Overworld.Range()
-			if oldFormat {
-				r = cube.Range{0, 255}
-			}
-
-			for i, blob := range pk.Blobs {
-				buf := bytes.NewBuffer(blob.Payload)
-				ind := byte(0)
-				subChunk, err := chunk.DecodeSubChunk(t.latest.Air(), r, buf, &ind, chunk.NetworkEncoding)
-				if err != nil {
-					// Has a possibility to be a biome, ignore then
-					continue
-				}
-				t.DowngradeSubChunk(subChunk)
-
-				blob.Payload = append(chunk.EncodeSubChunk(subChunk, chunk.NetworkEncoding, chunk.SubChunkVersion9, r, int(ind)), buf.Bytes()...)
-				pk.Blobs[i] = blob
-			}
 		case *packet.UpdateSubChunkBlocks:
+			pk.Position[1] += shift >> 4
 			for i, block := range pk.Blocks {
 				block.BlockRuntimeID = t.DowngradeBlockRuntimeID(block.BlockRuntimeID)
+				block.BlockPos[1] += shift
 				pk.Blocks[i] = block
 			}
 			for i, block := range pk.Extra {
 				block.BlockRuntimeID = t.DowngradeBlockRuntimeID(block.BlockRuntimeID)
+				block.BlockPos[1] += shift
 				pk.Extra[i] = block
 			}
 		case *packet.UpdateBlock:
 			pk.NewBlockRuntimeID = t.DowngradeBlockRuntimeID(pk.NewBlockRuntimeID)
+			pk.Position[1] += shift
 		case *packet.UpdateBlockSynced:
 			pk.NewBlockRuntimeID = t.DowngradeBlockRuntimeID(pk.NewBlockRuntimeID)
+			pk.Position[1] += shift
+		case *packet.BlockActorData:
+			pk.NBTData = t.downgradeBlockActor(pk.NBTData)
+			pk.Position[1] += shift
+			shiftBlockActorData(pk.NBTData, shift)
 		case *packet.InventoryTransaction:
 			if transactionData, ok := pk.TransactionData.(*protocol.UseItemTransactionData); ok {
 				transactionData.BlockRuntimeID = t.DowngradeBlockRuntimeID(transactionData.BlockRuntimeID)
@@ -311,15 +569,13 @@ func (t *DefaultBlockTranslator) DowngradeBlockPackets(pks []packet.Packet, conn
 				pk.ExtraData = int32(t.DowngradeBlockRuntimeID(uint32(pk.ExtraData)))
 			}
 		case *packet.AddActor:
-			if pk.EntityType != "minecraft:falling_block" {
-				continue
+			if pk.EntityType == "minecraft:falling_block" {
+				pk.EntityMetadata = t.downgradeEntityMetadata(pk.EntityMetadata)
 			}
-			pk.EntityMetadata = t.downgradeEntityMetadata(pk.EntityMetadata)
 		case *packet.SetActorData:
 			pk.EntityMetadata = t.downgradeEntityMetadata(pk.EntityMetadata)
 		case *packet.StartGame:
-			t.latest.Adjust(pk.Blocks)
-			t.mapping.Adjust(pk.Blocks)
+			t.adjust(pk.Blocks)
 		}
 		result = append(result, pk)
 	}
@@ -327,30 +583,39 @@ func (t *DefaultBlockTranslator) DowngradeBlockPackets(pks []packet.Packet, conn
 }
 
 func (t *DefaultBlockTranslator) UpgradeBlockPackets(pks []packet.Packet, conn *minecraft.Conn) (result []packet.Packet) {
-	oldFormat := conn.GameData().BaseGameVersion == "1.17.40"
+	r, legacy := t.ranges()
+	shift := t.shift()
 	for _, pk := range pks {
+		shiftPositions(pk, -shift)
 		switch pk := pk.(type) {
 		case *packet.LevelChunk:
 			count := int(pk.SubChunkCount)
 			if count == protocol.SubChunkRequestModeLimitless || count == protocol.SubChunkRequestModeLimited {
+				if pk.CacheEnabled || conn.ClientCacheEnabled() {
+					break
+				}
+				// The sub chunks are requested separately, so the payload only holds the biomes of the chunk.
+				buf := bytes.NewBuffer(pk.RawPayload)
+				c, err := chunk.NetworkDecode(t.mapping.Air(), buf, 0, false, legacy)
+				if err != nil {
+					fmt.Println(err)
+					break
+				}
+				replaceBiomes(c.BiomeSub(), t.UpgradeBiomeID)
+				pk.RawPayload = append(chunk.EncodeBiomes(c, chunk.NetworkEncoding), buf.Bytes()...)
 				break
 			}
 			buf := bytes.NewBuffer(pk.RawPayload)
 			writeBuf := bytes.NewBuffer(nil)
 			if !pk.CacheEnabled && !conn.ClientCacheEnabled() {
-				r := world.Overworld.Range()
-				if oldFormat {
-					r = cube.Range{0, 255}
-				}
-
-				c, err := chunk.NetworkDecode(t.mapping.Air(), buf, count, oldFormat, r)
+				c, err := chunk.NetworkDecode(t.mapping.Air(), buf, count, t.legacyFormat, legacy)
 				if err != nil {
 					fmt.Println(err)
 					break
 				}
-				t.UpgradeChunk(c, oldFormat)
+				c = t.UpgradeChunk(c, r)
 
-				payload, err := chunk.NetworkEncode(t.latest.Air(), c, oldFormat)
+				payload, err := chunk.NetworkEncode(c, false)
 				if err != nil {
 					fmt.Println(err)
 					break
@@ -380,7 +645,8 @@ func (t *DefaultBlockTranslator) UpgradeBlockPackets(pks []packet.Packet, conn *
 				if err = dec.Decode(&decNbt); err != nil {
 					break
 				}
-				t.mapping.UpgradeBlockActorData(decNbt)
+				decNbt = t.upgradeBlockActor(decNbt)
+				shiftBlockActorData(decNbt, -shift)
 
 				if err = enc.Encode(decNbt); err != nil {
 					break
@@ -388,24 +654,19 @@ func (t *DefaultBlockTranslator) UpgradeBlockPackets(pks []packet.Packet, conn *
 			}
 			pk.RawPayload = append(writeBuf.Bytes(), buf.Bytes()...)
 		case *packet.SubChunk:
-			r := world.Overworld.Range()
-			if oldFormat {
-				r = cube.Range{0, 255}
-			}
-
 			for i, entry := range pk.SubChunkEntries {
 				if entry.Result == protocol.SubChunkResultSuccess {
 					buf := bytes.NewBuffer(entry.RawPayload)
 					writeBuf := bytes.NewBuffer(nil)
 					if !pk.CacheEnabled && !conn.ClientCacheEnabled() {
-						ind := byte(i)
-						subChunk, err := chunk.DecodeSubChunk(t.mapping.Air(), r, buf, &ind, chunk.NetworkEncoding)
+						ind := byte(int(pk.Position.Y()) + int(entry.Offset[1]) - (legacy[0] >> 4))
+						subChunk, err := chunk.DecodeSubChunk(t.mapping.Air(), legacy, buf, &ind, chunk.NetworkEncoding)
 						if err != nil {
 							// Has a possibility to be a biome, ignore then
 							continue
 						}
 						t.UpgradeSubChunk(subChunk)
-						writeBuf.Write(chunk.EncodeSubChunk(subChunk, chunk.NetworkEncoding, chunk.SubChunkVersion9, r, int(ind)))
+						writeBuf.Write(chunk.EncodeSubChunk(subChunk, chunk.NetworkEncoding, chunk.SubChunkVersion9, legacy, int(ind)))
 					}
 
 					enc := nbt.NewEncoderWithEncoding(writeBuf, nbt.NetworkLittleEndian)
@@ -415,7 +676,7 @@ func (t *DefaultBlockTranslator) UpgradeBlockPackets(pks []packet.Packet, conn *
 						if err := dec.Decode(&decNbt); err != nil {
 							break
 						}
-						t.mapping.UpgradeBlockActorData(decNbt)
+						decNbt = t.upgradeBlockActor(decNbt)
 
 						if err := enc.Encode(decNbt); err != nil {
 							break
@@ -427,22 +688,17 @@ func (t *DefaultBlockTranslator) UpgradeBlockPackets(pks []packet.Packet, conn *
 				}
 			}
 		case *packet.ClientCacheMissResponse:
-			r := world.Overworld.Range()
-			if oldFormat {
-				r = cube.Range{0, 255}
-			}
-
 			for i, blob := range pk.Blobs {
 				buf := bytes.NewBuffer(blob.Payload)
 				ind := byte(0)
-				subChunk, err := chunk.DecodeSubChunk(t.mapping.Air(), r, buf, &ind, chunk.NetworkEncoding)
+				subChunk, err := chunk.DecodeSubChunk(t.mapping.Air(), legacy, buf, &ind, chunk.NetworkEncoding)
 				if err != nil {
 					fmt.Println(err)
 					continue
 				}
 				t.UpgradeSubChunk(subChunk)
 
-				blob.Payload = chunk.EncodeSubChunk(subChunk, chunk.NetworkEncoding, chunk.SubChunkVersion9, r, int(ind))
+				blob.Payload = chunk.EncodeSubChunk(subChunk, chunk.NetworkEncoding, chunk.SubChunkVersion9, legacy, int(ind))
 				pk.Blobs[i] = blob
 			}
 		case *packet.UpdateSubChunkBlocks:
@@ -456,11 +712,16 @@ func (t *DefaultBlockTranslator) UpgradeBlockPackets(pks []packet.Packet, conn *
 			}
 		case *packet.UpdateBlock:
 			pk.NewBlockRuntimeID = t.UpgradeBlockRuntimeID(pk.NewBlockRuntimeID)
+		case *packet.BlockActorData:
+			pk.NBTData = t.upgradeBlockActor(pk.NBTData)
+			pk.Position[1] -= shift
+			shiftBlockActorData(pk.NBTData, -shift)
 		case *packet.UpdateBlockSynced:
 			pk.NewBlockRuntimeID = t.UpgradeBlockRuntimeID(pk.NewBlockRuntimeID)
 		case *packet.InventoryTransaction:
 			if transactionData, ok := pk.TransactionData.(*protocol.UseItemTransactionData); ok {
 				transactionData.BlockRuntimeID = t.UpgradeBlockRuntimeID(transactionData.BlockRuntimeID)
+				transactionData.BlockPosition[1] -= shift
 				pk.TransactionData = transactionData
 			}
 		case *packet.LevelEvent:
@@ -490,15 +751,13 @@ func (t *DefaultBlockTranslator) UpgradeBlockPackets(pks []packet.Packet, conn *
 				pk.ExtraData = int32(t.UpgradeBlockRuntimeID(uint32(pk.ExtraData)))
 			}
 		case *packet.AddActor:
-			if pk.EntityType != "minecraft:falling_block" {
-				continue
+			if pk.EntityType == "minecraft:falling_block" {
+				pk.EntityMetadata = t.upgradeEntityMetadata(pk.EntityMetadata)
 			}
-			pk.EntityMetadata = t.upgradeEntityMetadata(pk.EntityMetadata)
 		case *packet.SetActorData:
 			pk.EntityMetadata = t.upgradeEntityMetadata(pk.EntityMetadata)
 		case *packet.StartGame:
-			t.mapping.Adjust(pk.Blocks)
-			t.latest.Adjust(pk.Blocks)
+			t.adjust(pk.Blocks)
 		}
 		result = append(result, pk)
 	}
@@ -0,0 +1,232 @@
+package translator
+
+// BlockActorConverter converts the NBT data of a single kind of block actor between the latest version and a legacy
+// version.
+type BlockActorConverter struct {
+	// ID is the ID of the block actor in the legacy version, for example 'Sign'. The ID is left untouched if empty.
+	// Block actors with a different ID in the legacy version are never upgraded, as the original ID is lost.
+	ID string
+	// Plain replaces the block actor with a plain block actor that only holds its ID and position, for block actors
+	// whose data the legacy version does not understand.
+	Plain bool
+	// Items holds the names of the fields holding items, in the latest version. A field may hold a single item or a
+	// list of items, which are translated using the item translator.
+	Items []string
+	// Fields holds the names of fields in the legacy version, indexed by their names in the latest version.
+	Fields map[string]string
+	// Downgrade and Upgrade are called for changes that cannot be expressed by the other fields. Both are called with
+	// the field names of the latest version.
+	Downgrade, Upgrade func(data map[string]any)
+}
+
+// BlockActorRegistry holds the block actor converters of a legacy version, indexed by the ID of the block actor in the
+// latest version. Block actors without a converter are sent untouched.
+type BlockActorRegistry struct {
+	converters map[string]BlockActorConverter
+}
+
+// NewBlockActorRegistry creates an empty block actor registry.
+func NewBlockActorRegistry() *BlockActorRegistry {
+	return &BlockActorRegistry{converters: make(map[string]BlockActorConverter)}
+}
+
+// Register registers the converter passed for block actors with the ID passed.
+func (r *BlockActorRegistry) Register(id string, converter BlockActorConverter) *BlockActorRegistry {
+	r.converters[id] = converter
+	return r
+}
+
+// Downgrade downgrades the block actor data passed to the legacy version, translating items held by the block actor
+// with the item translator passed.
+func (r *BlockActorRegistry) Downgrade(data map[string]any, items ItemTranslator) map[string]any {
+	id, _ := data["id"].(string)
+	converter, ok := r.converters[id]
+	if !ok {
+		return data
+	}
+	if converter.ID != "" {
+		id = converter.ID
+	}
+	if converter.Plain {
+		plain := map[string]any{"id": id}
+		for _, k := range []string{"x", "y", "z", "isMovable"} {
+			if v, ok := data[k]; ok {
+				plain[k] = v
+			}
+		}
+		return plain
+	}
+	for _, field := range converter.Items {
+		if v, ok := data[field]; ok && items != nil {
+			data[field] = translateItemData(v, items.DowngradeItemData)
+		}
+	}
+	if converter.Downgrade != nil {
+		converter.Downgrade(data)
+	}
+	renameFields(data, converter.Fields, false)
+	data["id"] = id
+	return data
+}
+
+// Upgrade upgrades the block actor data passed to the latest version, translating items held by the block actor with
+// the item translator passed.
+func (r *BlockActorRegistry) Upgrade(data map[string]any, items ItemTranslator) map[string]any {
+	id, _ := data["id"].(string)
+	converter, ok := r.converters[id]
+	if !ok || converter.Plain || converter.ID != "" && converter.ID != id {
+		return data
+	}
+	renameFields(data, converter.Fields, true)
+	if converter.Upgrade != nil {
+		converter.Upgrade(data)
+	}
+	for _, field := range converter.Items {
+		if v, ok := data[field]; ok && items != nil {
+			data[field] = translateItemData(v, items.UpgradeItemData)
+		}
+	}
+	return data
+}
+
+// LegacyBlockActorRules holds the differences between the block actors of the legacy versions supported, used by
+// NewLegacyBlockActorRegistry.
+type LegacyBlockActorRules struct {
+	// IDs holds the IDs of block actors in the legacy version, indexed by their IDs in the latest version, for block
+	// actors that did not exist yet in the legacy version.
+	IDs map[string]string
+	// SignFields holds the fields on the front of a sign, other than its text, that are supported by the legacy
+	// version.
+	SignFields []string
+}
+
+// legacyContainers holds the IDs of the block actors that hold their items in the 'Items' field.
+var legacyContainers = []string{
+	"Barrel",
+	"BlastFurnace",
+	"BrewingStand",
+	"Chest",
+	"Dispenser",
+	"Dropper",
+	"Furnace",
+	"Hopper",
+	"ShulkerBox",
+	"Smoker",
+}
+
+// NewLegacyBlockActorRegistry creates a block actor registry translating block actors between the latest version and
+// a legacy version predating 1.20, with the differences between legacy versions described by the rules passed.
+func NewLegacyBlockActorRegistry(rules LegacyBlockActorRules) *BlockActorRegistry {
+	downgradeSign, upgradeSign := func(data map[string]any) {
+		downgradeLegacySign(data, rules.SignFields)
+	}, func(data map[string]any) {
+		upgradeLegacySign(data, rules.SignFields)
+	}
+	r := NewBlockActorRegistry().
+		Register("Sign", BlockActorConverter{Downgrade: downgradeSign, Upgrade: upgradeSign}).
+		Register("HangingSign", BlockActorConverter{ID: "Sign", Downgrade: downgradeSign}).
+		Register("Bed", BlockActorConverter{Downgrade: downgradeLegacyBed}).
+		Register("Lectern", BlockActorConverter{Items: []string{"book"}}).
+		Register("Jukebox", BlockActorConverter{Items: []string{"RecordItem"}}).
+		Register("ItemFrame", BlockActorConverter{Items: []string{"Item"}}).
+		Register("GlowItemFrame", BlockActorConverter{Items: []string{"Item"}}).
+		Register("Campfire", BlockActorConverter{Items: []string{"Item1", "Item2", "Item3", "Item4"}}).
+		Register("ChiseledBookshelf", BlockActorConverter{Plain: true}).
+		Register("DecoratedPot", BlockActorConverter{ID: "FlowerPot", Plain: true}).
+		Register("BrushableBlock", BlockActorConverter{Plain: true}).
+		Register("SculkSensor", BlockActorConverter{Plain: true}).
+		Register("CalibratedSculkSensor", BlockActorConverter{ID: "SculkSensor", Plain: true}).
+		Register("SculkCatalyst", BlockActorConverter{Plain: true}).
+		Register("SculkShrieker", BlockActorConverter{Plain: true})
+	for _, id := range legacyContainers {
+		r.Register(id, BlockActorConverter{Items: []string{"Items"}})
+	}
+	for id, legacyID := range rules.IDs {
+		converter := r.converters[id]
+		converter.ID = legacyID
+		r.converters[id] = converter
+	}
+	return r
+}
+
+// downgradeLegacySign moves the text on the front of a sign to the fields of legacy signs, which only have one side.
+// Only the fields passed are kept besides the text.
+func downgradeLegacySign(data map[string]any, fields []string) {
+	front, _ := data["FrontText"].(map[string]any)
+	delete(data, "FrontText")
+	delete(data, "BackText")
+	delete(data, "IsWaxed")
+
+	text, _ := front["Text"].(string)
+	data["Text"] = text
+	for _, k := range fields {
+		if v, ok := front[k]; ok {
+			data[k] = v
+		}
+	}
+}
+
+// upgradeLegacySign moves the text and the fields passed of a legacy sign to the front of the sign.
+func upgradeLegacySign(data map[string]any, fields []string) {
+	text, _ := data["Text"].(string)
+	front := map[string]any{"Text": text}
+	delete(data, "Text")
+	for _, k := range fields {
+		if v, ok := data[k]; ok {
+			front[k] = v
+			delete(data, k)
+		}
+	}
+	data["FrontText"] = front
+	data["BackText"] = map[string]any{"Text": ""}
+}
+
+// downgradeLegacyBed stores the colour of a bed as the byte legacy versions expect. Beds with a missing or unknown
+// colour are made red, the colour of the default bed.
+func downgradeLegacyBed(data map[string]any) {
+	colour := int64(-1)
+	switch v := data["color"].(type) {
+	case uint8:
+		colour = int64(v)
+	case int16:
+		colour = int64(v)
+	case int32:
+		colour = int64(v)
+	case int64:
+		colour = v
+	}
+	if colour < 0 || colour > 15 {
+		colour = 14
+	}
+	data["color"] = uint8(colour)
+}
+
+// renameFields renames the fields of the data passed from their names in the latest version to those in the legacy
+// version, or the other way around if reverse is true.
+func renameFields(data map[string]any, fields map[string]string, reverse bool) {
+	for latest, legacy := range fields {
+		from, to := latest, legacy
+		if reverse {
+			from, to = legacy, latest
+		}
+		if v, ok := data[from]; ok {
+			delete(data, from)
+			data[to] = v
+		}
+	}
+}
+
+// translateItemData translates a single item or a list of items encoded as NBT using the function passed.
+func translateItemData(v any, f func(map[string]any) map[string]any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		return f(v)
+	case []any:
+		for i, item := range v {
+			if item, ok := item.(map[string]any); ok {
+				v[i] = f(item)
+			}
+		}
+	}
+	return v
+}
@@ -0,0 +1,70 @@
+package translator
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/sandertv/gophertunnel/minecraft/nbt"
+)
+
+// TestLegacyBlockActors converts the block actors in testdata with a legacy block actor registry. Every
+// '<name>_latest.nbt' fixture must downgrade to '<name>_legacy.nbt', and fixtures that round trip must upgrade back.
+func TestLegacyBlockActors(t *testing.T) {
+	r := NewLegacyBlockActorRegistry(LegacyBlockActorRules{SignFields: []string{"TextOwner", "SignTextColor", "IgnoreLighting"}})
+	for _, test := range []struct {
+		name      string
+		roundTrip bool
+	}{
+		{name: "sign", roundTrip: true},
+		{name: "hanging_sign"},
+		{name: "banner", roundTrip: true},
+		{name: "bed", roundTrip: true},
+		{name: "bed_int_colour"},
+		{name: "bed_no_colour"},
+		{name: "chest", roundTrip: true},
+		{name: "chiseled_bookshelf"},
+		{name: "decorated_pot"},
+		{name: "sculk_sensor"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			latest, legacy := readBlockActor(t, test.name+"_latest"), readBlockActor(t, test.name+"_legacy")
+			if downgraded := r.Downgrade(readBlockActor(t, test.name+"_latest"), nil); !reflect.DeepEqual(downgraded, legacy) {
+				t.Fatalf("downgrade: expected %v, got %v", legacy, downgraded)
+			}
+			if !test.roundTrip {
+				return
+			}
+			if upgraded := r.Upgrade(legacy, nil); !reflect.DeepEqual(upgraded, latest) {
+				t.Fatalf("upgrade: expected %v, got %v", latest, upgraded)
+			}
+		})
+	}
+}
+
+// TestLegacyBlockActorIDs checks that the IDs of legacy block actor rules replace the default legacy IDs.
+func TestLegacyBlockActorIDs(t *testing.T) {
+	r := NewLegacyBlockActorRegistry(LegacyBlockActorRules{IDs: map[string]string{"SculkSensor": "DaylightDetector"}})
+	legacy := readBlockActor(t, "sculk_sensor_legacy")
+	legacy["id"] = "DaylightDetector"
+	if downgraded := r.Downgrade(readBlockActor(t, "sculk_sensor_latest"), nil); !reflect.DeepEqual(downgraded, legacy) {
+		t.Fatalf("downgrade: expected %v, got %v", legacy, downgraded)
+	}
+	if upgraded := r.Upgrade(legacy, nil); !reflect.DeepEqual(upgraded, legacy) {
+		t.Fatalf("upgrade: expected %v to be left untouched, got %v", legacy, upgraded)
+	}
+}
+
+// readBlockActor reads the block actor data in the fixture with the name passed.
+func readBlockActor(t *testing.T, name string) map[string]any {
+	b, err := os.ReadFile(filepath.Join("testdata", name+".nbt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data map[string]any
+	if err := nbt.UnmarshalEncoding(b, &data, nbt.LittleEndian); err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
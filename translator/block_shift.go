@@ -0,0 +1,79 @@
+package translator
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// shiftPositions moves the positions of the players, entities, sounds and particles in the packet passed up by the
+// shift passed, so that they line up with the blocks shifted by HeightPolicyShift. Block positions that the block
+// packets already shift are left untouched.
+func shiftPositions(pk packet.Packet, shift int32) {
+	if shift == 0 {
+		return
+	}
+	switch pk := pk.(type) {
+	case *packet.AddActor:
+		shiftVec(&pk.Position, shift)
+	case *packet.AddItemActor:
+		shiftVec(&pk.Position, shift)
+	case *packet.AddPainting:
+		shiftVec(&pk.Position, shift)
+	case *packet.AddPlayer:
+		shiftVec(&pk.Position, shift)
+	case *packet.ChangeDimension:
+		shiftVec(&pk.Position, shift)
+	case *packet.CorrectPlayerMovePrediction:
+		shiftVec(&pk.Position, shift)
+	case *packet.LevelEvent:
+		shiftVec(&pk.Position, shift)
+	case *packet.LevelSoundEvent:
+		shiftVec(&pk.Position, shift)
+	case *packet.MoveActorAbsolute:
+		shiftVec(&pk.Position, shift)
+	case *packet.MoveActorDelta:
+		if pk.Flags&packet.MoveActorDeltaFlagHasY != 0 {
+			shiftVec(&pk.Position, shift)
+		}
+	case *packet.MovePlayer:
+		shiftVec(&pk.Position, shift)
+	case *packet.PlayerAction:
+		pk.BlockPosition[1] += shift
+		pk.ResultPosition[1] += shift
+	case *packet.PlayerAuthInput:
+		shiftVec(&pk.Position, shift)
+		for i := range pk.BlockActions {
+			pk.BlockActions[i].BlockPos[1] += shift
+		}
+		if pk.InputData&packet.InputFlagPerformItemInteraction != 0 {
+			pk.ItemInteractionData.BlockPosition[1] += shift
+			shiftVec(&pk.ItemInteractionData.Position, shift)
+		}
+	case *packet.Respawn:
+		shiftVec(&pk.Position, shift)
+	case *packet.SetSpawnPosition:
+		pk.Position[1] += shift
+		pk.SpawnPosition[1] += shift
+	case *packet.SpawnParticleEffect:
+		shiftVec(&pk.Position, shift)
+	case *packet.StartGame:
+		shiftVec(&pk.PlayerPosition, shift)
+		pk.WorldSpawn[1] += shift
+	case *packet.InventoryTransaction:
+		switch data := pk.TransactionData.(type) {
+		case *protocol.UseItemTransactionData:
+			// The block position of the transaction is shifted along with its block runtime ID.
+			shiftVec(&data.Position, shift)
+		case *protocol.UseItemOnEntityTransactionData:
+			shiftVec(&data.Position, shift)
+		case *protocol.ReleaseItemTransactionData:
+			shiftVec(&data.HeadPosition, shift)
+		}
+	}
+}
+
+// shiftVec moves the position passed up by the shift passed.
+func shiftVec(pos *mgl32.Vec3, shift int32) {
+	pos[1] += float32(shift)
+}
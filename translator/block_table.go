@@ -0,0 +1,54 @@
+package translator
+
+import (
+	"github.com/flonja/multiversion/mapping"
+)
+
+// runtimeIDTables holds the translation of every block runtime ID between the legacy and the latest version, so that
+// translating a runtime ID is a single slice lookup.
+type runtimeIDTables struct {
+	// downgrade holds the legacy runtime ID of every latest runtime ID.
+	downgrade []uint32
+	// upgrade holds the latest runtime ID of every legacy runtime ID.
+	upgrade []uint32
+	// legacyAir and latestAir are the runtime IDs of air, used for runtime IDs not present in the tables.
+	legacyAir, latestAir uint32
+}
+
+// newRuntimeIDTables creates the runtime ID tables of the translator passed, using the slow path of the translator
+// to translate every runtime ID of both versions once.
+func newRuntimeIDTables(t *DefaultBlockTranslator) *runtimeIDTables {
+	return &runtimeIDTables{
+		downgrade: translateAll(t.latest, t.downgradeRuntimeID),
+		upgrade:   translateAll(t.mapping, t.upgradeRuntimeID),
+		legacyAir: t.mapping.Air(),
+		latestAir: t.latest.Air(),
+	}
+}
+
+// translateAll translates every runtime ID of the block mapping passed with the function passed.
+func translateAll(m mapping.Block, translate func(uint32) uint32) []uint32 {
+	var table []uint32
+	for rid := uint32(0); ; rid++ {
+		if _, ok := m.RuntimeIDToHash(rid); !ok {
+			return table
+		}
+		table = append(table, translate(rid))
+	}
+}
+
+// downgradeRuntimeID downgrades a latest runtime ID to a legacy runtime ID using the table.
+func (t *runtimeIDTables) downgradeRuntimeID(input uint32) uint32 {
+	if input < uint32(len(t.downgrade)) {
+		return t.downgrade[input]
+	}
+	return t.legacyAir
+}
+
+// upgradeRuntimeID upgrades a legacy runtime ID to a latest runtime ID using the table.
+func (t *runtimeIDTables) upgradeRuntimeID(input uint32) uint32 {
+	if input < uint32(len(t.upgrade)) {
+		return t.upgrade[input]
+	}
+	return t.latestAir
+}
@@ -0,0 +1,203 @@
+package translator
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/flonja/multiversion/internal/chunk"
+	"github.com/flonja/multiversion/mapping"
+	"github.com/flonja/multiversion/protocols/latest"
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// newTestBlockTranslator creates a block translator between two copies of the latest block mapping.
+func newTestBlockTranslator() *DefaultBlockTranslator {
+	return NewBlockTranslator(latest.NewBlockMapping(), latest.NewBlockMapping(), latest.NewBiomeMapping(), latest.NewBiomeMapping())
+}
+
+// newTestChunk creates a chunk of the latest version holding the 24 sub chunks of the overworld, with a different block
+// in every column of every sub chunk.
+func newTestChunk(m mapping.Block) *chunk.Chunk {
+	r := world.Overworld.Range()
+	c := chunk.New(m.Air(), r)
+	for y := r.Min(); y <= r.Max(); y++ {
+		for x := uint8(0); x < 16; x++ {
+			for z := uint8(0); z < 16; z++ {
+				c.SetBlock(x, int16(y), z, 0, uint32(x)<<4|uint32(z))
+			}
+		}
+	}
+	return c
+}
+
+// newTestLevelChunk encodes the chunk passed in a LevelChunk packet without border blocks and block actors.
+func newTestLevelChunk(c *chunk.Chunk) *packet.LevelChunk {
+	payload, _ := chunk.NetworkEncode(c, false)
+	return &packet.LevelChunk{SubChunkCount: uint32(len(c.Sub())), RawPayload: append(append([]byte(nil), payload...), 0)}
+}
+
+// newTestSubChunk encodes the lowest sub chunk of the chunk passed in a SubChunk packet.
+func newTestSubChunk(c *chunk.Chunk) *packet.SubChunk {
+	return &packet.SubChunk{
+		Position: protocol.SubChunkPos{0, int32(c.Range().Min() >> 4), 0},
+		SubChunkEntries: []protocol.SubChunkEntry{{
+			Result:     protocol.SubChunkResultSuccess,
+			RawPayload: chunk.EncodeSubChunk(c.Sub()[0], chunk.NetworkEncoding, chunk.SubChunkVersion9, c.Range(), 0),
+		}},
+	}
+}
+
+// BenchmarkDowngradeLevelChunk downgrades a LevelChunk packet holding 24 sub chunks.
+func BenchmarkDowngradeLevelChunk(b *testing.B) {
+	t := newTestBlockTranslator()
+	pk := newTestLevelChunk(newTestChunk(t.latest))
+	t.DowngradeBlockPackets([]packet.Packet{&packet.LevelChunk{SubChunkCount: pk.SubChunkCount, RawPayload: pk.RawPayload}}, nil)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		t.DowngradeBlockPackets([]packet.Packet{&packet.LevelChunk{SubChunkCount: pk.SubChunkCount, RawPayload: pk.RawPayload}}, nil)
+	}
+}
+
+// BenchmarkDowngradeBlockRuntimeID downgrades every block runtime ID using the runtime ID tables.
+func BenchmarkDowngradeBlockRuntimeID(b *testing.B) {
+	t := newTestBlockTranslator()
+	n := uint32(len(t.runtimeIDTables().downgrade))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		t.DowngradeBlockRuntimeID(uint32(i) % n)
+	}
+}
+
+// BenchmarkDowngradeBlockRuntimeIDSlow downgrades every block runtime ID without using the runtime ID tables.
+func BenchmarkDowngradeBlockRuntimeIDSlow(b *testing.B) {
+	t := newTestBlockTranslator()
+	n := uint32(len(t.runtimeIDTables().downgrade))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		t.downgradeRuntimeID(uint32(i) % n)
+	}
+}
+
+// BenchmarkUpgradeBlockRuntimeID upgrades every block runtime ID using the runtime ID tables.
+func BenchmarkUpgradeBlockRuntimeID(b *testing.B) {
+	t := newTestBlockTranslator()
+	n := uint32(len(t.runtimeIDTables().upgrade))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		t.UpgradeBlockRuntimeID(uint32(i) % n)
+	}
+}
+
+// BenchmarkUpgradeBlockRuntimeIDSlow upgrades every block runtime ID without using the runtime ID tables.
+func BenchmarkUpgradeBlockRuntimeIDSlow(b *testing.B) {
+	t := newTestBlockTranslator()
+	n := uint32(len(t.runtimeIDTables().upgrade))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		t.upgradeRuntimeID(uint32(i) % n)
+	}
+}
+
+// BenchmarkBlockTranslatorOverlay creates an overlay for a new connection and translates a single runtime ID with it,
+// which must not build the runtime ID tables again.
+func BenchmarkBlockTranslatorOverlay(b *testing.B) {
+	t := newTestBlockTranslator()
+	t.runtimeIDTables()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		t.Overlay(nil).DowngradeBlockRuntimeID(uint32(i))
+	}
+}
+
+// TestBlockTranslatorOverlayTables checks that overlays share the runtime ID tables of the shared translator until they
+// are adjusted.
+func TestBlockTranslatorOverlayTables(t *testing.T) {
+	tr := newTestBlockTranslator()
+	first, second := tr.Overlay(nil).(*DefaultBlockTranslator), tr.Overlay(nil).(*DefaultBlockTranslator)
+	if first.tables.Load() == nil || first.tables.Load() != second.tables.Load() {
+		t.Fatalf("overlays do not share the runtime ID tables of the shared translator")
+	}
+}
+
+// TestBlockTranslatorShiftPositions checks that HeightPolicyShift shifts the positions of entities and players along
+// with the blocks, and that entities other than falling blocks are kept.
+func TestBlockTranslatorShiftPositions(t *testing.T) {
+	tr := newTestBlockTranslator().WithLegacyChunkFormat()
+	tr.SetHeightPolicy(HeightPolicyShift)
+	overlay := tr.Overlay(NewDimension(packet.DimensionOverworld))
+
+	actor := &packet.AddActor{EntityType: "minecraft:pig", Position: mgl32.Vec3{0, -60, 0}}
+	move := &packet.MovePlayer{Position: mgl32.Vec3{0, 10, 0}}
+	result := overlay.DowngradeBlockPackets([]packet.Packet{actor, move}, nil)
+	if len(result) != 2 {
+		t.Fatalf("expected 2 packets, got %v", len(result))
+	}
+	if actor.Position[1] != 4 || move.Position[1] != 74 {
+		t.Fatalf("expected shifted positions 4 and 74, got %v and %v", actor.Position[1], move.Position[1])
+	}
+
+	input := &packet.PlayerAuthInput{Position: mgl32.Vec3{0, 74, 0}}
+	overlay.UpgradeBlockPackets([]packet.Packet{input}, nil)
+	if input.Position[1] != 10 {
+		t.Fatalf("expected upgraded position 10, got %v", input.Position[1])
+	}
+}
+
+// TestBlockTranslatorConcurrentChunks downgrades chunks and sub chunks with a shared translator and its overlays from
+// many goroutines, while some of the overlays are adjusted. It is meant to be run with the race detector enabled.
+func TestBlockTranslatorConcurrentChunks(t *testing.T) {
+	const goroutines = 16
+
+	tr := newTestBlockTranslator()
+	c := newTestChunk(tr.latest)
+	level, sub := newTestLevelChunk(c), newTestSubChunk(c)
+	downgradeLevel := func(bt BlockTranslator) []byte {
+		return bt.DowngradeBlockPackets([]packet.Packet{&packet.LevelChunk{SubChunkCount: level.SubChunkCount, RawPayload: level.RawPayload}}, nil)[0].(*packet.LevelChunk).RawPayload
+	}
+	downgradeSub := func(bt BlockTranslator) []byte {
+		entries := append([]protocol.SubChunkEntry(nil), sub.SubChunkEntries...)
+		return bt.DowngradeBlockPackets([]packet.Packet{&packet.SubChunk{Position: sub.Position, SubChunkEntries: entries}}, nil)[0].(*packet.SubChunk).SubChunkEntries[0].RawPayload
+	}
+	expectedLevel, expectedSub := downgradeLevel(newTestBlockTranslator()), downgradeSub(newTestBlockTranslator())
+
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var bt BlockTranslator = tr
+			if i%2 == 1 {
+				bt = tr.Overlay(NewDimension(packet.DimensionOverworld))
+			}
+			adjusted := i%4 == 3
+			if adjusted {
+				bt.DowngradeBlockPackets([]packet.Packet{&packet.StartGame{Blocks: []protocol.BlockEntry{
+					{Name: fmt.Sprintf("custom:block_%v", i), Properties: map[string]any{}},
+				}}}, nil)
+			}
+			for j := 0; j < 4; j++ {
+				levelPayload, subPayload := downgradeLevel(bt), downgradeSub(bt)
+				if adjusted {
+					// Custom blocks may change the runtime IDs of the overlay, so only the shared mapping is checked.
+					continue
+				}
+				if !bytes.Equal(levelPayload, expectedLevel) {
+					t.Errorf("goroutine %v: level chunk downgraded differently", i)
+				}
+				if !bytes.Equal(subPayload, expectedSub) {
+					t.Errorf("goroutine %v: sub chunk downgraded differently", i)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if !bytes.Equal(downgradeLevel(tr), expectedLevel) {
+		t.Errorf("adjusting overlays affected the shared translator")
+	}
+}
@@ -0,0 +1,72 @@
+package translator
+
+import (
+	"sync"
+
+	"github.com/sandertv/gophertunnel/minecraft"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// Context holds the translation state of a single connection. The custom items and block states sent in the StartGame
+// packet of the connection are registered on overlays of the shared mappings, so that connections to servers with
+// different custom items or blocks never affect each other. The dimension the connection is in is tracked as well.
+type Context struct {
+	Items     ItemTranslator
+	Blocks    BlockTranslator
+	Dimension *Dimension
+}
+
+// Contexts holds the contexts of all connections that started the game.
+type Contexts struct {
+	shared *Context
+
+	mu       sync.Mutex
+	contexts map[*minecraft.Conn]*Context
+}
+
+// NewContexts creates a new set of contexts. The translators passed are shared by all connections and are only used
+// directly for connections that did not start the game yet.
+func NewContexts(items ItemTranslator, blocks BlockTranslator) *Contexts {
+	blocks.SetItemTranslator(items)
+	return &Contexts{shared: &Context{Items: items, Blocks: blocks, Dimension: NewDimension(packet.DimensionOverworld)},
+		contexts: make(map[*minecraft.Conn]*Context)}
+}
+
+// Context returns the context to translate the packet passed with. A new context is created for the connection if
+// the packet is a StartGame packet, and the context is released after the packet if it is a Disconnect packet. The
+// dimension of the context is updated before it is returned.
+func (c *Contexts) Context(pk packet.Packet, conn *minecraft.Conn) *Context {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch pk := pk.(type) {
+	case *packet.StartGame:
+		dimension := NewDimension(pk.Dimension)
+		blocks := c.shared.Blocks.Overlay(dimension)
+		items := c.shared.Items.Overlay(blocks.BlockMappings())
+		blocks.SetItemTranslator(items)
+		ctx := &Context{Items: items, Blocks: blocks, Dimension: dimension}
+		c.contexts[conn] = ctx
+		return ctx
+	case *packet.ChangeDimension, *packet.DimensionData:
+		if ctx, ok := c.contexts[conn]; ok {
+			ctx.Dimension.Update(pk)
+		}
+	case *packet.Disconnect:
+		if ctx, ok := c.contexts[conn]; ok {
+			delete(c.contexts, conn)
+			return ctx
+		}
+	}
+	if ctx, ok := c.contexts[conn]; ok {
+		return ctx
+	}
+	return c.shared
+}
+
+// Release releases the context of the connection passed. It should be called for connections that were closed
+// without a Disconnect packet being sent.
+func (c *Contexts) Release(conn *minecraft.Conn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.contexts, conn)
+}
@@ -0,0 +1,70 @@
+package translator
+
+import (
+	"sync"
+
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// dimensionIDs holds the IDs of the dimensions that can be defined through the DimensionData packet, indexed by name.
+var dimensionIDs = map[string]int32{
+	"minecraft:overworld": packet.DimensionOverworld,
+	"minecraft:nether":    packet.DimensionNether,
+	"minecraft:the_end":   packet.DimensionEnd,
+}
+
+// Dimension holds the dimension a single connection is in, together with the height ranges of the dimensions of the
+// server. It is updated through the StartGame, ChangeDimension and DimensionData packets sent to the connection.
+type Dimension struct {
+	mu     sync.Mutex
+	id     int32
+	ranges map[int32]cube.Range
+}
+
+// NewDimension creates a dimension with the ID passed, using the vanilla height ranges of all dimensions.
+func NewDimension(id int32) *Dimension {
+	return &Dimension{id: id, ranges: map[int32]cube.Range{
+		packet.DimensionOverworld: world.Overworld.Range(),
+		packet.DimensionNether:    world.Nether.Range(),
+		packet.DimensionEnd:       world.End.Range(),
+	}}
+}
+
+// Update updates the dimension if the packet passed is a StartGame, ChangeDimension or DimensionData packet.
+func (d *Dimension) Update(pk packet.Packet) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	switch pk := pk.(type) {
+	case *packet.StartGame:
+		d.id = pk.Dimension
+	case *packet.ChangeDimension:
+		d.id = pk.Dimension
+	case *packet.DimensionData:
+		for _, def := range pk.Definitions {
+			if id, ok := dimensionIDs[def.Name]; ok {
+				// The maximum of the range sent is exclusive.
+				d.ranges[id] = cube.Range{int(def.Range[0]), int(def.Range[1]) - 1}
+			}
+		}
+	}
+}
+
+// ID returns the ID of the dimension the connection is in.
+func (d *Dimension) ID() int32 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.id
+}
+
+// Range returns the height range of the dimension the connection is in. Unknown dimensions have the height range of
+// the overworld.
+func (d *Dimension) Range() cube.Range {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if r, ok := d.ranges[d.id]; ok {
+		return r
+	}
+	return world.Overworld.Range()
+}
@@ -0,0 +1,180 @@
+package translator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/sandertv/gophertunnel/minecraft"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// Emulator emulates packets of the latest version that do not exist in a legacy version using packets that do, so
+// that servers can use newer features without checking the version of the client.
+type Emulator interface {
+	// Emulate returns the packets of the latest version sent to the legacy client in place of a packet that does not
+	// exist in its version. False is returned if the packet cannot be emulated, in which case it is dropped.
+	Emulate(pk packet.Packet, conn *minecraft.Conn) ([]packet.Packet, bool)
+	// Consume returns the packets of the latest version passed to the server in place of a packet sent by the legacy
+	// client in response to an emulated packet. False is returned if the packet is not such a response, in which case
+	// it is passed on untouched.
+	Consume(pk packet.Packet, conn *minecraft.Conn) ([]packet.Packet, bool)
+	// Release releases the emulation state of the connection passed.
+	Release(conn *minecraft.Conn)
+}
+
+// emulatedFormID is the ID of the forms sent in place of NPC dialogues.
+const emulatedFormID = 0x7fffffff
+
+// fadeCharacter is the character that fills the title sent in place of a camera fade.
+const fadeCharacter = "█"
+
+type DefaultEmulator struct {
+	// toastsInChat sends toasts as chat messages instead of on the action bar.
+	toastsInChat bool
+
+	mu sync.Mutex
+	// dialogues holds the NPC dialogue opened for every connection, which the next response to an emulated form
+	// belongs to.
+	dialogues map[*minecraft.Conn]npcDialogue
+}
+
+// npcDialogue is an NPC dialogue shown to a legacy client as a form.
+type npcDialogue struct {
+	// entityRuntimeID is the runtime ID of the NPC. Dialogues only hold the unique ID of the NPC, which servers such as
+	// Dragonfly use as its runtime ID too.
+	entityRuntimeID uint64
+	// sceneName is the name of the scene of the dialogue.
+	sceneName string
+}
+
+// NewEmulator creates a new emulator, which emulates toasts, NPC dialogues, sign editing and camera fades.
+func NewEmulator() *DefaultEmulator {
+	return &DefaultEmulator{dialogues: make(map[*minecraft.Conn]npcDialogue)}
+}
+
+// WithToastsInChat sends toasts as chat messages instead of on the action bar.
+func (e *DefaultEmulator) WithToastsInChat() *DefaultEmulator {
+	e.toastsInChat = true
+	return e
+}
+
+func (e *DefaultEmulator) Emulate(pk packet.Packet, conn *minecraft.Conn) ([]packet.Packet, bool) {
+	switch pk := pk.(type) {
+	case *packet.ToastRequest:
+		text := pk.Title
+		if pk.Message != "" {
+			text += "\n" + pk.Message
+		}
+		if e.toastsInChat {
+			return []packet.Packet{&packet.Text{TextType: packet.TextTypeRaw, Message: text}}, true
+		}
+		return []packet.Packet{&packet.SetTitle{ActionType: packet.TitleActionSetActionBar, Text: text}}, true
+	case *packet.NPCDialogue:
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		if pk.ActionType != packet.NPCDialogueActionOpen {
+			// Forms can't be closed by the server in legacy versions, so closing the dialogue is not emulated.
+			delete(e.dialogues, conn)
+			return nil, true
+		}
+		e.dialogues[conn] = npcDialogue{entityRuntimeID: pk.EntityUniqueID, sceneName: pk.SceneName}
+		return []packet.Packet{&packet.ModalFormRequest{FormID: emulatedFormID, FormData: npcDialogueForm(pk)}}, true
+	case *packet.OpenSign:
+		// Legacy clients can't be made to edit a sign, so the player is told to edit the sign themselves.
+		return []packet.Packet{&packet.Text{TextType: packet.TextTypeRaw, Message: "Use the sign to edit its text."}}, true
+	case *packet.CameraInstruction:
+		fade, ok := pk.Data["fade"].(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		return cameraFadeTitle(fade), true
+	}
+	return nil, false
+}
+
+func (e *DefaultEmulator) Consume(pk packet.Packet, conn *minecraft.Conn) ([]packet.Packet, bool) {
+	response, ok := pk.(*packet.ModalFormResponse)
+	if !ok || response.FormID != emulatedFormID {
+		return nil, false
+	}
+	e.mu.Lock()
+	dialogue, ok := e.dialogues[conn]
+	delete(e.dialogues, conn)
+	e.mu.Unlock()
+	if !ok {
+		return nil, true
+	}
+
+	request := &packet.NPCRequest{EntityRuntimeID: dialogue.entityRuntimeID, RequestType: packet.NPCRequestActionExecuteClosingCommands, SceneName: dialogue.sceneName}
+	var index *uint8
+	if data, ok := response.ResponseData.Value(); ok && json.Unmarshal(bytes.TrimSpace(data), &index) == nil && index != nil {
+		// The form has a button for every action of the dialogue, in the same order.
+		request.RequestType, request.ActionType = packet.NPCRequestActionExecuteAction, *index
+	}
+	return []packet.Packet{request}, true
+}
+
+func (e *DefaultEmulator) Release(conn *minecraft.Conn) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.dialogues, conn)
+}
+
+// npcDialogueForm encodes a menu form showing the NPC dialogue passed, with a button for every action of the NPC.
+func npcDialogueForm(pk *packet.NPCDialogue) []byte {
+	var actions []struct {
+		ButtonName string `json:"button_name"`
+	}
+	_ = json.Unmarshal([]byte(pk.ActionJSON), &actions)
+
+	buttons := make([]map[string]any, 0, len(actions))
+	for _, action := range actions {
+		buttons = append(buttons, map[string]any{"text": action.ButtonName})
+	}
+	data, err := json.Marshal(map[string]any{"type": "form", "title": pk.NPCName, "content": pk.Dialogue, "buttons": buttons})
+	if err != nil {
+		fmt.Println(err)
+	}
+	return data
+}
+
+// cameraFadeTitle returns the title packets sent in place of the camera fade passed. The title is filled with the
+// formatting colour closest to the colour of the fade, and fades in and out along with it.
+func cameraFadeTitle(fade map[string]any) []packet.Packet {
+	durations, _ := fade["time"].(map[string]any)
+	colour, _ := fade["color"].(map[string]any)
+	ticks := func(k string) int32 {
+		seconds, _ := durations[k].(float32)
+		return int32(seconds * 20)
+	}
+	component := func(k string) float32 {
+		v, _ := colour[k].(float32)
+		return v
+	}
+	return []packet.Packet{
+		&packet.SetTitle{ActionType: packet.TitleActionSetDurations, FadeInDuration: ticks("fadeIn"), RemainDuration: ticks("hold"), FadeOutDuration: ticks("fadeOut")},
+		&packet.SetTitle{ActionType: packet.TitleActionSetTitle, Text: closestColourCode(component("r"), component("g"), component("b")) + strings.Repeat(fadeCharacter, 16)},
+	}
+}
+
+// colourCodes holds the RGB values of the formatting colours, indexed by their formatting codes.
+var colourCodes = map[string][3]float32{
+	"§0": {0, 0, 0}, "§1": {0, 0, 0.67}, "§2": {0, 0.67, 0}, "§3": {0, 0.67, 0.67},
+	"§4": {0.67, 0, 0}, "§5": {0.67, 0, 0.67}, "§6": {1, 0.67, 0}, "§7": {0.67, 0.67, 0.67},
+	"§8": {0.33, 0.33, 0.33}, "§9": {0.33, 0.33, 1}, "§a": {0.33, 1, 0.33}, "§b": {0.33, 1, 1},
+	"§c": {1, 0.33, 0.33}, "§d": {1, 0.33, 1}, "§e": {1, 1, 0.33}, "§f": {1, 1, 1},
+}
+
+// closestColourCode returns the formatting code of the colour closest to the colour passed.
+func closestColourCode(r, g, b float32) string {
+	closest, distance := "§0", float32(4)
+	for code, c := range colourCodes {
+		if d := (c[0]-r)*(c[0]-r) + (c[1]-g)*(c[1]-g) + (c[2]-b)*(c[2]-b); d < distance || d == distance && code < closest {
+			closest, distance = code, d
+		}
+	}
+	return closest
+}
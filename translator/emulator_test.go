@@ -0,0 +1,35 @@
+package translator
+
+import (
+	"testing"
+
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// TestEmulatorNPCDialogueResponse checks that the responses to emulated NPC dialogues are passed to the server as NPC
+// requests for the NPC of the dialogue.
+func TestEmulatorNPCDialogueResponse(t *testing.T) {
+	for name, test := range map[string]struct {
+		data        []byte
+		requestType byte
+		actionType  byte
+	}{
+		"button":    {data: []byte("2\n"), requestType: packet.NPCRequestActionExecuteAction, actionType: 2},
+		"cancelled": {data: []byte("null\n"), requestType: packet.NPCRequestActionExecuteClosingCommands},
+	} {
+		e := NewEmulator()
+		e.Emulate(&packet.NPCDialogue{EntityUniqueID: 5, ActionType: packet.NPCDialogueActionOpen, SceneName: "scene", ActionJSON: "[]"}, nil)
+		consumed, ok := e.Consume(&packet.ModalFormResponse{FormID: emulatedFormID, ResponseData: protocol.Option(test.data)}, nil)
+		if !ok || len(consumed) != 1 {
+			t.Fatalf("%v: expected a single NPC request, got %v", name, consumed)
+		}
+		request, ok := consumed[0].(*packet.NPCRequest)
+		if !ok || request.EntityRuntimeID != 5 || request.SceneName != "scene" || request.RequestType != test.requestType || request.ActionType != test.actionType {
+			t.Fatalf("%v: unexpected NPC request %+v", name, consumed[0])
+		}
+		if consumed, ok := e.Consume(&packet.ModalFormResponse{FormID: emulatedFormID}, nil); !ok || len(consumed) != 0 {
+			t.Fatalf("%v: expected a second response to be dropped, got %v", name, consumed)
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package translator
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/sandertv/gophertunnel/minecraft"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+type EntityTranslator interface {
+	// DowngradeEntityType downgrades the input entity type to a legacy entity type. False is returned if the entity
+	// should not be shown to the legacy client at all.
+	DowngradeEntityType(entityType string) (EntitySubstitute, bool)
+	// DowngradeEntityPackets downgrades the input entity packets to legacy entity packets.
+	DowngradeEntityPackets(pks []packet.Packet, conn *minecraft.Conn) []packet.Packet
+	// Register registers a substitute shown to legacy clients in place of an entity type.
+	Register(entityType string, substitute EntitySubstitute)
+	// SetUnknownEntityPolicy sets the policy for entity types that do not exist in the legacy version and have no
+	// substitute registered.
+	SetUnknownEntityPolicy(policy UnknownEntityPolicy)
+	// Release releases the entities tracked for the connection passed. It should be called for connections that were
+	// closed without a Disconnect packet being sent.
+	Release(conn *minecraft.Conn)
+}
+
+// EntitySubstitute is an entity shown to legacy clients in place of an entity type that does not exist in the legacy
+// version.
+type EntitySubstitute struct {
+	// EntityType is the entity type shown to the legacy client, for example 'minecraft:horse'.
+	EntityType string
+	// Scale is multiplied with the scale of the entity, so that the substitute roughly matches the size of the
+	// original entity. A scale of zero leaves the scale untouched.
+	Scale float32
+	// Width and Height overwrite the bounding box of the entity if non-zero.
+	Width, Height float32
+	// NameTag is shown above the substitute if non-empty.
+	NameTag string
+}
+
+// UnknownEntityPolicy is the policy for entity types that do not exist in the legacy version and have no substitute
+// registered.
+type UnknownEntityPolicy int
+
+const (
+	// UnknownEntityPolicyDrop drops the entity, so that the legacy client never sees it.
+	UnknownEntityPolicyDrop UnknownEntityPolicy = iota
+	// UnknownEntityPolicyArmourStand replaces the entity with an armour stand named after the original entity type.
+	UnknownEntityPolicyArmourStand
+)
+
+type DefaultEntityTranslator struct {
+	unknown     map[string]struct{}
+	substitutes map[string]EntitySubstitute
+	policy      UnknownEntityPolicy
+
+	// entities holds the substituted entities spawned to every connection, so that metadata sent later on can be
+	// fixed up as well.
+	entities   map[*minecraft.Conn]*substitutedEntities
+	entitiesMu sync.Mutex
+}
+
+// substitutedEntities holds the substituted entities spawned to a single connection.
+type substitutedEntities struct {
+	// substitutes holds the substitute of every entity, indexed by its runtime ID.
+	substitutes map[uint64]EntitySubstitute
+	// runtimeIDs holds the runtime ID of every entity, indexed by its unique ID, as entities are removed by their
+	// unique ID.
+	runtimeIDs map[int64]uint64
+}
+
+// NewEntityTranslator creates a new entity translator. The entity types passed are the types of the latest version
+// that do not exist in the legacy version.
+func NewEntityTranslator(unknown []string) *DefaultEntityTranslator {
+	t := &DefaultEntityTranslator{unknown: make(map[string]struct{}), substitutes: make(map[string]EntitySubstitute),
+		entities: make(map[*minecraft.Conn]*substitutedEntities)}
+	for _, entityType := range unknown {
+		t.unknown[entityType] = struct{}{}
+	}
+	return t
+}
+
+func (t *DefaultEntityTranslator) DowngradeEntityType(entityType string) (EntitySubstitute, bool) {
+	if substitute, ok := t.substitutes[entityType]; ok {
+		return substitute, true
+	}
+	if _, ok := t.unknown[entityType]; !ok {
+		return EntitySubstitute{EntityType: entityType}, true
+	}
+	switch t.policy {
+	case UnknownEntityPolicyArmourStand:
+		return EntitySubstitute{EntityType: "minecraft:armor_stand", NameTag: entityDisplayName(entityType)}, true
+	}
+	return EntitySubstitute{}, false
+}
+
+func (t *DefaultEntityTranslator) DowngradeEntityPackets(pks []packet.Packet, conn *minecraft.Conn) (result []packet.Packet) {
+	for _, pk := range pks {
+		switch pk := pk.(type) {
+		case *packet.AddActor:
+			substitute, ok := t.DowngradeEntityType(pk.EntityType)
+			if !ok {
+				continue
+			}
+			if substitute.EntityType != pk.EntityType {
+				t.entitiesMu.Lock()
+				entities, ok := t.entities[conn]
+				if !ok {
+					entities = &substitutedEntities{substitutes: make(map[uint64]EntitySubstitute), runtimeIDs: make(map[int64]uint64)}
+					t.entities[conn] = entities
+				}
+				entities.substitutes[pk.EntityRuntimeID] = substitute
+				entities.runtimeIDs[pk.EntityUniqueID] = pk.EntityRuntimeID
+				t.entitiesMu.Unlock()
+
+				// The packet may be shared between multiple connections, so we copy it instead of modifying it.
+				newPk := *pk
+				newPk.EntityType = substitute.EntityType
+				newPk.EntityMetadata = substitute.downgradeEntityMetadata(pk.EntityMetadata, true)
+				pk = &newPk
+			}
+			result = append(result, pk)
+			continue
+		case *packet.SetActorData:
+			var substitute EntitySubstitute
+			t.entitiesMu.Lock()
+			entities, ok := t.entities[conn]
+			if ok {
+				substitute, ok = entities.substitutes[pk.EntityRuntimeID]
+			}
+			t.entitiesMu.Unlock()
+			if ok {
+				newPk := *pk
+				newPk.EntityMetadata = substitute.downgradeEntityMetadata(pk.EntityMetadata, false)
+				pk = &newPk
+			}
+			result = append(result, pk)
+			continue
+		case *packet.RemoveActor:
+			t.entitiesMu.Lock()
+			if entities, ok := t.entities[conn]; ok {
+				if runtimeID, ok := entities.runtimeIDs[pk.EntityUniqueID]; ok {
+					delete(entities.substitutes, runtimeID)
+					delete(entities.runtimeIDs, pk.EntityUniqueID)
+				}
+				if len(entities.substitutes) == 0 {
+					delete(t.entities, conn)
+				}
+			}
+			t.entitiesMu.Unlock()
+		case *packet.Disconnect:
+			t.Release(conn)
+		}
+		result = append(result, pk)
+	}
+	return result
+}
+
+func (t *DefaultEntityTranslator) Register(entityType string, substitute EntitySubstitute) {
+	if _, ok := t.substitutes[entityType]; ok {
+		panic(fmt.Errorf("%v is already mapped", entityType))
+	}
+	t.substitutes[entityType] = substitute
+}
+
+func (t *DefaultEntityTranslator) SetUnknownEntityPolicy(policy UnknownEntityPolicy) {
+	t.policy = policy
+}
+
+func (t *DefaultEntityTranslator) Release(conn *minecraft.Conn) {
+	t.entitiesMu.Lock()
+	defer t.entitiesMu.Unlock()
+	delete(t.entities, conn)
+}
+
+// downgradeEntityMetadata returns a copy of the entity metadata passed with the size and name tag of the substitute
+// applied. The scale is only added if it is not present yet when add is true.
+func (s EntitySubstitute) downgradeEntityMetadata(data map[uint32]any, add bool) map[uint32]any {
+	newData := make(map[uint32]any, len(data))
+	for key, value := range data {
+		newData[key] = value
+	}
+	if s.Scale != 0 {
+		if scale, ok := newData[protocol.EntityDataKeyScale].(float32); ok {
+			newData[protocol.EntityDataKeyScale] = scale * s.Scale
+		} else if add {
+			newData[protocol.EntityDataKeyScale] = s.Scale
+		}
+	}
+	if s.Width != 0 {
+		newData[protocol.EntityDataKeyWidth] = s.Width
+	}
+	if s.Height != 0 {
+		newData[protocol.EntityDataKeyHeight] = s.Height
+	}
+	if s.NameTag != "" {
+		newData[protocol.EntityDataKeyName] = s.NameTag
+		newData[protocol.EntityDataKeyAlwaysShowNameTag] = uint8(1)
+		flags, _ := newData[protocol.EntityDataKeyFlags].(int64)
+		newData[protocol.EntityDataKeyFlags] = flags | 1<<protocol.EntityDataFlagShowName | 1<<protocol.EntityDataFlagAlwaysShowName
+	}
+	return newData
+}
+
+// entityDisplayName turns an entity type such as 'minecraft:glow_squid' into a readable name such as 'Glow Squid'.
+func entityDisplayName(entityType string) string {
+	_, name, ok := strings.Cut(entityType, ":")
+	if !ok {
+		name = entityType
+	}
+	words := strings.Split(name, "_")
+	for i, word := range words {
+		if word != "" {
+			words[i] = strings.ToUpper(word[:1]) + word[1:]
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// EntityFlagTranslator translates the entity flag bitfields found in entity metadata between the latest version and
+// a legacy version. Flags are spread over two int64 bitfields, with the second bitfield holding flags 64 and up.
+type EntityFlagTranslator struct {
+	// latestToLegacy maps a flag index of the latest version to the flag index of the legacy version.
+	latestToLegacy map[uint32]uint32
+	// legacyToLatest maps a flag index of the legacy version to the flag index of the latest version.
+	legacyToLatest []uint32
+}
+
+// NewEntityFlagTranslator creates a new entity flag translator. The flags passed hold the latest flag index for every
+// flag that exists in the legacy version, in the order of the legacy version.
+func NewEntityFlagTranslator(flags []uint32) *EntityFlagTranslator {
+	latestToLegacy := make(map[uint32]uint32, len(flags))
+	for legacy, latest := range flags {
+		latestToLegacy[latest] = uint32(legacy)
+	}
+	return &EntityFlagTranslator{latestToLegacy: latestToLegacy, legacyToLatest: flags}
+}
+
+// DowngradeEntityFlags downgrades the entity flag bitfields from latest version to legacy version. Flags that do not
+// exist in the legacy version are dropped.
+func (t *EntityFlagTranslator) DowngradeEntityFlags(flags, flagsTwo int64) (int64, int64) {
+	return remapEntityFlags(flags, flagsTwo, func(index uint32) (uint32, bool) {
+		legacy, ok := t.latestToLegacy[index]
+		return legacy, ok
+	})
+}
+
+// UpgradeEntityFlags upgrades the entity flag bitfields from legacy version to latest version. Flags that do not
+// exist in the latest version are dropped.
+func (t *EntityFlagTranslator) UpgradeEntityFlags(flags, flagsTwo int64) (int64, int64) {
+	return remapEntityFlags(flags, flagsTwo, func(index uint32) (uint32, bool) {
+		if int(index) >= len(t.legacyToLatest) {
+			return 0, false
+		}
+		return t.legacyToLatest[index], true
+	})
+}
+
+// remapEntityFlags moves every set flag in the two bitfields to the index returned by remap.
+func remapEntityFlags(flags, flagsTwo int64, remap func(index uint32) (uint32, bool)) (int64, int64) {
+	var in, out [2]uint64
+	in[0], in[1] = uint64(flags), uint64(flagsTwo)
+	for i := uint32(0); i < 128; i++ {
+		if in[i/64]&(1<<(i%64)) == 0 {
+			continue
+		}
+		if j, ok := remap(i); ok && j < 128 {
+			out[j/64] |= 1 << (j % 64)
+		}
+	}
+	return int64(out[0]), int64(out[1])
+}
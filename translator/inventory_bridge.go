@@ -0,0 +1,612 @@
+package translator
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/sandertv/gophertunnel/minecraft"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+const (
+	// windowIDInventory, windowIDOffHand, windowIDArmour and windowIDUI are the IDs of the windows that are always
+	// open for the player.
+	windowIDInventory = 0
+	windowIDOffHand   = 119
+	windowIDArmour    = 120
+	windowIDUI        = 124
+
+	// craftingResultWindow is the window of the inventory actions that take the result of a recipe.
+	craftingResultWindow = -4
+	// craftingIngredientWindow is the window of the inventory actions that consume the ingredients of a recipe.
+	craftingIngredientWindow = -5
+
+	// cursorSlot, craftingGridStart, craftingGridEnd and createdOutputSlot are slots of the UI window.
+	cursorSlot        = 0
+	craftingGridStart = 28
+	craftingGridEnd   = 40
+	createdOutputSlot = 50
+)
+
+// InventoryBridge bridges the inventories of legacy clients that run without server authoritative inventories.
+// Such clients change their inventories themselves, and send the changes in inventory transactions of the normal
+// type, which servers of the latest version no longer understand. The bridge keeps a shadow of the inventories of
+// every connection and turns these transactions into the equivalent item stack requests. If the server rejects such
+// a request, the slots changed are corrected client-side.
+//
+// Transactions are bridged using the packets the client sends, so the bridge must be used in both directions.
+type InventoryBridge struct {
+	// write writes the corrections of transactions that could not be bridged to the connection passed.
+	write func(conn *minecraft.Conn, pks []packet.Packet)
+
+	mu    sync.Mutex
+	conns map[*minecraft.Conn]*inventoryShadow
+}
+
+// inventoryShadow holds the inventories of a single connection, as last sent or confirmed by the server.
+type inventoryShadow struct {
+	// windows holds the items of every window, indexed by window ID and slot.
+	windows map[windowSlot]protocol.ItemInstance
+	// containers holds the container types of the windows opened by the server, indexed by window ID.
+	containers map[uint32]byte
+	// recipes holds the network IDs of the crafting recipes, indexed by their UUID.
+	recipes map[uuid.UUID]uint32
+	// creativeItems holds the network IDs of the creative items, indexed by their item type.
+	creativeItems map[protocol.ItemType]uint32
+	// recipe is the network ID of the recipe the client is crafting, set by the last crafting event.
+	recipe uint32
+	// crafting is true if the client sent a crafting event for a recipe that was not crafted yet.
+	crafting bool
+	// requestID is the ID of the last item stack request bridged.
+	requestID int32
+	// pending holds the slots changed by the bridged item stack requests the server did not respond to yet.
+	pending map[int32]*bridgedRequest
+	// changed holds the IDs of the last pending requests that changed the slots, indexed by window slot. Such slots are
+	// referred to by the request ID until the server responds, as their stack network IDs are not yet known.
+	changed map[windowSlot]int32
+}
+
+// windowSlot is a slot in a window of a legacy client.
+type windowSlot struct {
+	window, slot uint32
+}
+
+// bridgedRequest is an item stack request sent on behalf of a legacy client.
+type bridgedRequest struct {
+	// before holds the items of the slots changed by the request before the request, in the order they changed.
+	before []slotItem
+	// slots holds the window slots changed by the request, indexed by their container ID and slot.
+	slots map[[2]byte]windowSlot
+}
+
+// slotItem is an item in a window slot.
+type slotItem struct {
+	windowSlot
+	item protocol.ItemInstance
+}
+
+// NewInventoryBridge creates a new inventory bridge.
+func NewInventoryBridge() *InventoryBridge {
+	return &InventoryBridge{write: writePackets, conns: make(map[*minecraft.Conn]*inventoryShadow)}
+}
+
+// DowngradeInventoryPackets updates the inventory shadow of the connection with the packets of the latest version
+// passed. The responses to bridged item stack requests are dropped, and rejected requests are replaced with the
+// slots needed to revert the changes of the client.
+func (b *InventoryBridge) DowngradeInventoryPackets(pks []packet.Packet, conn *minecraft.Conn) (result []packet.Packet) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	shadow := b.shadow(conn)
+	for _, pk := range pks {
+		switch pk := pk.(type) {
+		case *packet.InventoryContent:
+			for ws := range shadow.windows {
+				if ws.window == pk.WindowID {
+					delete(shadow.windows, ws)
+				}
+			}
+			for slot, it := range pk.Content {
+				shadow.windows[windowSlot{window: pk.WindowID, slot: uint32(slot)}] = it
+			}
+		case *packet.InventorySlot:
+			shadow.windows[windowSlot{window: pk.WindowID, slot: pk.Slot}] = pk.NewItem
+		case *packet.ContainerOpen:
+			shadow.containers[uint32(pk.WindowID)] = pk.ContainerType
+		case *packet.ContainerClose:
+			delete(shadow.containers, uint32(pk.WindowID))
+		case *packet.CraftingData:
+			if pk.ClearRecipes {
+				shadow.recipes = make(map[uuid.UUID]uint32)
+			}
+			for _, recipe := range pk.Recipes {
+				switch recipe := recipe.(type) {
+				case *protocol.ShapelessRecipe:
+					shadow.recipes[recipe.UUID] = recipe.RecipeNetworkID
+				case *protocol.ShapedRecipe:
+					shadow.recipes[recipe.UUID] = recipe.RecipeNetworkID
+				}
+			}
+		case *packet.CreativeContent:
+			shadow.creativeItems = make(map[protocol.ItemType]uint32, len(pk.Items))
+			for _, creativeItem := range pk.Items {
+				if _, ok := shadow.creativeItems[creativeItem.Item.ItemType]; !ok {
+					shadow.creativeItems[creativeItem.Item.ItemType] = creativeItem.CreativeItemNetworkID
+				}
+			}
+		case *packet.ItemStackResponse:
+			responses := pk.Responses[:0]
+			for _, response := range pk.Responses {
+				if request, ok := shadow.pending[response.RequestID]; ok {
+					delete(shadow.pending, response.RequestID)
+					result = append(result, shadow.resolve(response.RequestID, request, response)...)
+					continue
+				}
+				responses = append(responses, response)
+			}
+			if len(responses) == 0 {
+				continue
+			}
+			pk.Responses = responses
+		case *packet.Disconnect:
+			delete(b.conns, conn)
+		}
+		result = append(result, pk)
+	}
+	return result
+}
+
+// UpgradeInventoryPackets replaces the inventory transactions of the normal type sent by the client with the
+// equivalent item stack requests. Transactions that cannot be bridged, and transactions of the mismatch type, are
+// dropped, and the client is sent its inventories as known by the server instead.
+func (b *InventoryBridge) UpgradeInventoryPackets(pks []packet.Packet, conn *minecraft.Conn) []packet.Packet {
+	b.mu.Lock()
+	shadow := b.shadow(conn)
+
+	var result, corrections []packet.Packet
+	for _, pk := range pks {
+		switch pk := pk.(type) {
+		case *packet.CraftingEvent:
+			shadow.recipe, shadow.crafting = shadow.recipes[pk.RecipeUUID]
+			continue
+		case *packet.InventoryTransaction:
+			switch pk.TransactionData.(type) {
+			case *protocol.NormalTransactionData:
+				if request, ok := shadow.request(pk.Actions); ok {
+					result = append(result, &packet.ItemStackRequest{Requests: []protocol.ItemStackRequest{request}})
+					continue
+				}
+				corrections = append(corrections, shadow.correct(pk.Actions)...)
+				continue
+			case *protocol.MismatchTransactionData:
+				corrections = append(corrections, shadow.resync()...)
+				continue
+			}
+		}
+		result = append(result, pk)
+	}
+	b.mu.Unlock()
+
+	// Corrections are written after unlocking, as written packets are passed to DowngradeInventoryPackets as well.
+	if conn != nil && len(corrections) > 0 {
+		b.write(conn, corrections)
+	}
+	return result
+}
+
+// Release releases the inventory shadow of the connection passed. It should be called for connections that were
+// closed without a Disconnect packet being sent.
+func (b *InventoryBridge) Release(conn *minecraft.Conn) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.conns, conn)
+}
+
+// shadow returns the inventory shadow of the connection passed, creating it if it does not yet exist.
+func (b *InventoryBridge) shadow(conn *minecraft.Conn) *inventoryShadow {
+	shadow, ok := b.conns[conn]
+	if !ok {
+		shadow = &inventoryShadow{
+			windows:       make(map[windowSlot]protocol.ItemInstance),
+			containers:    make(map[uint32]byte),
+			recipes:       make(map[uuid.UUID]uint32),
+			creativeItems: make(map[protocol.ItemType]uint32),
+			pending:       make(map[int32]*bridgedRequest),
+			changed:       make(map[windowSlot]int32),
+		}
+		b.conns[conn] = shadow
+	}
+	return shadow
+}
+
+// flow is an amount of items moving into or out of an inventory transaction.
+type flow struct {
+	// source is the action the items move from or to.
+	source protocol.InventoryAction
+	// item is the item moving.
+	item protocol.ItemStack
+	// count is the number of items that did not move yet.
+	count int
+}
+
+// request turns the inventory actions passed into an item stack request, and applies the actions to the shadow.
+// False is returned if the actions cannot be bridged.
+func (s *inventoryShadow) request(actions []protocol.InventoryAction) (protocol.ItemStackRequest, bool) {
+	// Client requests have odd IDs, so bridged requests have even IDs to never be confused with them.
+	requestID := s.requestID - 2
+	request := protocol.ItemStackRequest{RequestID: requestID}
+	bridged := &bridgedRequest{slots: make(map[[2]byte]windowSlot)}
+	touched := make(map[windowSlot]bool)
+
+	// slotInfo returns the request slot info of the window slot of an action.
+	slotInfo := func(action protocol.InventoryAction) (protocol.StackRequestSlotInfo, bool) {
+		ws := windowSlot{window: uint32(action.WindowID), slot: action.InventorySlot}
+		containerID, slot, ok := s.containerSlot(ws)
+		if !ok {
+			return protocol.StackRequestSlotInfo{}, false
+		}
+		info := protocol.StackRequestSlotInfo{ContainerID: containerID, Slot: slot, StackNetworkID: s.windows[ws].StackNetworkID}
+		if id, ok := s.changed[ws]; ok {
+			info.StackNetworkID = id
+		}
+		if touched[ws] {
+			// Slots changed earlier on in the request are referred to by the ID of the request.
+			info.StackNetworkID = requestID
+		}
+		touched[ws] = true
+		bridged.slots[[2]byte{containerID, slot}] = ws
+		return info, true
+	}
+	createdOutput := protocol.StackRequestSlotInfo{ContainerID: protocol.ContainerCreatedOutput, Slot: createdOutputSlot, StackNetworkID: requestID}
+
+	var givers, takers []*flow
+	for i, action := range actions {
+		from, to := action.OldItem.Stack, action.NewItem.Stack
+		switch action.SourceType {
+		case protocol.InventoryActionSourceContainer:
+			if swapped := swapAction(actions, i); swapped >= 0 {
+				if swapped > i {
+					source, ok := slotInfo(action)
+					destination, ok2 := slotInfo(actions[swapped])
+					if !ok || !ok2 {
+						return request, false
+					}
+					request.Actions = append(request.Actions, &protocol.SwapStackRequestAction{Source: source, Destination: destination})
+				}
+				continue
+			}
+			if sameItem(from, to) {
+				if diff := int(to.Count) - int(from.Count); diff < 0 {
+					givers = append(givers, &flow{source: action, item: from, count: -diff})
+				} else if diff > 0 {
+					takers = append(takers, &flow{source: action, item: to, count: diff})
+				}
+				continue
+			}
+			if from.NetworkID != 0 {
+				givers = append(givers, &flow{source: action, item: from, count: int(from.Count)})
+			}
+			if to.NetworkID != 0 {
+				takers = append(takers, &flow{source: action, item: to, count: int(to.Count)})
+			}
+		case protocol.InventoryActionSourceWorld, protocol.InventoryActionSourceCreative, protocol.InventoryActionSourceTODO:
+			// Items move out of virtual sources through the old item, and into them through the new item.
+			if from.NetworkID != 0 {
+				givers = append(givers, &flow{source: action, item: from, count: int(from.Count)})
+			}
+			if to.NetworkID != 0 {
+				takers = append(takers, &flow{source: action, item: to, count: int(to.Count)})
+			}
+		default:
+			return request, false
+		}
+	}
+
+	// Slots that give items must be emptied before items are put into them.
+	sort.SliceStable(takers, func(i, j int) bool {
+		return !givesItems(givers, takers[i].source) && givesItems(givers, takers[j].source)
+	})
+	var crafted bool
+	for _, taker := range takers {
+		for _, giver := range givers {
+			if taker.count == 0 {
+				break
+			}
+			if giver.count == 0 || !sameItem(giver.item, taker.item) {
+				continue
+			}
+			count := giver.count
+			if taker.count < count {
+				count = taker.count
+			}
+			giver.count, taker.count = giver.count-count, taker.count-count
+
+			giverKind, takerKind := flowKind(giver.source), flowKind(taker.source)
+			switch {
+			case giverKind == flowCreative && takerKind == flowCreative:
+			case giverKind == flowCraftingGrid && takerKind == flowCraftingIngredient:
+				// Ingredients are consumed by the server when the recipe is crafted.
+			case giverKind == flowCreative && takerKind == flowSlot, giverKind == flowCraftingResult && takerKind == flowSlot:
+				if giverKind == flowCreative {
+					id, ok := s.creativeItems[giver.item.ItemType]
+					if !ok {
+						return request, false
+					}
+					request.Actions = append(request.Actions, &protocol.CraftCreativeStackRequestAction{CreativeItemNetworkID: id})
+				} else if !crafted {
+					if !s.crafting {
+						return request, false
+					}
+					request.Actions = append(request.Actions, &protocol.CraftRecipeStackRequestAction{RecipeNetworkID: s.recipe})
+					crafted = true
+				}
+				destination, ok := slotInfo(taker.source)
+				if !ok {
+					return request, false
+				}
+				place := &protocol.PlaceStackRequestAction{}
+				place.Count, place.Source, place.Destination = byte(count), createdOutput, destination
+				request.Actions = append(request.Actions, place)
+			case (giverKind == flowSlot || giverKind == flowCraftingGrid) && takerKind == flowSlot:
+				source, ok := slotInfo(giver.source)
+				destination, ok2 := slotInfo(taker.source)
+				if !ok || !ok2 {
+					return request, false
+				}
+				place := &protocol.PlaceStackRequestAction{}
+				place.Count, place.Source, place.Destination = byte(count), source, destination
+				request.Actions = append(request.Actions, place)
+			case (giverKind == flowSlot || giverKind == flowCraftingGrid) && takerKind == flowWorld:
+				source, ok := slotInfo(giver.source)
+				if !ok {
+					return request, false
+				}
+				request.Actions = append(request.Actions, &protocol.DropStackRequestAction{Count: byte(count), Source: source})
+			case (giverKind == flowSlot || giverKind == flowCraftingGrid) && takerKind == flowCreative:
+				source, ok := slotInfo(giver.source)
+				if !ok {
+					return request, false
+				}
+				request.Actions = append(request.Actions, &protocol.DestroyStackRequestAction{Count: byte(count), Source: source})
+			default:
+				return request, false
+			}
+		}
+		if taker.count != 0 {
+			return request, false
+		}
+	}
+	for _, giver := range givers {
+		if giver.count != 0 {
+			return request, false
+		}
+	}
+	if len(request.Actions) == 0 {
+		return request, false
+	}
+
+	for _, action := range actions {
+		if action.SourceType != protocol.InventoryActionSourceContainer {
+			continue
+		}
+		ws := windowSlot{window: uint32(action.WindowID), slot: action.InventorySlot}
+		before := s.windows[ws]
+		bridged.before = append(bridged.before, slotItem{windowSlot: ws, item: before})
+		s.windows[ws] = protocol.ItemInstance{StackNetworkID: before.StackNetworkID, Stack: action.NewItem.Stack}
+		s.changed[ws] = requestID
+	}
+	if crafted {
+		s.crafting = false
+	}
+	s.requestID, s.pending[requestID] = requestID, bridged
+	return request, true
+}
+
+// resolve resolves the bridged request passed using the response of the server. The slots changed are updated with
+// the stack network IDs assigned by the server. If the server rejected the request, or the server ended up with
+// different counts than the client, the slots are returned to correct the client.
+func (s *inventoryShadow) resolve(requestID int32, request *bridgedRequest, response protocol.ItemStackResponse) (corrections []packet.Packet) {
+	for _, before := range request.before {
+		if s.changed[before.windowSlot] == requestID {
+			delete(s.changed, before.windowSlot)
+		}
+	}
+	if response.Status != protocol.ItemStackResponseStatusOK {
+		for i := len(request.before) - 1; i >= 0; i-- {
+			s.windows[request.before[i].windowSlot] = request.before[i].item
+		}
+		for _, before := range request.before {
+			corrections = append(corrections, s.slot(before.windowSlot))
+		}
+		return corrections
+	}
+	for _, container := range response.ContainerInfo {
+		for _, slotInfo := range container.SlotInfo {
+			ws, ok := request.slots[[2]byte{container.ContainerID, slotInfo.Slot}]
+			if !ok {
+				continue
+			}
+			if _, changed := s.changed[ws]; changed {
+				// The slot was changed by later requests, which are reverted to the item with this stack network ID
+				// if rejected. The slot itself is updated once the server responds to those requests.
+				for _, later := range s.pending {
+					for i, before := range later.before {
+						if before.windowSlot == ws {
+							later.before[i].item.StackNetworkID = slotInfo.StackNetworkID
+						}
+					}
+				}
+				continue
+			}
+			it := s.windows[ws]
+			it.StackNetworkID = slotInfo.StackNetworkID
+			if int(it.Stack.Count) != int(slotInfo.Count) && it.Stack.NetworkID != 0 {
+				it.Stack.Count = uint16(slotInfo.Count)
+				if slotInfo.Count == 0 {
+					it = protocol.ItemInstance{}
+				}
+				s.windows[ws] = it
+				corrections = append(corrections, s.slot(ws))
+				continue
+			}
+			s.windows[ws] = it
+		}
+	}
+	return corrections
+}
+
+// correct returns the slots changed by the inventory actions passed, as known by the server.
+func (s *inventoryShadow) correct(actions []protocol.InventoryAction) (corrections []packet.Packet) {
+	for _, action := range actions {
+		if action.SourceType == protocol.InventoryActionSourceContainer {
+			corrections = append(corrections, s.slot(windowSlot{window: uint32(action.WindowID), slot: action.InventorySlot}))
+		}
+	}
+	return corrections
+}
+
+// resync returns every slot known by the server.
+func (s *inventoryShadow) resync() (corrections []packet.Packet) {
+	slots := make([]windowSlot, 0, len(s.windows))
+	for ws := range s.windows {
+		slots = append(slots, ws)
+	}
+	sort.Slice(slots, func(i, j int) bool {
+		return slots[i].window < slots[j].window || slots[i].window == slots[j].window && slots[i].slot < slots[j].slot
+	})
+	for _, ws := range slots {
+		corrections = append(corrections, s.slot(ws))
+	}
+	return corrections
+}
+
+// slot returns an inventory slot packet holding the item in the window slot passed.
+func (s *inventoryShadow) slot(ws windowSlot) packet.Packet {
+	return &packet.InventorySlot{WindowID: ws.window, Slot: ws.slot, NewItem: s.windows[ws]}
+}
+
+// containerSlot returns the container ID and slot used in item stack requests for the window slot passed. False is
+// returned if the window slot cannot be used in item stack requests.
+func (s *inventoryShadow) containerSlot(ws windowSlot) (byte, byte, bool) {
+	slot := byte(ws.slot)
+	switch ws.window {
+	case windowIDInventory:
+		if ws.slot < 9 {
+			return protocol.ContainerHotBar, slot, true
+		}
+		return protocol.ContainerInventory, slot, ws.slot < 36
+	case windowIDOffHand:
+		return protocol.ContainerOffhand, slot, ws.slot == 0
+	case windowIDArmour:
+		return protocol.ContainerArmor, slot, ws.slot < 4
+	case windowIDUI:
+		switch {
+		case ws.slot == cursorSlot:
+			return protocol.ContainerCursor, slot, true
+		case ws.slot >= craftingGridStart && ws.slot <= craftingGridEnd:
+			return protocol.ContainerCraftingInput, slot, true
+		case ws.slot == createdOutputSlot:
+			return protocol.ContainerCreatedOutput, slot, true
+		}
+		return 0, 0, false
+	}
+	containerType, ok := s.containers[ws.window]
+	if !ok {
+		return 0, 0, false
+	}
+	switch containerType {
+	case protocol.ContainerTypeContainer:
+		return protocol.ContainerLevelEntity, slot, true
+	case protocol.ContainerTypeFurnace, protocol.ContainerTypeBlastFurnace, protocol.ContainerTypeSmoker:
+		switch ws.slot {
+		case 0:
+			switch containerType {
+			case protocol.ContainerTypeBlastFurnace:
+				return protocol.ContainerBlastFurnaceIngredient, slot, true
+			case protocol.ContainerTypeSmoker:
+				return protocol.ContainerSmokerIngredient, slot, true
+			}
+			return protocol.ContainerFurnaceIngredient, slot, true
+		case 1:
+			return protocol.ContainerFurnaceFuel, slot, true
+		case 2:
+			return protocol.ContainerFurnaceResult, slot, true
+		}
+	}
+	return 0, 0, false
+}
+
+const (
+	flowSlot = iota
+	flowCraftingGrid
+	flowWorld
+	flowCreative
+	flowCraftingResult
+	flowCraftingIngredient
+	flowUnknown
+)
+
+// flowKind returns the kind of source items move from or to in the inventory action passed.
+func flowKind(action protocol.InventoryAction) int {
+	switch action.SourceType {
+	case protocol.InventoryActionSourceContainer:
+		if action.WindowID == windowIDUI && action.InventorySlot >= craftingGridStart && action.InventorySlot <= craftingGridEnd {
+			return flowCraftingGrid
+		}
+		return flowSlot
+	case protocol.InventoryActionSourceWorld:
+		return flowWorld
+	case protocol.InventoryActionSourceCreative:
+		return flowCreative
+	case protocol.InventoryActionSourceTODO:
+		switch action.WindowID {
+		case craftingResultWindow:
+			return flowCraftingResult
+		case craftingIngredientWindow:
+			return flowCraftingIngredient
+		}
+	}
+	return flowUnknown
+}
+
+// givesItems checks if items move out of the slot of the inventory action passed.
+func givesItems(givers []*flow, action protocol.InventoryAction) bool {
+	for _, giver := range givers {
+		if giver.source.SourceType == action.SourceType && giver.source.WindowID == action.WindowID && giver.source.InventorySlot == action.InventorySlot {
+			return true
+		}
+	}
+	return false
+}
+
+// swapAction returns the index of the container action that swaps its item with the container action at the index
+// passed, or -1 if there is no such action.
+func swapAction(actions []protocol.InventoryAction, i int) int {
+	from, to := actions[i].OldItem.Stack, actions[i].NewItem.Stack
+	if from.NetworkID == 0 || to.NetworkID == 0 || sameItem(from, to) {
+		return -1
+	}
+	for j, action := range actions {
+		if j != i && action.SourceType == protocol.InventoryActionSourceContainer &&
+			sameStack(action.OldItem.Stack, to) && sameStack(action.NewItem.Stack, from) {
+			return j
+		}
+	}
+	return -1
+}
+
+// sameItem checks if the item stacks passed hold the same item, and can therefore be stacked.
+func sameItem(a, b protocol.ItemStack) bool {
+	if a.NetworkID != b.NetworkID || a.MetadataValue != b.MetadataValue {
+		return false
+	}
+	return len(a.NBTData) == 0 && len(b.NBTData) == 0 || reflect.DeepEqual(a.NBTData, b.NBTData)
+}
+
+// sameStack checks if the item stacks passed hold the same item and count.
+func sameStack(a, b protocol.ItemStack) bool {
+	return sameItem(a, b) && a.Count == b.Count
+}
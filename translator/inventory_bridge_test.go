@@ -0,0 +1,179 @@
+package translator
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sandertv/gophertunnel/minecraft"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// newTestItem creates an item instance with the network ID, count and stack network ID passed.
+func newTestItem(networkID int32, count uint16, stackNetworkID int32) protocol.ItemInstance {
+	return protocol.ItemInstance{
+		StackNetworkID: stackNetworkID,
+		Stack:          protocol.ItemStack{ItemType: protocol.ItemType{NetworkID: networkID}, Count: count},
+	}
+}
+
+// newTestMove creates the inventory transaction a legacy client sends when moving count items of the stack in the
+// inventory slot from to the empty inventory slot to.
+func newTestMove(from, to uint32, stack protocol.ItemInstance, count uint16) *packet.InventoryTransaction {
+	left, moved := stack.Stack, stack.Stack
+	left.Count, moved.Count = stack.Stack.Count-count, count
+	if left.Count == 0 {
+		left = protocol.ItemStack{}
+	}
+	return &packet.InventoryTransaction{
+		TransactionData: &protocol.NormalTransactionData{},
+		Actions: []protocol.InventoryAction{
+			{SourceType: protocol.InventoryActionSourceContainer, WindowID: windowIDInventory, InventorySlot: from, OldItem: protocol.ItemInstance{Stack: stack.Stack}, NewItem: protocol.ItemInstance{Stack: left}},
+			{SourceType: protocol.InventoryActionSourceContainer, WindowID: windowIDInventory, InventorySlot: to, NewItem: protocol.ItemInstance{Stack: moved}},
+		},
+	}
+}
+
+// newTestInventoryBridge creates an inventory bridge of which the shadow of the connection passed holds the
+// inventory with the items passed. Corrections written by the bridge are returned through the channel.
+func newTestInventoryBridge(conn *minecraft.Conn, items ...protocol.ItemInstance) (*InventoryBridge, chan []packet.Packet) {
+	b, written := NewInventoryBridge(), make(chan []packet.Packet, 1)
+	b.write = func(_ *minecraft.Conn, pks []packet.Packet) {
+		written <- pks
+	}
+	content := make([]protocol.ItemInstance, 36)
+	copy(content, items)
+	b.DowngradeInventoryPackets([]packet.Packet{&packet.InventoryContent{WindowID: windowIDInventory, Content: content}}, conn)
+	return b, written
+}
+
+// upgradeTestRequest upgrades the packet passed with the inventory bridge and returns the single item stack request it
+// was bridged to.
+func upgradeTestRequest(t *testing.T, b *InventoryBridge, conn *minecraft.Conn, pk packet.Packet) protocol.ItemStackRequest {
+	t.Helper()
+	pks := b.UpgradeInventoryPackets([]packet.Packet{pk}, conn)
+	if len(pks) != 1 {
+		t.Fatalf("expected a single packet, got %v", len(pks))
+	}
+	request, ok := pks[0].(*packet.ItemStackRequest)
+	if !ok || len(request.Requests) != 1 {
+		t.Fatalf("expected a single item stack request, got %#v", pks[0])
+	}
+	return request.Requests[0]
+}
+
+// TestInventoryBridgeMove checks that an item moved by a legacy client is bridged to a place action, and that the
+// response of the server is dropped once the new stack network IDs are known.
+func TestInventoryBridgeMove(t *testing.T) {
+	conn := &minecraft.Conn{}
+	b, written := newTestInventoryBridge(conn, newTestItem(5, 10, 3))
+	defer b.Release(conn)
+
+	request := upgradeTestRequest(t, b, conn, newTestMove(0, 12, newTestItem(5, 10, 3), 6))
+	place := &protocol.PlaceStackRequestAction{}
+	place.Count = 6
+	place.Source = protocol.StackRequestSlotInfo{ContainerID: protocol.ContainerHotBar, Slot: 0, StackNetworkID: 3}
+	place.Destination = protocol.StackRequestSlotInfo{ContainerID: protocol.ContainerInventory, Slot: 12}
+	if expected := []protocol.StackRequestAction{place}; !reflect.DeepEqual(request.Actions, expected) {
+		t.Fatalf("expected actions %#v, got %#v", expected, request.Actions)
+	}
+
+	pks := b.DowngradeInventoryPackets([]packet.Packet{&packet.ItemStackResponse{Responses: []protocol.ItemStackResponse{{
+		Status:    protocol.ItemStackResponseStatusOK,
+		RequestID: request.RequestID,
+		ContainerInfo: []protocol.StackResponseContainerInfo{
+			{ContainerID: protocol.ContainerHotBar, SlotInfo: []protocol.StackResponseSlotInfo{{Slot: 0, Count: 4, StackNetworkID: 3}}},
+			{ContainerID: protocol.ContainerInventory, SlotInfo: []protocol.StackResponseSlotInfo{{Slot: 12, Count: 6, StackNetworkID: 4}}},
+		},
+	}}}}, conn)
+	if len(pks) != 0 {
+		t.Fatalf("expected the response to be dropped, got %v packets", len(pks))
+	}
+
+	// The slot the items moved to is referred to by the stack network ID assigned by the server.
+	request = upgradeTestRequest(t, b, conn, newTestMove(12, 20, newTestItem(5, 6, 4), 6))
+	if source := request.Actions[0].(*protocol.PlaceStackRequestAction).Source; source.StackNetworkID != 4 {
+		t.Fatalf("expected source stack network ID 4, got %v", source.StackNetworkID)
+	}
+	select {
+	case pks := <-written:
+		t.Fatalf("expected no corrections, got %v packets", len(pks))
+	default:
+	}
+}
+
+// TestInventoryBridgeRejected checks that the slots changed by a bridged request are reverted client-side if the
+// server rejects the request.
+func TestInventoryBridgeRejected(t *testing.T) {
+	conn := &minecraft.Conn{}
+	b, _ := newTestInventoryBridge(conn, newTestItem(5, 10, 3))
+	defer b.Release(conn)
+
+	request := upgradeTestRequest(t, b, conn, newTestMove(0, 12, newTestItem(5, 10, 3), 10))
+	pks := b.DowngradeInventoryPackets([]packet.Packet{&packet.ItemStackResponse{Responses: []protocol.ItemStackResponse{{
+		Status:    protocol.ItemStackResponseStatusError,
+		RequestID: request.RequestID,
+	}}}}, conn)
+
+	expected := []packet.Packet{
+		&packet.InventorySlot{WindowID: windowIDInventory, Slot: 0, NewItem: newTestItem(5, 10, 3)},
+		&packet.InventorySlot{WindowID: windowIDInventory, Slot: 12},
+	}
+	if !reflect.DeepEqual(pks, expected) {
+		t.Fatalf("expected corrections %#v, got %#v", expected, pks)
+	}
+}
+
+// TestInventoryBridgeCorrection checks that transactions that cannot be bridged are dropped, and that the slots they
+// changed are written to the connection as known by the server.
+func TestInventoryBridgeCorrection(t *testing.T) {
+	conn := &minecraft.Conn{}
+	b, written := newTestInventoryBridge(conn, newTestItem(5, 10, 3))
+	defer b.Release(conn)
+
+	// The client moves the items into a window that was never opened by the server.
+	pk := newTestMove(0, 12, newTestItem(5, 10, 3), 10)
+	pk.Actions[1].WindowID = 5
+	if pks := b.UpgradeInventoryPackets([]packet.Packet{pk}, conn); len(pks) != 0 {
+		t.Fatalf("expected the transaction to be dropped, got %v packets", len(pks))
+	}
+
+	expected := []packet.Packet{
+		&packet.InventorySlot{WindowID: windowIDInventory, Slot: 0, NewItem: newTestItem(5, 10, 3)},
+		&packet.InventorySlot{WindowID: 5, Slot: 12},
+	}
+	select {
+	case pks := <-written:
+		if !reflect.DeepEqual(pks, expected) {
+			t.Fatalf("expected corrections %#v, got %#v", expected, pks)
+		}
+	default:
+		t.Fatalf("expected corrections to be written")
+	}
+}
+
+// TestInventoryBridgeRequestIDs checks that bridged requests get negative even IDs, that slots changed by pending
+// requests are referred to by the request ID, and that responses to other requests are left untouched.
+func TestInventoryBridgeRequestIDs(t *testing.T) {
+	conn := &minecraft.Conn{}
+	b, _ := newTestInventoryBridge(conn, newTestItem(5, 10, 3))
+	defer b.Release(conn)
+
+	first := upgradeTestRequest(t, b, conn, newTestMove(0, 12, newTestItem(5, 10, 3), 10))
+	second := upgradeTestRequest(t, b, conn, newTestMove(12, 20, newTestItem(5, 10, 0), 10))
+	if first.RequestID != -2 || second.RequestID != -4 {
+		t.Fatalf("expected request IDs -2 and -4, got %v and %v", first.RequestID, second.RequestID)
+	}
+	if source := second.Actions[0].(*protocol.PlaceStackRequestAction).Source; source.StackNetworkID != first.RequestID {
+		t.Fatalf("expected the slot changed by the first request to be referred to by its ID, got %v", source.StackNetworkID)
+	}
+
+	other := protocol.ItemStackResponse{Status: protocol.ItemStackResponseStatusOK, RequestID: 1}
+	pks := b.DowngradeInventoryPackets([]packet.Packet{&packet.ItemStackResponse{Responses: []protocol.ItemStackResponse{
+		{Status: protocol.ItemStackResponseStatusOK, RequestID: first.RequestID},
+		other,
+	}}}, conn)
+	if len(pks) != 1 || !reflect.DeepEqual(pks[0].(*packet.ItemStackResponse).Responses, []protocol.ItemStackResponse{other}) {
+		t.Fatalf("expected only the response to the other request to be kept, got %#v", pks)
+	}
+}
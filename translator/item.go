@@ -25,6 +25,8 @@ type ItemTranslator interface {
 	DowngradeItemDescriptor(input protocol.ItemDescriptor) protocol.ItemDescriptor
 	// DowngradeItemDescriptorCount downgrades the input item descriptor (with count) to a legacy item descriptor (with count).
 	DowngradeItemDescriptorCount(input protocol.ItemDescriptorCount) protocol.ItemDescriptorCount
+	// DowngradeItemData downgrades the input item encoded as NBT, as held by block actors, to a legacy item.
+	DowngradeItemData(input map[string]any) map[string]any
 	DowngradeItemPackets(pks []packet.Packet, conn *minecraft.Conn) []packet.Packet
 	// UpgradeItemType upgrades the input item type to the latest item type.
 	UpgradeItemType(input protocol.ItemType) protocol.ItemType
@@ -36,11 +38,19 @@ type ItemTranslator interface {
 	UpgradeItemDescriptor(input protocol.ItemDescriptor) protocol.ItemDescriptor
 	// UpgradeItemDescriptorCount upgrades the input item descriptor (with count) to the latest item descriptor (with count).
 	UpgradeItemDescriptorCount(input protocol.ItemDescriptorCount) protocol.ItemDescriptorCount
+	// UpgradeItemData upgrades the input item encoded as NBT, as held by block actors, to the latest item.
+	UpgradeItemData(input map[string]any) map[string]any
 	UpgradeItemPackets(pks []packet.Packet, conn *minecraft.Conn) []packet.Packet
 	// Register registers a custom item entry.
 	Register(item world.CustomItem, replacement string)
 	// CustomItems lists all custom items used as substitutes, with the runtime id as the key
 	CustomItems() map[int32]world.CustomItem
+	// RegisterStandIns registers a custom item for every latest item that does not exist in the legacy version, using
+	// the textures found in the directory passed.
+	RegisterStandIns(textureDir string)
+	// Overlay returns a copy of the translator for a single connection, which registers entries on overlays of the
+	// item mappings and uses the block mappings passed.
+	Overlay(blockMapping, blockMappingLatest mapping.Block) ItemTranslator
 }
 
 type DefaultItemTranslator struct {
@@ -51,6 +61,10 @@ type DefaultItemTranslator struct {
 	ridToCustomItem    map[int32]world.CustomItem
 	originalToCustom   map[int32]int32
 	customToOriginal   map[int32]int32
+	fallback           *mapping.ItemFallback
+	nbtRules           ItemNBTRules
+	tags               *mapping.ItemTags
+	variants           *recipeVariants
 }
 
 func NewItemTranslator(mapping mapping.Item, latestMapping mapping.Item, blockMapping mapping.Block, blockMappingLatest mapping.Block) *DefaultItemTranslator {
@@ -58,14 +72,39 @@ func NewItemTranslator(mapping mapping.Item, latestMapping mapping.Item, blockMa
 		ridToCustomItem: make(map[int32]world.CustomItem), originalToCustom: make(map[int32]int32), customToOriginal: make(map[int32]int32)}
 }
 
+// WithItemFallback sets the substitutes used for items that do not exist in the legacy version. Without substitutes,
+// such items are replaced with 'minecraft:info_update'.
+func (t *DefaultItemTranslator) WithItemFallback(fallback *mapping.ItemFallback) *DefaultItemTranslator {
+	t.fallback = fallback
+	return t
+}
+
+func (t *DefaultItemTranslator) Overlay(blockMapping, blockMappingLatest mapping.Block) ItemTranslator {
+	overlay := *t
+	overlay.mapping, overlay.latest = mapping.NewItemOverlay(t.mapping), mapping.NewItemOverlay(t.latest)
+	overlay.blockMapping, overlay.blockMappingLatest = blockMapping, blockMappingLatest
+	if t.variants != nil {
+		overlay.variants = newRecipeVariants()
+	}
+	return &overlay
+}
+
 func (t *DefaultItemTranslator) DowngradeItemType(input protocol.ItemType) protocol.ItemType {
+	itemType, _ := t.downgradeItemType(input)
+	return itemType
+}
+
+// downgradeItemType downgrades the input item type to a legacy item type. If the item is replaced with a stand-in,
+// the display name of the stand-in is returned too.
+func (t *DefaultItemTranslator) downgradeItemType(input protocol.ItemType) (protocol.ItemType, string) {
 	if input.NetworkID == t.latest.Air() || input.NetworkID == 0 {
 		return protocol.ItemType{
 			NetworkID: t.mapping.Air(),
-		}
+		}, ""
 	}
 	networkID := input.NetworkID
 	metadata := input.MetadataValue
+	var displayName string
 
 	var ok bool
 	if networkID, ok = t.originalToCustom[input.NetworkID]; !ok {
@@ -78,6 +117,13 @@ func (t *DefaultItemTranslator) DowngradeItemType(input protocol.ItemType) proto
 		metadata = i.Metadata
 
 		networkID, ok = t.mapping.ItemNameToRuntimeID(i.Name)
+		if !ok && t.fallback != nil {
+			if substitute, substituteMetadata, found := t.fallback.Downgrade(name, input.MetadataValue); found {
+				if networkID, ok = t.mapping.ItemNameToRuntimeID(substitute.Name); ok {
+					metadata, displayName = substituteMetadata, substitute.DisplayName
+				}
+			}
+		}
 		if !ok {
 			networkID, _ = t.mapping.ItemNameToRuntimeID("minecraft:info_update")
 		}
@@ -86,11 +132,18 @@ func (t *DefaultItemTranslator) DowngradeItemType(input protocol.ItemType) proto
 	return protocol.ItemType{
 		NetworkID:     networkID,
 		MetadataValue: metadata,
-	}
+	}, displayName
 }
 
 func (t *DefaultItemTranslator) DowngradeItemStack(input protocol.ItemStack) protocol.ItemStack {
-	input.ItemType = t.DowngradeItemType(input.ItemType)
+	original := input.ItemType
+	input.NBTData, input.CanBePlacedOn, input.CanBreak = t.downgradeNBT(input.NBTData, input.CanBePlacedOn, input.CanBreak)
+	var displayName string
+	input.ItemType, displayName = t.downgradeItemType(input.ItemType)
+	if displayName != "" {
+		name, _ := t.latest.ItemRuntimeIDToName(original.NetworkID)
+		input.NBTData = markStandIn(input.NBTData, name, original.MetadataValue, displayName)
+	}
 
 	blockRuntimeId := uint32(0)
 	if input.NetworkID != t.mapping.Air() {
@@ -167,6 +220,26 @@ func (t *DefaultItemTranslator) DowngradeItemDescriptorCount(input protocol.Item
 	return input
 }
 
+func (t *DefaultItemTranslator) DowngradeItemData(input map[string]any) map[string]any {
+	name, _ := input["Name"].(string)
+	networkID, ok := t.latest.ItemNameToRuntimeID(name)
+	if !ok {
+		return input
+	}
+	replaceItemDataNBT(input, t.downgradeNBT)
+	damage, _ := input["Damage"].(int16)
+	itemType, displayName := t.downgradeItemType(protocol.ItemType{NetworkID: networkID, MetadataValue: uint32(damage)})
+	if displayName != "" {
+		tag, _ := input["tag"].(map[string]any)
+		input["tag"] = markStandIn(tag, name, uint32(damage), displayName)
+	}
+	input["Name"], _ = t.mapping.ItemRuntimeIDToName(itemType.NetworkID)
+	input["Damage"] = int16(itemType.MetadataValue)
+	// The block state of block items is derived from the name and damage of the item instead.
+	delete(input, "Block")
+	return input
+}
+
 func (t *DefaultItemTranslator) UpgradeItemType(input protocol.ItemType) protocol.ItemType {
 	if input.NetworkID == t.mapping.Air() || input.NetworkID == 0 {
 		return protocol.ItemType{
@@ -185,6 +258,13 @@ func (t *DefaultItemTranslator) UpgradeItemType(input protocol.ItemType) protoco
 			Version:  t.mapping.ItemVersion(),
 		}, t.latest.ItemVersion())
 		networkID, ok = t.latest.ItemNameToRuntimeID(i.Name)
+		if !ok && t.fallback != nil {
+			if latestName, latestMetadata, found := t.fallback.Upgrade(name, input.MetadataValue); found {
+				if networkID, ok = t.latest.ItemNameToRuntimeID(latestName); ok {
+					metadata = latestMetadata
+				}
+			}
+		}
 		if !ok {
 			networkID, _ = t.latest.ItemNameToRuntimeID("minecraft:info_update")
 		}
@@ -197,7 +277,12 @@ func (t *DefaultItemTranslator) UpgradeItemType(input protocol.ItemType) protoco
 }
 
 func (t *DefaultItemTranslator) UpgradeItemStack(input protocol.ItemStack) protocol.ItemStack {
-	input.ItemType = t.UpgradeItemType(input.ItemType)
+	if itemType, nbt, ok := t.restoreStandIn(input.NBTData); ok {
+		input.ItemType, input.NBTData = itemType, nbt
+	} else {
+		input.ItemType = t.UpgradeItemType(input.ItemType)
+	}
+	input.NBTData, input.CanBePlacedOn, input.CanBreak = t.upgradeNBT(input.NBTData, input.CanBePlacedOn, input.CanBreak)
 
 	blockRuntimeId := uint32(0)
 	if input.NetworkID != t.latest.Air() {
@@ -267,6 +352,34 @@ func (t *DefaultItemTranslator) UpgradeItemDescriptorCount(input protocol.ItemDe
 	return input
 }
 
+func (t *DefaultItemTranslator) UpgradeItemData(input map[string]any) map[string]any {
+	var itemType protocol.ItemType
+	if tag, ok := input["tag"].(map[string]any); ok {
+		if original, nbt, ok := t.restoreStandIn(tag); ok {
+			itemType = original
+			if nbt == nil {
+				delete(input, "tag")
+			} else {
+				input["tag"] = nbt
+			}
+		}
+	}
+	if itemType.NetworkID == 0 {
+		name, _ := input["Name"].(string)
+		networkID, ok := t.mapping.ItemNameToRuntimeID(name)
+		if !ok {
+			return input
+		}
+		damage, _ := input["Damage"].(int16)
+		itemType = t.UpgradeItemType(protocol.ItemType{NetworkID: networkID, MetadataValue: uint32(damage)})
+	}
+	replaceItemDataNBT(input, t.upgradeNBT)
+	input["Name"], _ = t.latest.ItemRuntimeIDToName(itemType.NetworkID)
+	input["Damage"] = int16(itemType.MetadataValue)
+	delete(input, "Block")
+	return input
+}
+
 func (t *DefaultItemTranslator) DowngradeItemPackets(pks []packet.Packet, _ *minecraft.Conn) (result []packet.Packet) {
 	for _, pk := range pks {
 		switch pk := pk.(type) {
@@ -299,6 +412,9 @@ func (t *DefaultItemTranslator) DowngradeItemPackets(pks []packet.Packet, _ *min
 				}
 			}
 		case *packet.CraftingData:
+			if t.tags != nil {
+				pk.Recipes = t.expandRecipes(pk.Recipes, pk.ClearRecipes)
+			}
 			for i, recipe := range pk.Recipes {
 				switch recipe := recipe.(type) {
 				case *protocol.ShapelessRecipe:
@@ -388,6 +504,8 @@ func (t *DefaultItemTranslator) DowngradeItemPackets(pks []packet.Packet, _ *min
 				}
 				pk.MaterialReducers[i] = recipe
 			}
+		case *packet.UnlockedRecipes:
+			pk.Recipes = t.expandRecipeIDs(pk.Recipes)
 		case *packet.CraftingEvent:
 			pk.Input = lo.Map(pk.Input, func(item protocol.ItemInstance, _ int) protocol.ItemInstance {
 				return t.DowngradeItemInstance(item)
@@ -444,14 +562,18 @@ func (t *DefaultItemTranslator) DowngradeItemPackets(pks []packet.Packet, _ *min
 				pk.EventData = (itemType.NetworkID << 16) | int32(itemType.MetadataValue)
 			}
 		case *packet.StartGame:
-			for i, entry := range pk.Items {
+			items := make([]protocol.ItemEntry, 0, len(pk.Items))
+			for _, entry := range pk.Items {
 				if !entry.ComponentBased {
+					if _, ok := t.originalToCustom[int32(entry.RuntimeID)]; ok {
+						// The custom item is added below.
+						continue
+					}
 					itemType := t.DowngradeItemType(protocol.ItemType{
 						NetworkID:     int32(entry.RuntimeID),
 						MetadataValue: 0,
 					})
 					if itemType.NetworkID == t.mapping.Air() {
-						removeIndex(pk.Items, i)
 						continue
 					}
 					entry.RuntimeID = int16(itemType.NetworkID)
@@ -464,8 +586,9 @@ func (t *DefaultItemTranslator) DowngradeItemPackets(pks []packet.Packet, _ *min
 					t.latest.RegisterEntry(entry.Name)
 					entry.RuntimeID = int16(t.mapping.RegisterEntry(entry.Name))
 				}
-				pk.Items[i] = entry
+				items = append(items, entry)
 			}
+			pk.Items = items
 			for rid, i := range t.CustomItems() {
 				name, _ := i.EncodeItem()
 				pk.Items = append(pk.Items, protocol.ItemEntry{
@@ -511,6 +634,7 @@ func (t *DefaultItemTranslator) UpgradeItemPackets(pks []packet.Packet, _ *minec
 		case *packet.ItemStackRequest:
 			for i, request := range pk.Requests {
 				for i2, action := range request.Actions {
+					t.upgradeRecipeAction(action)
 					if act, ok := action.(*protocol.CraftResultsDeprecatedStackRequestAction); ok {
 						act.ResultItems = lo.Map(act.ResultItems, func(item protocol.ItemStack, _ int) protocol.ItemStack {
 							return t.UpgradeItemStack(item)
@@ -610,6 +734,7 @@ func (t *DefaultItemTranslator) UpgradeItemPackets(pks []packet.Packet, _ *minec
 				pk.MaterialReducers[i] = recipe
 			}
 		case *packet.CraftingEvent:
+			pk.RecipeUUID = t.upgradeRecipeUUID(pk.RecipeUUID)
 			pk.Input = lo.Map(pk.Input, func(item protocol.ItemInstance, _ int) protocol.ItemInstance {
 				return t.UpgradeItemInstance(item)
 			})
@@ -618,6 +743,7 @@ func (t *DefaultItemTranslator) UpgradeItemPackets(pks []packet.Packet, _ *minec
 			})
 		case *packet.PlayerAuthInput:
 			for i, action := range pk.ItemStackRequest.Actions {
+				t.upgradeRecipeAction(action)
 				if act, ok := action.(*protocol.CraftResultsDeprecatedStackRequestAction); ok {
 					act.ResultItems = lo.Map(act.ResultItems, func(item protocol.ItemStack, _ int) protocol.ItemStack {
 						return t.UpgradeItemStack(item)
@@ -724,8 +850,69 @@ func (t *DefaultItemTranslator) CustomItems() map[int32]world.CustomItem {
 	return t.ridToCustomItem
 }
 
-func removeIndex[T any](s []T, index int) []T {
-	ret := make([]T, 0)
-	ret = append(ret, s[:index]...)
-	return append(ret, s[index+1:]...)
+// standInTag is the NBT tag added to stand-ins of items that do not exist in the legacy version, holding the
+// original item so that it can be restored once the legacy client sends the item back.
+const standInTag = "multiversion:original"
+
+// markStandIn returns a copy of the NBT data passed with the original item stored in it and, if the item has no
+// custom name yet, the display name of the stand-in added as custom name.
+func markStandIn(nbt map[string]any, name string, metadata uint32, displayName string) map[string]any {
+	newNBT := make(map[string]any, len(nbt)+2)
+	for k, v := range nbt {
+		newNBT[k] = v
+	}
+	display := make(map[string]any)
+	if d, ok := nbt["display"].(map[string]any); ok {
+		for k, v := range d {
+			display[k] = v
+		}
+	}
+
+	original := map[string]any{"Name": name, "Damage": int32(metadata)}
+	if _, ok := display["Name"]; !ok {
+		display["Name"] = displayName
+		newNBT["display"] = display
+		original["DisplayName"] = displayName
+	}
+	newNBT[standInTag] = original
+	return newNBT
+}
+
+// restoreStandIn restores the original item of a stand-in from the NBT data passed. The NBT data returned has the
+// tags added by markStandIn removed again. False is returned if the NBT data is not of a stand-in.
+func (t *DefaultItemTranslator) restoreStandIn(nbt map[string]any) (protocol.ItemType, map[string]any, bool) {
+	original, ok := nbt[standInTag].(map[string]any)
+	if !ok {
+		return protocol.ItemType{}, nil, false
+	}
+	name, _ := original["Name"].(string)
+	metadata, _ := original["Damage"].(int32)
+	networkID, ok := t.latest.ItemNameToRuntimeID(name)
+	if !ok {
+		return protocol.ItemType{}, nil, false
+	}
+
+	newNBT := make(map[string]any, len(nbt))
+	for k, v := range nbt {
+		newNBT[k] = v
+	}
+	delete(newNBT, standInTag)
+	if displayName, ok := original["DisplayName"].(string); ok {
+		if display, ok := nbt["display"].(map[string]any); ok && display["Name"] == displayName {
+			newDisplay := make(map[string]any, len(display))
+			for k, v := range display {
+				newDisplay[k] = v
+			}
+			delete(newDisplay, "Name")
+			if len(newDisplay) == 0 {
+				delete(newNBT, "display")
+			} else {
+				newNBT["display"] = newDisplay
+			}
+		}
+	}
+	if len(newNBT) == 0 {
+		newNBT = nil
+	}
+	return protocol.ItemType{NetworkID: networkID, MetadataValue: uint32(metadata)}, newNBT, true
 }
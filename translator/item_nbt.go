@@ -0,0 +1,284 @@
+package translator
+
+import "strings"
+
+// originalNBTTag is the NBT tag added to items whose NBT data was rewritten for the legacy version, holding the
+// original values of the rewritten tags so that they can be restored once the legacy client sends the item back.
+const originalNBTTag = "multiversion:nbt"
+
+// ItemNBTRules holds the rules used to rewrite the NBT data of items for a legacy version.
+type ItemNBTRules struct {
+	// Enchantments holds the IDs of the enchantments in the legacy version, indexed by their IDs in the latest
+	// version. Enchantments without an ID in the legacy version are dropped, but the item keeps its glint. If nil,
+	// enchantments are left untouched.
+	Enchantments map[int16]int16
+	// StripTrims removes the armour trims of items, for versions that do not support them.
+	StripTrims bool
+	// TextColours holds the colour codes of the legacy version, indexed by the colour codes of the latest version that
+	// do not exist in the legacy version. They are replaced in the display name and lore of items, and in the title and
+	// pages of books. If nil, text is left untouched.
+	TextColours map[rune]rune
+}
+
+// WithItemNBTRules sets the rules used to rewrite the NBT data of items. Without rules, only the block names held by
+// items are rewritten.
+func (t *DefaultItemTranslator) WithItemNBTRules(rules ItemNBTRules) *DefaultItemTranslator {
+	t.nbtRules = rules
+	return t
+}
+
+// downgradeNBT rewrites the NBT data of an item and the names of the blocks it can be placed on and can destroy for
+// the legacy version. The NBT data passed is never modified.
+func (t *DefaultItemTranslator) downgradeNBT(nbt map[string]any, canPlaceOn, canDestroy []string) (map[string]any, []string, []string) {
+	original := make(map[string]any)
+	newNBT := make(map[string]any, len(nbt)+1)
+	for k, v := range nbt {
+		newNBT[k] = v
+	}
+
+	if enchantments, ok := nbt["ench"]; ok && t.nbtRules.Enchantments != nil {
+		if downgraded, changed := t.downgradeEnchantments(enchantments); changed {
+			original["ench"], newNBT["ench"] = enchantments, downgraded
+		}
+	}
+	if trim, ok := nbt["Trim"]; ok && t.nbtRules.StripTrims {
+		original["Trim"] = trim
+		delete(newNBT, "Trim")
+	}
+	if t.nbtRules.TextColours != nil {
+		if display, ok := nbt["display"].(map[string]any); ok {
+			if downgraded, changed := t.downgradeDisplay(display); changed {
+				original["display"], newNBT["display"] = display, downgraded
+			}
+		}
+		if title, ok := nbt["title"].(string); ok {
+			if downgraded, changed := t.downgradeText(title); changed {
+				original["title"], newNBT["title"] = title, downgraded
+			}
+		}
+		if pages, ok := nbt["pages"].([]any); ok {
+			if downgraded, changed := t.downgradePages(pages); changed {
+				original["pages"], newNBT["pages"] = pages, downgraded
+			}
+		}
+	}
+	if names, changed := t.downgradeBlockNames(canPlaceOn); changed {
+		original["CanPlaceOn"], canPlaceOn = stringsToList(canPlaceOn), names
+	}
+	if names, changed := t.downgradeBlockNames(canDestroy); changed {
+		original["CanDestroy"], canDestroy = stringsToList(canDestroy), names
+	}
+
+	if len(original) == 0 {
+		return nbt, canPlaceOn, canDestroy
+	}
+	newNBT[originalNBTTag] = original
+	return newNBT, canPlaceOn, canDestroy
+}
+
+// upgradeNBT restores the NBT data of an item and the names of the blocks it can be placed on and can destroy from
+// the NBT data rewritten by downgradeNBT. The NBT data passed is never modified.
+func (t *DefaultItemTranslator) upgradeNBT(nbt map[string]any, canPlaceOn, canDestroy []string) (map[string]any, []string, []string) {
+	original, ok := nbt[originalNBTTag].(map[string]any)
+	if !ok {
+		return nbt, canPlaceOn, canDestroy
+	}
+	newNBT := make(map[string]any, len(nbt))
+	for k, v := range nbt {
+		newNBT[k] = v
+	}
+	delete(newNBT, originalNBTTag)
+
+	for k, v := range original {
+		switch k {
+		case "CanPlaceOn":
+			canPlaceOn = listToStrings(v)
+		case "CanDestroy":
+			canDestroy = listToStrings(v)
+		default:
+			newNBT[k] = v
+		}
+	}
+	if len(newNBT) == 0 {
+		newNBT = nil
+	}
+	return newNBT, canPlaceOn, canDestroy
+}
+
+// replaceItemDataNBT replaces the tag of an item encoded as NBT and the names of the blocks it can be placed on and
+// can destroy using the function passed.
+func replaceItemDataNBT(input map[string]any, f func(map[string]any, []string, []string) (map[string]any, []string, []string)) {
+	tag, _ := input["tag"].(map[string]any)
+	tag, canPlaceOn, canDestroy := f(tag, listToStrings(input["CanPlaceOn"]), listToStrings(input["CanDestroy"]))
+	for k, v := range map[string][]string{"CanPlaceOn": canPlaceOn, "CanDestroy": canDestroy} {
+		if len(v) > 0 {
+			input[k] = stringsToList(v)
+		} else {
+			delete(input, k)
+		}
+	}
+	if tag != nil {
+		input["tag"] = tag
+	} else {
+		delete(input, "tag")
+	}
+}
+
+// downgradeEnchantments translates the IDs of the enchantments passed to those of the legacy version, dropping the
+// enchantments that do not exist in the legacy version. False is returned if no enchantment changed.
+func (t *DefaultItemTranslator) downgradeEnchantments(v any) ([]any, bool) {
+	var enchantments []map[string]any
+	switch v := v.(type) {
+	case []any:
+		for _, e := range v {
+			if e, ok := e.(map[string]any); ok {
+				enchantments = append(enchantments, e)
+			}
+		}
+	case []map[string]any:
+		enchantments = v
+	}
+
+	changed := false
+	// An empty list is kept rather than removed, so that the item keeps its glint.
+	downgraded := make([]any, 0, len(enchantments))
+	for _, e := range enchantments {
+		id, _ := e["id"].(int16)
+		legacyID, ok := t.nbtRules.Enchantments[id]
+		if !ok {
+			changed = true
+			continue
+		}
+		if legacyID != id {
+			changed = true
+			e = map[string]any{"id": legacyID, "lvl": e["lvl"]}
+		}
+		downgraded = append(downgraded, e)
+	}
+	return downgraded, changed
+}
+
+// downgradeDisplay replaces the colour codes in the display name and lore passed that do not exist in the legacy
+// version. False is returned if no text changed.
+func (t *DefaultItemTranslator) downgradeDisplay(display map[string]any) (map[string]any, bool) {
+	changed := false
+	downgraded := make(map[string]any, len(display))
+	for k, v := range display {
+		downgraded[k] = v
+	}
+	if name, ok := display["Name"].(string); ok {
+		if name, ok := t.downgradeText(name); ok {
+			changed, downgraded["Name"] = true, name
+		}
+	}
+	if lore, ok := display["Lore"].([]any); ok {
+		lines := make([]any, len(lore))
+		for i, line := range lore {
+			lines[i] = line
+			if line, ok := line.(string); ok {
+				if line, ok := t.downgradeText(line); ok {
+					changed, lines[i] = true, line
+				}
+			}
+		}
+		downgraded["Lore"] = lines
+	}
+	return downgraded, changed
+}
+
+// downgradePages replaces the colour codes in the text of the book pages passed that do not exist in the legacy
+// version. The other fields of pages, such as their photo, are kept. False is returned if no text changed.
+func (t *DefaultItemTranslator) downgradePages(pages []any) ([]any, bool) {
+	changed := false
+	downgraded := make([]any, len(pages))
+	for i, page := range pages {
+		downgraded[i] = page
+		page, ok := page.(map[string]any)
+		if !ok {
+			continue
+		}
+		text, ok := page["text"].(string)
+		if !ok {
+			continue
+		}
+		if text, ok := t.downgradeText(text); ok {
+			newPage := make(map[string]any, len(page))
+			for k, v := range page {
+				newPage[k] = v
+			}
+			newPage["text"] = text
+			changed, downgraded[i] = true, newPage
+		}
+	}
+	return downgraded, changed
+}
+
+// downgradeText replaces the colour codes in the text passed that do not exist in the legacy version. False is
+// returned if the text did not change.
+func (t *DefaultItemTranslator) downgradeText(text string) (string, bool) {
+	if !strings.ContainsRune(text, '§') {
+		return text, false
+	}
+	changed, code := false, false
+	downgraded := []rune(text)
+	for i, r := range downgraded {
+		if code {
+			if legacy, ok := t.nbtRules.TextColours[r]; ok {
+				changed, downgraded[i] = true, legacy
+			}
+		}
+		code = r == '§' && !code
+	}
+	return string(downgraded), changed
+}
+
+// downgradeBlockNames translates the block names passed to those of the legacy version, dropping the blocks that do
+// not exist in the legacy version. False is returned if no name changed.
+func (t *DefaultItemTranslator) downgradeBlockNames(names []string) ([]string, bool) {
+	changed := false
+	downgraded := make([]string, 0, len(names))
+	for _, name := range names {
+		legacyName, ok := t.downgradeBlockName(name)
+		if !ok {
+			changed = true
+			continue
+		}
+		if legacyName != name {
+			changed = true
+		}
+		downgraded = append(downgraded, legacyName)
+	}
+	return downgraded, changed
+}
+
+// downgradeBlockName finds the name of the block in the legacy version with the name passed in the latest version.
+// Blocks of the legacy mapping are looked up by their upgraded names, so the state found holds the legacy name.
+func (t *DefaultItemTranslator) downgradeBlockName(name string) (string, bool) {
+	rid, ok := t.blockMapping.MatchState(name, nil, nil)
+	if !ok {
+		return "", false
+	}
+	state, ok := t.blockMapping.RuntimeIDToState(rid)
+	return state.Name, ok
+}
+
+// stringsToList converts the strings passed to a list that can be encoded as NBT.
+func stringsToList(s []string) []any {
+	list := make([]any, len(s))
+	for i, v := range s {
+		list[i] = v
+	}
+	return list
+}
+
+// listToStrings converts a list of strings decoded from NBT to strings.
+func listToStrings(v any) []string {
+	list, _ := v.([]any)
+	s := make([]string, 0, len(list))
+	for _, v := range list {
+		if v, ok := v.(string); ok {
+			s = append(s, v)
+		}
+	}
+	return s
+}
@@ -0,0 +1,38 @@
+package translator
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/flonja/multiversion/protocols/latest"
+)
+
+// TestItemNBTTextColours checks that colour codes unknown to the legacy version are replaced in the display and pages
+// of books, and that the original text is restored when upgrading.
+func TestItemNBTTextColours(t *testing.T) {
+	tr := NewItemTranslator(latest.NewItemMapping(), latest.NewItemMapping(), latest.NewBlockMapping(), latest.NewBlockMapping()).
+		WithItemNBTRules(ItemNBTRules{TextColours: map[rune]rune{'m': '4', 'u': '5'}})
+	input := map[string]any{
+		"display": map[string]any{"Name": "§mRed§r book", "Lore": []any{"§§u", "§aplain"}},
+		"title":   "§uTitle",
+		"author":  "§mAuthor",
+		"pages":   []any{map[string]any{"text": "§m§lpage", "photoname": ""}, map[string]any{"text": "page", "photoname": ""}},
+	}
+	expected := map[string]any{
+		"display": map[string]any{"Name": "§4Red§r book", "Lore": []any{"§§u", "§aplain"}},
+		"title":   "§5Title",
+		"author":  "§mAuthor",
+		"pages":   []any{map[string]any{"text": "§4§lpage", "photoname": ""}, map[string]any{"text": "page", "photoname": ""}},
+	}
+
+	downgraded, _, _ := tr.downgradeNBT(input, nil, nil)
+	original := downgraded[originalNBTTag]
+	delete(downgraded, originalNBTTag)
+	if !reflect.DeepEqual(downgraded, expected) {
+		t.Fatalf("downgrade: expected %v, got %v", expected, downgraded)
+	}
+	downgraded[originalNBTTag] = original
+	if upgraded, _, _ := tr.upgradeNBT(downgraded, nil, nil); !reflect.DeepEqual(upgraded, input) {
+		t.Fatalf("upgrade: expected %v, got %v", input, upgraded)
+	}
+}
@@ -0,0 +1,287 @@
+package translator
+
+import (
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/flonja/multiversion/mapping"
+	"github.com/google/uuid"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+)
+
+const (
+	// anyMetadata is the metadata of item descriptors that match an item with any metadata.
+	anyMetadata = math.MaxInt16
+	// maxRecipeVariants is the maximum amount of legacy recipes a single recipe using item tags is expanded into.
+	maxRecipeVariants = 64
+)
+
+// recipeVariants holds the recipes of a connection that were expanded into multiple legacy recipes, because they use
+// item tags. The first variant of a recipe keeps its ID, network ID and UUID, the others get new ones.
+type recipeVariants struct {
+	mu sync.Mutex
+	// originals holds the network ID of the recipe every variant was expanded from, indexed by the network ID of the
+	// variant.
+	originals map[uint32]uint32
+	// uuids holds the UUID of the recipe every variant was expanded from, indexed by the UUID of the variant.
+	uuids map[uuid.UUID]uuid.UUID
+	// names holds the IDs of the variants of every recipe, indexed by the ID of the recipe expanded.
+	names map[string][]string
+	// nextNetworkID is the network ID of the next variant. Network IDs are handed out counting down from the highest
+	// network ID possible, so that they never collide with the network IDs of the server.
+	nextNetworkID uint32
+}
+
+// newRecipeVariants creates a new, empty set of recipe variants.
+func newRecipeVariants() *recipeVariants {
+	return &recipeVariants{originals: make(map[uint32]uint32), uuids: make(map[uuid.UUID]uuid.UUID), names: make(map[string][]string), nextNetworkID: math.MaxUint32}
+}
+
+// WithItemTags sets the items of the item tags used by recipes, for versions that do not support item tags. Every
+// recipe using item tags is expanded into a legacy recipe for every combination of items, up to a limit. Recipes with
+// unknown item tags are dropped.
+func (t *DefaultItemTranslator) WithItemTags(tags *mapping.ItemTags) *DefaultItemTranslator {
+	t.tags, t.variants = tags, newRecipeVariants()
+	return t
+}
+
+// expandRecipes expands the recipes passed that use item tags into a recipe for every combination of items of those
+// tags. If clear is true, the variants of the recipes sent before are forgotten.
+func (t *DefaultItemTranslator) expandRecipes(recipes []protocol.Recipe, clear bool) []protocol.Recipe {
+	t.variants.mu.Lock()
+	defer t.variants.mu.Unlock()
+	if clear {
+		t.variants.originals, t.variants.uuids, t.variants.names = make(map[uint32]uint32), make(map[uuid.UUID]uuid.UUID), make(map[string][]string)
+		t.variants.nextNetworkID = math.MaxUint32
+	}
+
+	result := make([]protocol.Recipe, 0, len(recipes))
+	for _, recipe := range recipes {
+		var input []protocol.ItemDescriptorCount
+		switch recipe := recipe.(type) {
+		case *protocol.ShapelessRecipe:
+			input = recipe.Input
+		case *protocol.ShapedRecipe:
+			input = recipe.Input
+		case *protocol.ShulkerBoxRecipe:
+			input = recipe.Input
+		case *protocol.ShapelessChemistryRecipe:
+			input = recipe.Input
+		case *protocol.ShapedChemistryRecipe:
+			input = recipe.Input
+		default:
+			result = append(result, recipe)
+			continue
+		}
+		inputs, ok := t.recipeInputs(input)
+		if !ok {
+			continue
+		}
+		for i, input := range inputs {
+			switch recipe := recipe.(type) {
+			case *protocol.ShapelessRecipe:
+				variant := *recipe
+				variant.Input, variant.Output = input, append([]protocol.ItemStack(nil), recipe.Output...)
+				variant.RecipeID, variant.RecipeNetworkID, variant.UUID = t.recipeVariant(recipe.RecipeID, recipe.RecipeNetworkID, recipe.UUID, i)
+				result = append(result, &variant)
+			case *protocol.ShapedRecipe:
+				variant := *recipe
+				variant.Input, variant.Output = input, append([]protocol.ItemStack(nil), recipe.Output...)
+				variant.RecipeID, variant.RecipeNetworkID, variant.UUID = t.recipeVariant(recipe.RecipeID, recipe.RecipeNetworkID, recipe.UUID, i)
+				result = append(result, &variant)
+			case *protocol.ShulkerBoxRecipe:
+				variant := *recipe
+				variant.Input, variant.Output = input, append([]protocol.ItemStack(nil), recipe.Output...)
+				variant.RecipeID, variant.RecipeNetworkID, variant.UUID = t.recipeVariant(recipe.RecipeID, recipe.RecipeNetworkID, recipe.UUID, i)
+				result = append(result, &variant)
+			case *protocol.ShapelessChemistryRecipe:
+				variant := *recipe
+				variant.Input, variant.Output = input, append([]protocol.ItemStack(nil), recipe.Output...)
+				variant.RecipeID, variant.RecipeNetworkID, variant.UUID = t.recipeVariant(recipe.RecipeID, recipe.RecipeNetworkID, recipe.UUID, i)
+				result = append(result, &variant)
+			case *protocol.ShapedChemistryRecipe:
+				variant := *recipe
+				variant.Input, variant.Output = input, append([]protocol.ItemStack(nil), recipe.Output...)
+				variant.RecipeID, variant.RecipeNetworkID, variant.UUID = t.recipeVariant(recipe.RecipeID, recipe.RecipeNetworkID, recipe.UUID, i)
+				result = append(result, &variant)
+			}
+		}
+	}
+	return result
+}
+
+// recipeInputs returns the input of every variant of a recipe with the input passed. Slots using the same item tag
+// always get the same item, so that for example a crafting table is made of a single kind of planks. False is
+// returned if the input uses an unknown item tag.
+func (t *DefaultItemTranslator) recipeInputs(input []protocol.ItemDescriptorCount) ([][]protocol.ItemDescriptorCount, bool) {
+	var tags []string
+	items := make(map[string][]protocol.ItemType)
+	for _, in := range input {
+		descriptor, ok := in.Descriptor.(*protocol.ItemTagItemDescriptor)
+		if !ok {
+			continue
+		}
+		if _, ok := items[descriptor.Tag]; ok {
+			continue
+		}
+		tagItems := t.tagItems(descriptor.Tag)
+		if len(tagItems) == 0 {
+			return nil, false
+		}
+		tags, items[descriptor.Tag] = append(tags, descriptor.Tag), tagItems
+	}
+	if len(tags) == 0 {
+		return [][]protocol.ItemDescriptorCount{input}, true
+	}
+
+	count := 1
+	for _, tag := range tags {
+		if count *= len(items[tag]); count >= maxRecipeVariants {
+			count = maxRecipeVariants
+			break
+		}
+	}
+	inputs := make([][]protocol.ItemDescriptorCount, count)
+	for n := range inputs {
+		chosen, remainder := make(map[string]protocol.ItemType, len(tags)), n
+		for _, tag := range tags {
+			chosen[tag] = items[tag][remainder%len(items[tag])]
+			remainder /= len(items[tag])
+		}
+		inputs[n] = make([]protocol.ItemDescriptorCount, len(input))
+		for i, in := range input {
+			if descriptor, ok := in.Descriptor.(*protocol.ItemTagItemDescriptor); ok {
+				itemType := chosen[descriptor.Tag]
+				in.Descriptor = &protocol.DefaultItemDescriptor{NetworkID: int16(itemType.NetworkID), MetadataValue: int16(itemType.MetadataValue)}
+			} else {
+				in.Descriptor = cloneItemDescriptor(in.Descriptor)
+			}
+			inputs[n][i] = in
+		}
+	}
+	return inputs, true
+}
+
+// tagItems returns the items of the item tag passed that exist in the legacy version. Items that are the same item
+// in the legacy version, or that are already matched by an earlier item with any metadata, are only returned once.
+func (t *DefaultItemTranslator) tagItems(tag string) []protocol.ItemType {
+	names, ok := t.tags.Items(tag)
+	if !ok {
+		return nil
+	}
+	infoUpdate, _ := t.mapping.ItemNameToRuntimeID("minecraft:info_update")
+
+	var items []protocol.ItemType
+	seen := make(map[protocol.ItemType]struct{})
+	for _, name := range names {
+		rid, ok := t.latest.ItemNameToRuntimeID(name)
+		if !ok {
+			continue
+		}
+		itemType := protocol.ItemType{NetworkID: rid, MetadataValue: anyMetadata}
+		legacy := t.DowngradeItemType(itemType)
+		if legacy.NetworkID == infoUpdate {
+			continue
+		}
+		if _, ok := seen[legacy]; ok {
+			continue
+		}
+		if _, ok := seen[protocol.ItemType{NetworkID: legacy.NetworkID, MetadataValue: anyMetadata}]; ok {
+			continue
+		}
+		seen[legacy] = struct{}{}
+		items = append(items, itemType)
+	}
+	return items
+}
+
+// recipeVariant returns the ID, network ID and UUID of the variant with the index passed of a recipe. The first
+// variant keeps the IDs of the recipe.
+func (t *DefaultItemTranslator) recipeVariant(id string, networkID uint32, recipeUUID uuid.UUID, index int) (string, uint32, uuid.UUID) {
+	if index == 0 {
+		return id, networkID, recipeUUID
+	}
+	variantID, variantNetworkID, variantUUID := fmt.Sprintf("%v_%v", id, index), t.variants.nextNetworkID, uuid.New()
+	t.variants.nextNetworkID--
+	t.variants.originals[variantNetworkID] = networkID
+	t.variants.uuids[variantUUID] = recipeUUID
+	t.variants.names[id] = append(t.variants.names[id], variantID)
+	return variantID, variantNetworkID, variantUUID
+}
+
+// expandRecipeIDs adds the IDs of the variants of the recipes passed, as sent in an UnlockedRecipes packet.
+func (t *DefaultItemTranslator) expandRecipeIDs(ids []string) []string {
+	if t.variants == nil {
+		return ids
+	}
+	t.variants.mu.Lock()
+	defer t.variants.mu.Unlock()
+	result := make([]string, 0, len(ids))
+	for _, id := range ids {
+		result = append(result, id)
+		result = append(result, t.variants.names[id]...)
+	}
+	return result
+}
+
+// upgradeRecipeAction replaces the network ID of a recipe variant crafted in the action passed with the network ID
+// of the recipe it was expanded from.
+func (t *DefaultItemTranslator) upgradeRecipeAction(action protocol.StackRequestAction) {
+	if t.variants == nil {
+		return
+	}
+	t.variants.mu.Lock()
+	defer t.variants.mu.Unlock()
+	var networkID *uint32
+	switch act := action.(type) {
+	case *protocol.CraftRecipeStackRequestAction:
+		networkID = &act.RecipeNetworkID
+	case *protocol.AutoCraftRecipeStackRequestAction:
+		networkID = &act.RecipeNetworkID
+	case *protocol.CraftRecipeOptionalStackRequestAction:
+		networkID = &act.RecipeNetworkID
+	default:
+		return
+	}
+	if original, ok := t.variants.originals[*networkID]; ok {
+		*networkID = original
+	}
+}
+
+// upgradeRecipeUUID returns the UUID of the recipe the recipe variant with the UUID passed was expanded from, as sent
+// by legacy clients in crafting events. UUIDs of recipes that were not expanded are returned untouched.
+func (t *DefaultItemTranslator) upgradeRecipeUUID(recipeUUID uuid.UUID) uuid.UUID {
+	if t.variants == nil {
+		return recipeUUID
+	}
+	t.variants.mu.Lock()
+	defer t.variants.mu.Unlock()
+	if original, ok := t.variants.uuids[recipeUUID]; ok {
+		return original
+	}
+	return recipeUUID
+}
+
+// cloneItemDescriptor returns a copy of the item descriptor passed, so that the variants of a recipe never share
+// descriptors that are downgraded in place.
+func cloneItemDescriptor(descriptor protocol.ItemDescriptor) protocol.ItemDescriptor {
+	switch descriptor := descriptor.(type) {
+	case *protocol.DefaultItemDescriptor:
+		clone := *descriptor
+		return &clone
+	case *protocol.MoLangItemDescriptor:
+		clone := *descriptor
+		return &clone
+	case *protocol.ItemTagItemDescriptor:
+		clone := *descriptor
+		return &clone
+	case *protocol.DeferredItemDescriptor:
+		clone := *descriptor
+		return &clone
+	case *protocol.ComplexAliasItemDescriptor:
+		clone := *descriptor
+		return &clone
+	}
+	return descriptor
+}
@@ -0,0 +1,140 @@
+package translator
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/flonja/multiversion/mapping"
+	"github.com/flonja/multiversion/protocols/latest"
+	"github.com/google/uuid"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+)
+
+// testItemTags holds the item tags used to test the expansion of recipes.
+var testItemTags = map[string][]string{
+	"test:three": {"minecraft:stone", "minecraft:dirt", "minecraft:cobblestone"},
+	"test:two":   {"minecraft:sand", "minecraft:gravel"},
+	"test:ten": {"minecraft:stone", "minecraft:dirt", "minecraft:cobblestone", "minecraft:sand", "minecraft:gravel",
+		"minecraft:apple", "minecraft:bread", "minecraft:stick", "minecraft:diamond", "minecraft:coal"},
+	"test:ten_more": {"minecraft:iron_ingot", "minecraft:gold_ingot", "minecraft:emerald", "minecraft:crafting_table",
+		"minecraft:stone", "minecraft:dirt", "minecraft:cobblestone", "minecraft:sand", "minecraft:gravel", "minecraft:apple"},
+	"test:duplicates": {"minecraft:stone", "minecraft:stone", "minecraft:not_an_item", "minecraft:dirt", "minecraft:info_update"},
+}
+
+// newTestRecipeTranslator creates an item translator that expands recipes using the test item tags.
+func newTestRecipeTranslator(t *testing.T) *DefaultItemTranslator {
+	raw, err := json.Marshal(testItemTags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewItemTranslator(latest.NewItemMapping(), latest.NewItemMapping(), latest.NewBlockMapping(), latest.NewBlockMapping()).
+		WithItemTags(mapping.NewItemTags(raw))
+}
+
+// newTestRecipe creates a shapeless recipe with an input of every item tag passed.
+func newTestRecipe(tags ...string) *protocol.ShapelessRecipe {
+	recipe := &protocol.ShapelessRecipe{RecipeID: "test_recipe", UUID: uuid.New(), Block: "crafting_table", RecipeNetworkID: 7}
+	for _, tag := range tags {
+		recipe.Input = append(recipe.Input, protocol.ItemDescriptorCount{Descriptor: &protocol.ItemTagItemDescriptor{Tag: tag}, Count: 1})
+	}
+	return recipe
+}
+
+// TestExpandRecipes checks that recipes using item tags are expanded into a variant for every combination of items,
+// and that the IDs of every variant are mapped back to those of the original recipe when upgrading.
+func TestExpandRecipes(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		tags     []string
+		variants int
+	}{
+		{name: "single tag", tags: []string{"test:three"}, variants: 3},
+		{name: "cartesian product", tags: []string{"test:three", "test:two", "test:three"}, variants: 6},
+		{name: "variant cap", tags: []string{"test:ten", "test:ten_more"}, variants: maxRecipeVariants},
+		{name: "unknown tag", tags: []string{"test:three", "test:unknown"}, variants: 0},
+		{name: "duplicate items", tags: []string{"test:duplicates"}, variants: 2},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			tr, recipe := newTestRecipeTranslator(t), newTestRecipe(test.tags...)
+			result := tr.expandRecipes([]protocol.Recipe{recipe}, true)
+			if len(result) != test.variants {
+				t.Fatalf("expected %v variants, got %v", test.variants, len(result))
+			}
+
+			ids, networkIDs, uuids, inputs := make(map[string]bool), make(map[uint32]bool), make(map[uuid.UUID]bool), make(map[[3]int32]bool)
+			for i, r := range result {
+				variant := r.(*protocol.ShapelessRecipe)
+				if i == 0 && (variant.RecipeID != recipe.RecipeID || variant.RecipeNetworkID != recipe.RecipeNetworkID || variant.UUID != recipe.UUID) {
+					t.Fatalf("expected the first variant to keep the IDs of the recipe, got %v %v %v", variant.RecipeID, variant.RecipeNetworkID, variant.UUID)
+				}
+				if ids[variant.RecipeID] || networkIDs[variant.RecipeNetworkID] || uuids[variant.UUID] {
+					t.Fatalf("variant %v shares its IDs with an earlier variant", i)
+				}
+				ids[variant.RecipeID], networkIDs[variant.RecipeNetworkID], uuids[variant.UUID] = true, true, true
+
+				var input [3]int32
+				for j, in := range variant.Input {
+					descriptor, ok := in.Descriptor.(*protocol.DefaultItemDescriptor)
+					if !ok {
+						t.Fatalf("variant %v: expected a default item descriptor, got %#v", i, in.Descriptor)
+					}
+					if test.tags[j] == test.tags[0] && j != 0 && descriptor.NetworkID != int16(input[0]) {
+						t.Fatalf("variant %v: expected inputs using the same tag to get the same item", i)
+					}
+					input[j] = int32(descriptor.NetworkID)
+				}
+				if inputs[input] {
+					t.Fatalf("variant %v: input %v was already used by an earlier variant", i, input)
+				}
+				inputs[input] = true
+
+				action := &protocol.CraftRecipeStackRequestAction{RecipeNetworkID: variant.RecipeNetworkID}
+				if tr.upgradeRecipeAction(action); action.RecipeNetworkID != recipe.RecipeNetworkID {
+					t.Fatalf("variant %v: expected network ID %v when upgrading, got %v", i, recipe.RecipeNetworkID, action.RecipeNetworkID)
+				}
+				if upgraded := tr.upgradeRecipeUUID(variant.UUID); upgraded != recipe.UUID {
+					t.Fatalf("variant %v: expected UUID %v when upgrading, got %v", i, recipe.UUID, upgraded)
+				}
+			}
+		})
+	}
+}
+
+// TestExpandRecipesClear checks that recipes without item tags are left untouched, and that the variants of earlier
+// recipes are forgotten when the recipes are cleared.
+func TestExpandRecipesClear(t *testing.T) {
+	tr := newTestRecipeTranslator(t)
+	plain := &protocol.ShapelessRecipe{RecipeID: "plain", RecipeNetworkID: 3, Input: []protocol.ItemDescriptorCount{
+		{Descriptor: &protocol.DefaultItemDescriptor{NetworkID: 1}, Count: 1},
+	}}
+	result := tr.expandRecipes([]protocol.Recipe{plain, newTestRecipe("test:two")}, true)
+	if len(result) != 3 || !reflect.DeepEqual(result[0], plain) {
+		t.Fatalf("expected the plain recipe to be left untouched, got %#v", result)
+	}
+	variant := result[2].(*protocol.ShapelessRecipe)
+
+	tr.expandRecipes(nil, true)
+	action := &protocol.CraftRecipeStackRequestAction{RecipeNetworkID: variant.RecipeNetworkID}
+	if tr.upgradeRecipeAction(action); action.RecipeNetworkID != variant.RecipeNetworkID {
+		t.Fatalf("expected network ID %v to be forgotten, got %v", variant.RecipeNetworkID, action.RecipeNetworkID)
+	}
+	if upgraded := tr.upgradeRecipeUUID(variant.UUID); upgraded != variant.UUID {
+		t.Fatalf("expected UUID %v to be forgotten, got %v", variant.UUID, upgraded)
+	}
+}
+
+// TestTagItems checks that items of an item tag are only returned once, and that items that do not exist in the
+// legacy version are left out.
+func TestTagItems(t *testing.T) {
+	tr := newTestRecipeTranslator(t)
+	stone, _ := tr.latest.ItemNameToRuntimeID("minecraft:stone")
+	dirt, _ := tr.latest.ItemNameToRuntimeID("minecraft:dirt")
+	expected := []protocol.ItemType{{NetworkID: stone, MetadataValue: anyMetadata}, {NetworkID: dirt, MetadataValue: anyMetadata}}
+	if items := tr.tagItems("test:duplicates"); !reflect.DeepEqual(items, expected) {
+		t.Fatalf("expected items %v, got %v", expected, items)
+	}
+	if items := tr.tagItems("test:unknown"); len(items) != 0 {
+		t.Fatalf("expected no items for an unknown tag, got %v", items)
+	}
+}
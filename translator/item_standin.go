@@ -0,0 +1,96 @@
+package translator
+
+import (
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/df-mc/dragonfly/server/item/category"
+	"github.com/flonja/multiversion/internal/item"
+	"golang.org/x/exp/slices"
+	"golang.org/x/image/colornames"
+)
+
+// standInItem is a component based custom item shown to legacy clients in place of an item that does not exist in
+// the legacy version.
+type standInItem struct {
+	identifier string
+	name       string
+	texture    image.Image
+}
+
+func (s standInItem) EncodeItem() (name string, meta int16) {
+	return s.identifier, 0
+}
+
+func (s standInItem) Name() string {
+	return s.name
+}
+
+func (s standInItem) Texture() image.Image {
+	return s.texture
+}
+
+func (s standInItem) Category() category.Category {
+	return category.Items()
+}
+
+// RegisterStandIns registers a custom item for every item of the latest version that does not exist in the legacy
+// version. Items with an exact equivalent in the item fallback are left alone. The texture of each item is read from
+// '<name>.png' in the texture directory passed, and a placeholder is used if it is not found.
+func (t *DefaultItemTranslator) RegisterStandIns(textureDir string) {
+	names := t.latest.ItemNames()
+	slices.Sort(names)
+	for _, name := range names {
+		if name == "minecraft:air" {
+			continue
+		}
+		rid, _ := t.latest.ItemNameToRuntimeID(name)
+		if _, ok := t.originalToCustom[rid]; ok {
+			continue
+		}
+		i := item.Downgrade(item.Item{
+			Name:    name,
+			Version: t.latest.ItemVersion(),
+		}, t.mapping.ItemVersion())
+		if _, ok := t.mapping.ItemNameToRuntimeID(i.Name); ok {
+			continue
+		}
+		if t.fallback != nil {
+			if substitute, _, ok := t.fallback.Downgrade(name, 0); ok && substitute.DisplayName == "" {
+				continue
+			}
+		}
+
+		_, path, _ := strings.Cut(name, ":")
+		t.Register(standInItem{
+			identifier: "multiversion:mv_" + strings.ReplaceAll(path, ".", "_"),
+			name:       itemDisplayName(name),
+			texture:    standInTexture(filepath.Join(textureDir, strings.TrimPrefix(path, "item.")+".png")),
+		}, name)
+	}
+}
+
+// itemDisplayName turns an item name such as 'minecraft:item.glow_frame' into a readable name such as 'Glow Frame'.
+func itemDisplayName(name string) string {
+	return entityDisplayName(strings.TrimPrefix(strings.TrimPrefix(name, "minecraft:"), "item."))
+}
+
+// standInTexture reads the PNG texture at the path passed, or returns a goldenrod placeholder if it could not be read.
+func standInTexture(path string) image.Image {
+	if f, err := os.Open(path); err == nil {
+		defer f.Close()
+		if img, err := png.Decode(f); err == nil {
+			return img
+		}
+	}
+	im := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	for x := 0; x < im.Bounds().Dx(); x++ {
+		for y := 0; y < im.Bounds().Dy(); y++ {
+			im.SetRGBA(x, y, colornames.Goldenrod)
+		}
+	}
+	return im
+}
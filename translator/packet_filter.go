@@ -0,0 +1,149 @@
+package translator
+
+import (
+	"github.com/sandertv/gophertunnel/minecraft"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// UnknownPacketFunc is called for every packet that does not exist in a legacy version, right before it is dropped.
+// It may be used to log such packets, or to emulate them by returning packets of the legacy version that are sent in
+// their place. It is called while a packet is being written to the connection, so it must not write packets itself.
+type UnknownPacketFunc func(pk packet.Packet, conn *minecraft.Conn) []packet.Packet
+
+// PacketFilter filters the packets that do not exist in a legacy version. Legacy clients disconnect when receiving
+// packets they cannot decode, so these packets are emulated or never sent to them, and such packets sent by legacy
+// clients are never passed on.
+type PacketFilter struct {
+	unknown  map[uint32]struct{}
+	emulator Emulator
+	f        UnknownPacketFunc
+}
+
+// NewPacketFilter creates a packet filter for a legacy version, holding the IDs of the packets of the latest version
+// that do not exist in the legacy version. These packets are emulated using the emulator passed, if not nil.
+func NewPacketFilter(unknown []uint32, emulator Emulator) *PacketFilter {
+	f := &PacketFilter{unknown: make(map[uint32]struct{}, len(unknown)), emulator: emulator}
+	for _, id := range unknown {
+		f.unknown[id] = struct{}{}
+	}
+	return f
+}
+
+// SetUnknownPacketFunc sets the function called for packets that do not exist in the legacy version. By default,
+// such packets are dropped silently.
+func (f *PacketFilter) SetUnknownPacketFunc(fn UnknownPacketFunc) {
+	f.f = fn
+}
+
+// SetEmulator sets the emulator used to emulate packets that do not exist in the legacy version. If nil, such packets
+// are not emulated.
+func (f *PacketFilter) SetEmulator(emulator Emulator) {
+	f.emulator = emulator
+}
+
+// Pool returns the packet pool of the legacy version, holding the packets of both clients and servers that exist in
+// the legacy version. The legacy packets passed replace the packets with the same ID, but are only added if that
+// packet exists in the legacy version.
+func (f *PacketFilter) Pool(legacy packet.Pool) packet.Pool {
+	pool := packet.NewClientPool()
+	for id, pk := range packet.NewServerPool() {
+		pool[id] = pk
+	}
+	for id := range f.unknown {
+		delete(pool, id)
+	}
+	for id, pk := range legacy {
+		if _, ok := pool[id]; ok {
+			pool[id] = pk
+		}
+	}
+	return pool
+}
+
+// Known checks if the packet with the ID passed exists in the legacy version.
+func (f *PacketFilter) Known(id uint32) bool {
+	_, ok := f.unknown[id]
+	return !ok
+}
+
+// EmulatePackets replaces the packets that do not exist in the legacy version with the packets emulating them. The
+// packets passed must be packets of the latest version, and the packets returned must still be converted to the legacy
+// version. Packets that could not be emulated are left untouched, so that they are dropped by DowngradePackets.
+func (f *PacketFilter) EmulatePackets(pks []packet.Packet, conn *minecraft.Conn) []packet.Packet {
+	if f.emulator == nil {
+		return pks
+	}
+	result := make([]packet.Packet, 0, len(pks))
+	for _, pk := range pks {
+		if _, ok := pk.(*packet.Disconnect); ok {
+			f.emulator.Release(conn)
+		}
+		if f.Known(pk.ID()) {
+			result = append(result, pk)
+			continue
+		}
+		if emulated, ok := f.emulator.Emulate(pk, conn); ok {
+			result = append(result, emulated...)
+			continue
+		}
+		result = append(result, pk)
+	}
+	return result
+}
+
+// DowngradePackets drops the packets that do not exist in the legacy version, replacing them with the packets returned
+// by the unknown packet function. The packets passed must already be converted to the legacy version.
+func (f *PacketFilter) DowngradePackets(pks []packet.Packet, conn *minecraft.Conn) (result []packet.Packet) {
+	for _, pk := range pks {
+		if f.Known(pk.ID()) {
+			result = append(result, pk)
+			continue
+		}
+		if f.f != nil {
+			for _, replacement := range f.f(pk, conn) {
+				// Replacements are filtered too, so that a faulty function never gets the client disconnected.
+				if f.Known(replacement.ID()) {
+					result = append(result, replacement)
+				}
+			}
+		}
+	}
+	return result
+}
+
+// UpgradePackets drops the packets sent by the legacy client that are not in its packet pool, which the legacy
+// version should never send.
+func (f *PacketFilter) UpgradePackets(pks []packet.Packet, _ *minecraft.Conn) (result []packet.Packet) {
+	for _, pk := range pks {
+		if _, ok := pk.(*packet.Unknown); ok || !f.Known(pk.ID()) {
+			continue
+		}
+		result = append(result, pk)
+	}
+	return result
+}
+
+// ConsumePackets replaces the packets sent by the legacy client in response to emulated packets with the packets the
+// server expects. The packets passed must already be converted to the latest version.
+func (f *PacketFilter) ConsumePackets(pks []packet.Packet, conn *minecraft.Conn) []packet.Packet {
+	if f.emulator == nil {
+		return pks
+	}
+	result := make([]packet.Packet, 0, len(pks))
+	for _, pk := range pks {
+		if consumed, ok := f.emulator.Consume(pk, conn); ok {
+			result = append(result, consumed...)
+			continue
+		}
+		result = append(result, pk)
+	}
+	return result
+}
+
+// Release releases the emulation state of the connection passed. It should be called for connections that were
+// closed without a Disconnect packet being sent.
+func (f *PacketFilter) Release(conn *minecraft.Conn) {
+	if f.emulator != nil {
+		f.emulator.Release(conn)
+	}
+}
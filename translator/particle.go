@@ -0,0 +1,127 @@
+package translator
+
+import (
+	"fmt"
+
+	"github.com/sandertv/gophertunnel/minecraft"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+type ParticleTranslator interface {
+	// DowngradeParticleName downgrades the input particle name, as sent in the SpawnParticleEffect packet, to a legacy
+	// particle name.
+	DowngradeParticleName(name string) string
+	// DowngradeParticleType downgrades the input particle type, as sent with the legacy particle level event, to a
+	// legacy particle type.
+	DowngradeParticleType(particleType int32) int32
+	// DowngradeLevelEvent downgrades the input level event type to a legacy level event type. False is returned if the
+	// level event should not be sent to the legacy client at all.
+	DowngradeLevelEvent(eventType int32) (int32, bool)
+	// DowngradeParticlePackets downgrades the input particle packets to legacy particle packets.
+	DowngradeParticlePackets(pks []packet.Packet, conn *minecraft.Conn) []packet.Packet
+	// RegisterName registers a particle name shown to legacy clients in place of a particle name. If the substitute is
+	// empty, the fallback particle is shown instead.
+	RegisterName(name, substitute string)
+	// RegisterType registers a particle type shown to legacy clients in place of a particle type. If the substitute is
+	// zero, the fallback particle is shown instead.
+	RegisterType(particleType, substitute int32)
+	// RegisterLevelEvent registers a level event sent to legacy clients in place of a level event type. If the
+	// substitute is zero, the level event is not sent at all.
+	RegisterLevelEvent(eventType, substitute int32)
+}
+
+type DefaultParticleTranslator struct {
+	// first is the first particle type that does not exist in the legacy version.
+	first int32
+	// fallbackName and fallbackType are the name and type of the particle shown in place of particles that do not
+	// exist in the legacy version.
+	fallbackName string
+	fallbackType int32
+
+	names  map[string]string
+	types  map[int32]int32
+	events map[int32]int32
+}
+
+// NewParticleTranslator creates a new particle translator. Particle types are only ever added, apart from the ones
+// registered, so all particle types starting from the first type passed are replaced with the fallback particle. The
+// fallback particle is passed both by its name and by its type in the legacy version.
+func NewParticleTranslator(first int32, fallbackName string, fallbackType int32) *DefaultParticleTranslator {
+	return &DefaultParticleTranslator{first: first, fallbackName: fallbackName, fallbackType: fallbackType,
+		names: make(map[string]string), types: make(map[int32]int32), events: make(map[int32]int32)}
+}
+
+func (t *DefaultParticleTranslator) DowngradeParticleName(name string) string {
+	substitute, ok := t.names[name]
+	if !ok {
+		return name
+	}
+	if substitute == "" {
+		return t.fallbackName
+	}
+	return substitute
+}
+
+func (t *DefaultParticleTranslator) DowngradeParticleType(particleType int32) int32 {
+	substitute, ok := t.types[particleType]
+	if !ok && particleType < t.first {
+		return particleType
+	}
+	if substitute == 0 {
+		return t.fallbackType
+	}
+	return substitute
+}
+
+func (t *DefaultParticleTranslator) DowngradeLevelEvent(eventType int32) (int32, bool) {
+	if eventType&packet.LevelEventParticleLegacyEvent != 0 {
+		particleType := eventType &^ packet.LevelEventParticleLegacyEvent
+		return packet.LevelEventParticleLegacyEvent | t.DowngradeParticleType(particleType), true
+	}
+	substitute, ok := t.events[eventType]
+	if !ok {
+		return eventType, true
+	}
+	return substitute, substitute != 0
+}
+
+// DowngradeParticlePackets downgrades the input particle packets to legacy particle packets. The data of level events
+// is not translated here, so the packets must have passed through the item and block translators already, which
+// recognise particles by their types in the latest version.
+func (t *DefaultParticleTranslator) DowngradeParticlePackets(pks []packet.Packet, _ *minecraft.Conn) (result []packet.Packet) {
+	for _, pk := range pks {
+		switch pk := pk.(type) {
+		case *packet.SpawnParticleEffect:
+			pk.ParticleName = t.DowngradeParticleName(pk.ParticleName)
+		case *packet.LevelEvent:
+			eventType, ok := t.DowngradeLevelEvent(pk.EventType)
+			if !ok {
+				continue
+			}
+			pk.EventType = eventType
+		}
+		result = append(result, pk)
+	}
+	return result
+}
+
+func (t *DefaultParticleTranslator) RegisterName(name, substitute string) {
+	if _, ok := t.names[name]; ok {
+		panic(fmt.Errorf("particle %v is already mapped", name))
+	}
+	t.names[name] = substitute
+}
+
+func (t *DefaultParticleTranslator) RegisterType(particleType, substitute int32) {
+	if _, ok := t.types[particleType]; ok {
+		panic(fmt.Errorf("particle type %v is already mapped", particleType))
+	}
+	t.types[particleType] = substitute
+}
+
+func (t *DefaultParticleTranslator) RegisterLevelEvent(eventType, substitute int32) {
+	if _, ok := t.events[eventType]; ok {
+		panic(fmt.Errorf("level event %v is already mapped", eventType))
+	}
+	t.events[eventType] = substitute
+}
@@ -0,0 +1,108 @@
+package translator
+
+import (
+	"fmt"
+
+	"github.com/sandertv/gophertunnel/minecraft"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+type SoundTranslator interface {
+	// DowngradeSoundType downgrades the input sound event type to a legacy sound. False is returned if the sound
+	// should not be played to the legacy client at all.
+	DowngradeSoundType(soundType uint32) (SoundSubstitute, bool)
+	// DowngradeSoundName downgrades the input sound name, as sent in the PlaySound packet, to a legacy sound name.
+	DowngradeSoundName(name string) string
+	// DowngradeSoundPackets downgrades the input sound packets to legacy sound packets.
+	DowngradeSoundPackets(pks []packet.Packet, conn *minecraft.Conn) []packet.Packet
+	// Register registers a substitute played to legacy clients in place of a sound event type.
+	Register(soundType uint32, substitute SoundSubstitute)
+	// RegisterName registers a sound name played to legacy clients in place of a sound name.
+	RegisterName(name, substitute string)
+}
+
+// SoundSubstitute is a sound played to legacy clients in place of a sound event type that does not exist in the
+// legacy version.
+type SoundSubstitute struct {
+	// SoundType is the sound event type played to the legacy client.
+	SoundType uint32
+	// LevelEvent is the level event sent instead of a sound event if non-zero, for sounds that were played through
+	// level events in the legacy version.
+	LevelEvent int32
+}
+
+type DefaultSoundTranslator struct {
+	// first is the first sound event type that does not exist in the legacy version.
+	first       uint32
+	substitutes map[uint32]SoundSubstitute
+	names       map[string]string
+	entities    EntityTranslator
+}
+
+// NewSoundTranslator creates a new sound translator. Sound event types are only ever added, so all sound event types
+// starting from the first type passed do not exist in the legacy version. Entity types sent with sound events are
+// translated using the entity translator passed.
+func NewSoundTranslator(first uint32, entities EntityTranslator) *DefaultSoundTranslator {
+	return &DefaultSoundTranslator{first: first, substitutes: make(map[uint32]SoundSubstitute), names: make(map[string]string),
+		entities: entities}
+}
+
+func (t *DefaultSoundTranslator) DowngradeSoundType(soundType uint32) (SoundSubstitute, bool) {
+	if substitute, ok := t.substitutes[soundType]; ok {
+		return substitute, true
+	}
+	if soundType < t.first {
+		return SoundSubstitute{SoundType: soundType}, true
+	}
+	return SoundSubstitute{}, false
+}
+
+func (t *DefaultSoundTranslator) DowngradeSoundName(name string) string {
+	if substitute, ok := t.names[name]; ok {
+		return substitute
+	}
+	return name
+}
+
+func (t *DefaultSoundTranslator) DowngradeSoundPackets(pks []packet.Packet, _ *minecraft.Conn) (result []packet.Packet) {
+	for _, pk := range pks {
+		switch pk := pk.(type) {
+		case *packet.LevelSoundEvent:
+			substitute, ok := t.DowngradeSoundType(pk.SoundType)
+			if !ok {
+				continue
+			}
+			if substitute.LevelEvent != 0 {
+				result = append(result, &packet.LevelEvent{EventType: substitute.LevelEvent, Position: pk.Position})
+				continue
+			}
+			pk.SoundType = substitute.SoundType
+			if pk.EntityType != "" && t.entities != nil {
+				entity, ok := t.entities.DowngradeEntityType(pk.EntityType)
+				if !ok {
+					// The entity is not shown to the legacy client, so the sound is played without an entity type.
+					entity.EntityType = ""
+				}
+				pk.EntityType = entity.EntityType
+			}
+		case *packet.PlaySound:
+			pk.SoundName = t.DowngradeSoundName(pk.SoundName)
+		}
+		result = append(result, pk)
+	}
+	return result
+}
+
+func (t *DefaultSoundTranslator) Register(soundType uint32, substitute SoundSubstitute) {
+	if _, ok := t.substitutes[soundType]; ok {
+		panic(fmt.Errorf("sound %v is already mapped", soundType))
+	}
+	t.substitutes[soundType] = substitute
+}
+
+func (t *DefaultSoundTranslator) RegisterName(name, substitute string) {
+	if _, ok := t.names[name]; ok {
+		panic(fmt.Errorf("%v is already mapped", name))
+	}
+	t.names[name] = substitute
+}
@@ -0,0 +1,297 @@
+package translator
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/df-mc/dragonfly/server/block/cube"
+	"github.com/flonja/multiversion/internal/chunk"
+	"github.com/sandertv/gophertunnel/minecraft"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// maxSubChunkRequests is the maximum number of sub chunk requests added to a single packet sent by the client. The
+// connection buffers a limited number of packets converted from a single packet.
+const maxSubChunkRequests = 8
+
+// SubChunkBridge rebuilds full chunks for legacy clients that do not support sub chunk requesting. Chunks sent in one
+// of the sub chunk request modes are held back while their sub chunks are requested on behalf of the client. Once all
+// sub chunks arrived, or the timeout passed, the chunk is sent to the client as a full chunk.
+//
+// Sub chunk requests are sent to the server along with the next packet the client sends, so the bridge must be used
+// in both directions. Chunks that time out while no packets are sent to the client are written to the connection.
+type SubChunkBridge struct {
+	timeout time.Duration
+	// write writes the chunks that timed out to the connection passed.
+	write func(conn *minecraft.Conn, pks []packet.Packet)
+
+	mu    sync.Mutex
+	conns map[*minecraft.Conn]*subChunkRequests
+}
+
+// subChunkRequests holds the chunks of a single connection that are waiting for their sub chunks.
+type subChunkRequests struct {
+	// r is the height range of the dimension the connection is in.
+	r cube.Range
+	// chunks holds the chunks waiting for their sub chunks, indexed by their position.
+	chunks map[protocol.ChunkPos]*pendingChunk
+	// unsent holds the positions of the chunks whose sub chunks were not requested yet, in the order they were sent.
+	unsent []protocol.ChunkPos
+	// timer flushes the chunks that timed out. It is nil if no sub chunks are requested.
+	timer *time.Timer
+}
+
+// pendingChunk is a chunk held back until its sub chunks arrive.
+type pendingChunk struct {
+	// biomes holds the biomes sent with the chunk, followed by its border blocks and block entities.
+	biomes []byte
+	// subChunks holds every encoded sub chunk received so far, with nil for sub chunks that did not arrive yet.
+	subChunks [][]byte
+	// remaining is the number of sub chunks that did not arrive yet.
+	remaining int
+	// blockEntities holds the encoded block entities of the sub chunks received so far.
+	blockEntities []byte
+	// deadline is the time after which the chunk is sent with the sub chunks received so far. It is zero until the
+	// sub chunks are requested.
+	deadline time.Time
+}
+
+// NewSubChunkBridge creates a new sub chunk bridge. Chunks whose sub chunks did not all arrive within the timeout
+// passed are sent with the missing sub chunks left empty.
+func NewSubChunkBridge(timeout time.Duration) *SubChunkBridge {
+	return &SubChunkBridge{timeout: timeout, write: writePackets, conns: make(map[*minecraft.Conn]*subChunkRequests)}
+}
+
+// writePackets writes the packets of the latest version passed to the connection, which converts them for the client.
+func writePackets(conn *minecraft.Conn, pks []packet.Packet) {
+	for _, pk := range pks {
+		_ = conn.WritePacket(pk)
+	}
+}
+
+// DowngradeChunkPackets holds back chunks sent in one of the sub chunk request modes and turns the sub chunks sent by
+// the server into full chunks, using the height range of the dimension of the context passed. Chunks that timed out
+// are sent as well.
+func (b *SubChunkBridge) DowngradeChunkPackets(pks []packet.Packet, ctx *Context, conn *minecraft.Conn) (result []packet.Packet) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	requests, ok := b.conns[conn]
+	if !ok {
+		requests = &subChunkRequests{chunks: make(map[protocol.ChunkPos]*pendingChunk)}
+		b.conns[conn] = requests
+	}
+	requests.r = ctx.Dimension.Range()
+	for _, pk := range pks {
+		switch pk := pk.(type) {
+		case *packet.LevelChunk:
+			count := int(pk.SubChunkCount)
+			if count != protocol.SubChunkRequestModeLimitless && count != protocol.SubChunkRequestModeLimited || pk.CacheEnabled {
+				break
+			}
+			if err := requests.hold(pk); err != nil {
+				fmt.Println(err)
+				break
+			}
+			continue
+		case *packet.SubChunk:
+			if !pk.CacheEnabled {
+				result = append(result, requests.receive(pk)...)
+			}
+			// Legacy clients do not know sub chunks, so the packet is dropped either way.
+			continue
+		case *packet.ChangeDimension:
+			// Chunks held back belong to the previous dimension, so they are dropped.
+			requests.chunks, requests.unsent = make(map[protocol.ChunkPos]*pendingChunk), nil
+		case *packet.Disconnect:
+			b.release(conn)
+		}
+		result = append(result, pk)
+	}
+	return append(result, requests.expired()...)
+}
+
+// UpgradeChunkPackets adds the sub chunk requests of chunks held back since the last packet of the client to the
+// packets passed.
+func (b *SubChunkBridge) UpgradeChunkPackets(pks []packet.Packet, conn *minecraft.Conn) []packet.Packet {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	requests, ok := b.conns[conn]
+	if !ok {
+		return pks
+	}
+	for i := 0; i < maxSubChunkRequests && len(requests.unsent) > 0; i++ {
+		pks = append(pks, requests.request(b.timeout))
+	}
+	if deadline, ok := requests.nextDeadline(); ok && requests.timer == nil {
+		requests.timer = time.AfterFunc(time.Until(deadline), func() { b.flush(conn) })
+	}
+	return pks
+}
+
+// flush writes the chunks of the connection passed that timed out, and schedules the next flush if sub chunks of
+// other chunks are still requested.
+func (b *SubChunkBridge) flush(conn *minecraft.Conn) {
+	b.mu.Lock()
+	requests, ok := b.conns[conn]
+	if !ok {
+		b.mu.Unlock()
+		return
+	}
+	expired := requests.expired()
+	requests.timer = nil
+	if deadline, ok := requests.nextDeadline(); ok {
+		requests.timer = time.AfterFunc(time.Until(deadline), func() { b.flush(conn) })
+	}
+	b.mu.Unlock()
+
+	if len(expired) > 0 {
+		b.write(conn, expired)
+	}
+}
+
+// Release releases the chunks held back for the connection passed. It should be called for connections that were
+// closed without a Disconnect packet being sent.
+func (b *SubChunkBridge) Release(conn *minecraft.Conn) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.release(conn)
+}
+
+// release releases the chunks held back for the connection passed. The mutex must be held.
+func (b *SubChunkBridge) release(conn *minecraft.Conn) {
+	if requests, ok := b.conns[conn]; ok && requests.timer != nil {
+		requests.timer.Stop()
+	}
+	delete(b.conns, conn)
+}
+
+// hold holds back the chunk passed until its sub chunks arrive.
+func (s *subChunkRequests) hold(pk *packet.LevelChunk) error {
+	r := s.r
+	count := (r.Height() >> 4) + 1
+	if pk.SubChunkCount == protocol.SubChunkRequestModeLimited {
+		count = int(pk.HighestSubChunk)
+	}
+
+	// The payload only holds the biomes of the chunk, followed by the border blocks and block entities.
+	buf := bytes.NewBuffer(pk.RawPayload)
+	if _, err := chunk.NetworkDecode(0, buf, 0, false, r); err != nil {
+		return fmt.Errorf("decode biomes of chunk %v: %w", pk.Position, err)
+	}
+	if _, ok := s.chunks[pk.Position]; !ok {
+		s.unsent = append(s.unsent, pk.Position)
+	}
+	s.chunks[pk.Position] = &pendingChunk{
+		biomes:    append([]byte(nil), pk.RawPayload...),
+		subChunks: make([][]byte, count),
+		remaining: count,
+	}
+	return nil
+}
+
+// request creates a sub chunk request for the oldest chunks that were not requested yet. All chunks close enough to
+// the oldest chunk to be expressed as an offset are requested at once.
+func (s *subChunkRequests) request(timeout time.Duration) *packet.SubChunkRequest {
+	r := s.r
+	base := s.unsent[0]
+	pk := &packet.SubChunkRequest{Position: protocol.SubChunkPos{base.X(), 0, base.Z()}}
+	deadline := time.Now().Add(timeout)
+
+	unsent := s.unsent[:0]
+	for _, pos := range s.unsent {
+		dx, dz := pos.X()-base.X(), pos.Z()-base.Z()
+		c, ok := s.chunks[pos]
+		if !ok {
+			continue
+		}
+		if dx < -128 || dx > 127 || dz < -128 || dz > 127 {
+			unsent = append(unsent, pos)
+			continue
+		}
+		for i := range c.subChunks {
+			pk.Offsets = append(pk.Offsets, protocol.SubChunkOffset{int8(dx), int8(i + (r[0] >> 4)), int8(dz)})
+		}
+		c.deadline = deadline
+	}
+	s.unsent = unsent
+	return pk
+}
+
+// receive stores the sub chunks passed in the chunks they belong to, and returns the chunks that are complete.
+func (s *subChunkRequests) receive(pk *packet.SubChunk) (result []packet.Packet) {
+	r := s.r
+	for _, entry := range pk.SubChunkEntries {
+		pos := protocol.ChunkPos{pk.Position.X() + int32(entry.Offset[0]), pk.Position.Z() + int32(entry.Offset[2])}
+		c, ok := s.chunks[pos]
+		if !ok {
+			continue
+		}
+		index := int(pk.Position.Y()) + int(entry.Offset[1]) - (r[0] >> 4)
+		if index < 0 || index >= len(c.subChunks) || c.subChunks[index] != nil {
+			continue
+		}
+
+		c.subChunks[index] = chunk.EncodeSubChunk(chunk.NewSubChunk(0), chunk.NetworkEncoding, chunk.SubChunkVersion9, r, index)
+		if entry.Result == protocol.SubChunkResultSuccess {
+			buf := bytes.NewBuffer(entry.RawPayload)
+			ind := byte(index)
+			if _, err := chunk.DecodeSubChunk(0, r, buf, &ind, chunk.NetworkEncoding); err != nil {
+				fmt.Println(err)
+			} else {
+				c.subChunks[index] = entry.RawPayload[:len(entry.RawPayload)-buf.Len()]
+				c.blockEntities = append(c.blockEntities, buf.Bytes()...)
+			}
+		}
+		if c.remaining--; c.remaining == 0 {
+			delete(s.chunks, pos)
+			result = append(result, c.levelChunk(pos, r))
+		}
+	}
+	return result
+}
+
+// expired returns the chunks whose sub chunks were requested longer ago than the timeout, with the sub chunks that did
+// not arrive left empty.
+func (s *subChunkRequests) expired() (result []packet.Packet) {
+	now := time.Now()
+	for pos, c := range s.chunks {
+		if !c.deadline.IsZero() && now.After(c.deadline) {
+			delete(s.chunks, pos)
+			result = append(result, c.levelChunk(pos, s.r))
+		}
+	}
+	return result
+}
+
+// nextDeadline returns the earliest deadline of the chunks whose sub chunks were requested.
+func (s *subChunkRequests) nextDeadline() (deadline time.Time, ok bool) {
+	for _, c := range s.chunks {
+		if !c.deadline.IsZero() && (!ok || c.deadline.Before(deadline)) {
+			deadline, ok = c.deadline, true
+		}
+	}
+	return deadline, ok
+}
+
+// levelChunk creates a full chunk of the sub chunks received so far.
+func (c *pendingChunk) levelChunk(pos protocol.ChunkPos, r cube.Range) *packet.LevelChunk {
+	buf := bytes.NewBuffer(nil)
+	for index, sub := range c.subChunks {
+		if sub == nil {
+			sub = chunk.EncodeSubChunk(chunk.NewSubChunk(0), chunk.NetworkEncoding, chunk.SubChunkVersion9, r, index)
+		}
+		buf.Write(sub)
+	}
+	buf.Write(c.biomes)
+	buf.Write(c.blockEntities)
+	return &packet.LevelChunk{
+		Position:      pos,
+		SubChunkCount: uint32(len(c.subChunks)),
+		RawPayload:    buf.Bytes(),
+	}
+}
@@ -0,0 +1,130 @@
+package translator
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/flonja/multiversion/internal/chunk"
+	"github.com/sandertv/gophertunnel/minecraft"
+	"github.com/sandertv/gophertunnel/minecraft/protocol"
+	"github.com/sandertv/gophertunnel/minecraft/protocol/packet"
+)
+
+// newTestRequestedChunk creates a chunk in the overworld of which the sub chunks must be requested.
+func newTestRequestedChunk(x, z int32) *packet.LevelChunk {
+	return &packet.LevelChunk{
+		Position:      protocol.ChunkPos{x, z},
+		SubChunkCount: protocol.SubChunkRequestModeLimitless,
+		RawPayload:    chunk.EncodeBiomes(chunk.New(0, world.Overworld.Range()), chunk.NetworkEncoding),
+	}
+}
+
+// newTestSubChunks creates a SubChunk packet holding the sub chunks of the overworld chunk at the offset passed from
+// the position of the request, with the indices passed.
+func newTestSubChunks(dx, dz int8, indices ...int) *packet.SubChunk {
+	r := world.Overworld.Range()
+	pk := &packet.SubChunk{}
+	for _, index := range indices {
+		pk.SubChunkEntries = append(pk.SubChunkEntries, protocol.SubChunkEntry{
+			Offset:     protocol.SubChunkOffset{dx, int8(index + (r[0] >> 4)), dz},
+			Result:     protocol.SubChunkResultSuccess,
+			RawPayload: chunk.EncodeSubChunk(chunk.NewSubChunk(0), chunk.NetworkEncoding, chunk.SubChunkVersion9, r, index),
+		})
+	}
+	return pk
+}
+
+// checkTestLevelChunk checks that the packet passed is a full overworld chunk at the position passed.
+func checkTestLevelChunk(t *testing.T, pk packet.Packet, x, z int32) {
+	t.Helper()
+	levelChunk, ok := pk.(*packet.LevelChunk)
+	if !ok || levelChunk.Position != (protocol.ChunkPos{x, z}) || levelChunk.SubChunkCount != 24 {
+		t.Fatalf("expected a full chunk at %v %v, got %#v", x, z, pk)
+	}
+	if _, err := chunk.NetworkDecode(0, bytes.NewBuffer(levelChunk.RawPayload), 24, false, world.Overworld.Range()); err != nil {
+		t.Fatalf("decode chunk at %v %v: %v", x, z, err)
+	}
+}
+
+// TestSubChunkBridgeBatching checks that the sub chunks of chunks close to each other are requested at once, and that
+// a chunk is sent as soon as all of its sub chunks arrived.
+func TestSubChunkBridgeBatching(t *testing.T) {
+	b, conn := NewSubChunkBridge(time.Minute), &minecraft.Conn{}
+	defer b.Release(conn)
+	ctx := &Context{Dimension: NewDimension(packet.DimensionOverworld)}
+
+	held := b.DowngradeChunkPackets([]packet.Packet{newTestRequestedChunk(0, 0), newTestRequestedChunk(1, 0), newTestRequestedChunk(200, 0)}, ctx, conn)
+	if len(held) != 0 {
+		t.Fatalf("expected chunks to be held back, got %v packets", len(held))
+	}
+	requests := b.UpgradeChunkPackets(nil, conn)
+	if len(requests) != 2 {
+		t.Fatalf("expected 2 sub chunk requests, got %v", len(requests))
+	}
+	if first := requests[0].(*packet.SubChunkRequest); first.Position != (protocol.SubChunkPos{0, 0, 0}) || len(first.Offsets) != 48 {
+		t.Fatalf("expected the first request to hold both close chunks, got %v offsets at %v", len(first.Offsets), first.Position)
+	}
+	if second := requests[1].(*packet.SubChunkRequest); second.Position != (protocol.SubChunkPos{200, 0, 0}) || len(second.Offsets) != 24 {
+		t.Fatalf("expected the second request to hold the far chunk, got %v offsets at %v", len(second.Offsets), second.Position)
+	}
+
+	indices := make([]int, 24)
+	for i := range indices {
+		indices[i] = i
+	}
+	if result := b.DowngradeChunkPackets([]packet.Packet{newTestSubChunks(1, 0, indices[:12]...)}, ctx, conn); len(result) != 0 {
+		t.Fatalf("expected an incomplete chunk to be held back, got %v packets", len(result))
+	}
+	result := b.DowngradeChunkPackets([]packet.Packet{newTestSubChunks(1, 0, indices[12:]...)}, ctx, conn)
+	if len(result) != 1 {
+		t.Fatalf("expected the completed chunk to be sent, got %v packets", len(result))
+	}
+	checkTestLevelChunk(t, result[0], 1, 0)
+}
+
+// TestSubChunkBridgeRequestLimit checks that no more than maxSubChunkRequests requests are added to a single packet of
+// the client.
+func TestSubChunkBridgeRequestLimit(t *testing.T) {
+	b, conn := NewSubChunkBridge(time.Minute), &minecraft.Conn{}
+	defer b.Release(conn)
+	ctx := &Context{Dimension: NewDimension(packet.DimensionOverworld)}
+
+	var pks []packet.Packet
+	for i := int32(0); i < 10; i++ {
+		pks = append(pks, newTestRequestedChunk(i*300, 0))
+	}
+	b.DowngradeChunkPackets(pks, ctx, conn)
+	for i, expected := range []int{maxSubChunkRequests, 2, 0} {
+		if requests := b.UpgradeChunkPackets(nil, conn); len(requests) != expected {
+			t.Fatalf("packet %v: expected %v sub chunk requests, got %v", i, expected, len(requests))
+		}
+	}
+}
+
+// TestSubChunkBridgeTimeoutFlush checks that chunks of which not all sub chunks arrived are written to the connection
+// once they time out, even if no other packets are sent to the client.
+func TestSubChunkBridgeTimeoutFlush(t *testing.T) {
+	b, conn := NewSubChunkBridge(10*time.Millisecond), &minecraft.Conn{}
+	defer b.Release(conn)
+	written := make(chan []packet.Packet, 1)
+	b.write = func(_ *minecraft.Conn, pks []packet.Packet) {
+		written <- pks
+	}
+	ctx := &Context{Dimension: NewDimension(packet.DimensionOverworld)}
+
+	b.DowngradeChunkPackets([]packet.Packet{newTestRequestedChunk(0, 0)}, ctx, conn)
+	b.UpgradeChunkPackets(nil, conn)
+	b.DowngradeChunkPackets([]packet.Packet{newTestSubChunks(0, 0, 0)}, ctx, conn)
+
+	select {
+	case pks := <-written:
+		if len(pks) != 1 {
+			t.Fatalf("expected a single chunk to be flushed, got %v packets", len(pks))
+		}
+		checkTestLevelChunk(t, pks[0], 0, 0)
+	case <-time.After(time.Second):
+		t.Fatalf("chunk was not flushed after timing out")
+	}
+}